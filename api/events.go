@@ -0,0 +1,19 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed events/*.json
+var eventSchemas embed.FS
+
+// EventSchemas returns the JSON Schemas of the event data published on Kafka,
+// one file per event type and version, e.g. ambulance_created.v1.json.
+func EventSchemas() fs.FS {
+	schemas, err := fs.Sub(eventSchemas, "events")
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}
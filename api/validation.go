@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+var unsupportedProperty = regexp.MustCompile(`^property "(.*)" is unsupported$`)
+
+// Violation describes one way in which a request does not match the OpenAPI specification.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateRequests returns a middleware that validates the path and query parameters and the body
+// of each request against the embedded OpenAPI specification before the handler runs.
+// Invalid requests are answered with 400 (or 415 for an undocumented content type) and a list of violations,
+// requests to paths that the specification does not describe are passed through.
+func ValidateRequests() (gin.HandlerFunc, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openapiSpec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		MultiError:          true,
+		SkipSettingDefaults: true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+	}
+	return func(c *gin.Context) {
+		route, pathParams, err := router.FindRoute(c.Request)
+		if err != nil {
+			if !errors.Is(err, routers.ErrPathNotFound) && !errors.Is(err, routers.ErrMethodNotAllowed) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Invalid request", "error": err.Error()})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			status, violations := describeValidationError(err)
+			c.AbortWithStatusJSON(status, gin.H{"message": "Request validation failed", "errors": violations})
+			return
+		}
+		c.Next()
+	}, nil
+}
+
+// describeValidationError flattens the errors reported by openapi3filter into violations.
+func describeValidationError(err error) (int, []Violation) {
+	status := http.StatusBadRequest
+	violations := []Violation{}
+
+	var errs openapi3.MultiError
+	if !errors.As(err, &errs) {
+		errs = openapi3.MultiError{err}
+	}
+	for _, err := range errs {
+		var requestErr *openapi3filter.RequestError
+		if !errors.As(err, &requestErr) {
+			violations = append(violations, Violation{Message: err.Error()})
+			continue
+		}
+
+		field := ""
+		switch {
+		case requestErr.Parameter != nil:
+			field = requestErr.Parameter.Name
+		case requestErr.RequestBody != nil && requestErr.Err == nil && strings.HasPrefix(requestErr.Reason, "header Content-Type"):
+			status = http.StatusUnsupportedMediaType
+			violations = append(violations, Violation{Field: "Content-Type", Message: requestErr.Reason})
+			continue
+		}
+
+		causes := openapi3.MultiError{requestErr.Err}
+		if requestErr.Err == nil {
+			causes = openapi3.MultiError{errors.New(requestErr.Reason)}
+		} else {
+			errors.As(requestErr.Err, &causes)
+		}
+		for _, cause := range causes {
+			violations = append(violations, describeCause(field, cause))
+		}
+	}
+	return status, violations
+}
+
+// describeCause names the offending field of a schema error, e.g. `price` or `items.0.op`.
+func describeCause(field string, cause error) Violation {
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(cause, &schemaErr) {
+		return Violation{Field: field, Message: cause.Error()}
+	}
+	path := schemaErr.JSONPointer()
+	if field != "" {
+		path = append([]string{field}, path...)
+	}
+	message := schemaErr.Reason
+	switch schemaErr.SchemaField {
+	case "properties":
+		// the unsupported property is only named in the reason
+		if match := unsupportedProperty.FindStringSubmatch(message); match != nil {
+			path = append(path, match[1])
+		}
+	case "format":
+		// drop the regular expression that follows the format name
+		if i := strings.Index(message, " ("); i > 0 {
+			message = message[:i]
+		}
+	}
+	return Violation{Field: strings.Join(path, "."), Message: message}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidationSuite struct {
+	suite.Suite
+	engine *gin.Engine
+}
+
+func TestValidationSuite(t *testing.T) {
+	suite.Run(t, new(ValidationSuite))
+}
+
+func (suite *ValidationSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	validator, err := ValidateRequests()
+	suite.Require().NoError(err)
+
+	suite.engine = gin.New()
+	suite.engine.Use(validator)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	suite.engine.POST("/api/procedures", ok)
+	suite.engine.PUT("/api/ambulances/:ambulanceId", ok)
+	suite.engine.PATCH("/api/ambulances/:ambulanceId", ok)
+	suite.engine.GET("/api/ambulances", ok)
+	suite.engine.GET("/openapi", ok)
+}
+
+func (suite *ValidationSuite) serve(method string, target string, contentType string, body string) (*httptest.ResponseRecorder, []Violation) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	suite.engine.ServeHTTP(recorder, request)
+
+	var response struct {
+		Errors []Violation `json:"errors"`
+	}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response.Errors
+}
+
+func (suite *ValidationSuite) Test_ValidProcedure_PassesThrough() {
+	recorder, _ := suite.serve("POST", "/api/procedures", "application/json",
+		`{"patient":"Peter","visit_type":"checkup","price":100,"ambulance_id":"amb1","timestamp":"2026-01-10T10:00:00Z"}`)
+	suite.Equal(http.StatusOK, recorder.Code)
+}
+
+func (suite *ValidationSuite) Test_InvalidProcedure_ReportsAllViolations() {
+	recorder, violations := suite.serve("POST", "/api/procedures", "application/json",
+		`{"patient":"","visit_type":"checkup","price":-1,"timestamp":"yesterday","visitType":"checkup"}`)
+
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	fields := map[string]bool{}
+	for _, violation := range violations {
+		fields[violation.Field] = true
+	}
+	suite.Equal(map[string]bool{"patient": true, "price": true, "timestamp": true, "ambulance_id": true, "visitType": true}, fields)
+}
+
+func (suite *ValidationSuite) Test_UnknownStatus_IsRejected() {
+	recorder, violations := suite.serve("PUT", "/api/ambulances/amb1", "application/json", `{"name":"A1","status":"Flying"}`)
+
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Require().Len(violations, 1)
+	suite.Equal("status", violations[0].Field)
+}
+
+func (suite *ValidationSuite) Test_MergePatch_AllowsClearingOptionalFields() {
+	recorder, _ := suite.serve("PATCH", "/api/ambulances/amb1", "application/merge-patch+json", `{"status":null,"capacity":0}`)
+	suite.Equal(http.StatusOK, recorder.Code)
+
+	recorder, violations := suite.serve("PATCH", "/api/ambulances/amb1", "application/merge-patch+json", `{"name":null}`)
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Require().Len(violations, 1)
+	suite.Equal("name", violations[0].Field)
+}
+
+func (suite *ValidationSuite) Test_UndocumentedContentType_ReturnsUnsupportedMediaType() {
+	recorder, _ := suite.serve("PATCH", "/api/ambulances/amb1", "text/plain", `name=A1`)
+	suite.Equal(http.StatusUnsupportedMediaType, recorder.Code)
+}
+
+func (suite *ValidationSuite) Test_QueryParameters_AreValidated() {
+	recorder, violations := suite.serve("GET", "/api/ambulances?limit=-1&status=Available", "", "")
+
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Require().Len(violations, 1)
+	suite.Equal("limit", violations[0].Field)
+}
+
+func (suite *ValidationSuite) Test_UndocumentedPath_PassesThrough() {
+	recorder, _ := suite.serve("GET", "/openapi", "", "")
+	suite.Equal(http.StatusOK, recorder.Code)
+}
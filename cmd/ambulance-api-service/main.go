@@ -3,8 +3,13 @@ package main
 import (
     "context"
     "log"
+    "net/http"
     "os"
+    "os/signal"
     "strings"
+    "sync"
+    "sync/atomic"
+    "syscall"
     "time"
 
 
@@ -15,18 +20,23 @@ import (
     "github.com/wac-project/wac-api/api"
 	"github.com/wac-project/wac-api/internal/ambulance"
 	"github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/camunda"
     "github.com/wac-project/wac-api/pkg/kafka"
 )
 
 
+// newDbService creates the DbService for one collection in the configured storage.
+func newDbService[DocType interface{}](storage string, collection string) db_service.DbService[DocType] {
+    if strings.EqualFold(storage, "memory") {
+        log.Printf("Using in-memory storage for collection %v", collection)
+        return db_service.NewMemoryService[DocType]()
+    }
+    return db_service.NewMongoService[DocType](db_service.MongoServiceConfig{Collection: collection})
+}
 
 func main() {
     log.Printf("Server started")
 
-    // point this at your local Docker-Compose broker
-    kafka.Init([]string{"localhost:9092"}, "hospital-events")
-    log.Println("✅ Kafka producer initialized")
-
     port := os.Getenv("AMBULANCE_API_PORT")
     if port == "" {
         port = "8080"
@@ -43,38 +53,153 @@ func main() {
     corsMiddleware := cors.New(cors.Config{
         AllowOrigins:     []string{"*"},
         AllowMethods:     []string{"GET", "PUT", "POST", "DELETE", "PATCH", "OPTIONS"},
-        AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-        ExposeHeaders:    []string{""},
+        AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "If-Match"},
+        ExposeHeaders:    []string{"Link", "X-Total-Count", "X-Next-Cursor", "ETag"},
         AllowCredentials: false,
         MaxAge:           12 * time.Hour,
     })
     engine.Use(corsMiddleware)
 
     // one service per collection/type
-   dbAmbSvc  := db_service.NewMongoService[ambulance.Ambulance](db_service.MongoServiceConfig{Collection: "ambulance"})
-   dbPaySvc  := db_service.NewMongoService[ambulance.Payment](  db_service.MongoServiceConfig{Collection: "payment"})
-   dbProcSvc := db_service.NewMongoService[ambulance.Procedure](db_service.MongoServiceConfig{Collection: "procedure"})
-
-   // tear down all three on exit
+   // AMBULANCE_API_STORAGE=memory runs the API without MongoDB, data is lost on exit
+   storage := os.Getenv("AMBULANCE_API_STORAGE")
+   dbAmbSvc  := newDbService[ambulance.Ambulance](storage, "ambulance")
+   dbPaySvc  := newDbService[ambulance.Payment](storage, "payment")
+   dbProcSvc := newDbService[ambulance.Procedure](storage, "procedure")
+   dbOutboxSvc := newDbService[kafka.OutboxMessage](storage, "outbox")
+
+   // tear down each service on exit, the costs service below likewise
    defer dbAmbSvc.Disconnect(context.Background())
    defer dbPaySvc.Disconnect(context.Background())
    defer dbProcSvc.Disconnect(context.Background())
+   defer dbOutboxSvc.Disconnect(context.Background())
+
+   // handlers store validated events in the outbox, the relay forwards them to the publisher
+   // selected by AMBULANCE_API_EVENT_PUBLISHER, Kafka configured by AMBULANCE_API_KAFKA_* by default
+   eventSchemas, err := kafka.NewSchemaRegistry(api.EventSchemas())
+   if err != nil {
+       log.Fatalf("Failed to load event schemas: %v", err)
+   }
+   outbox := db_service.NewOutboxStore(dbOutboxSvc)
+   eventPublisher := kafka.NewValidatingPublisher(eventSchemas, kafka.NewOutboxPublisher(outbox))
+   eventSink, err := kafka.NewEventPublisher("")
+   if err != nil {
+       log.Fatalf("Failed to create event publisher: %v", err)
+   }
+   defer eventSink.Close()
+
+   // the relay and the consumer run until shutdown, see below
+   backgroundCtx, stopBackground := context.WithCancel(context.Background())
+   defer stopBackground()
+   var background sync.WaitGroup
+   background.Add(1)
+   go func() {
+       defer background.Done()
+       kafka.NewRelay(outbox, eventSink, kafka.RelayConfig{}).Run(backgroundCtx)
+   }()
+
+   // with Kafka, consume the events again to maintain the projections;
+   // AMBULANCE_API_KAFKA_CONSUMER=false leaves that to another instance
+   var dbCostsSvc db_service.DbService[ambulance.AmbulanceCosts]
+   // projecting is cleared when the consumer stops, the summaries are then computed from the
+   // collections instead of the projection that is no longer updated
+   var projecting atomic.Bool
+   if _, ok := eventSink.(*kafka.KafkaPublisher); ok && !strings.EqualFold(os.Getenv("AMBULANCE_API_KAFKA_CONSUMER"), "false") {
+       dbCostsSvc = newDbService[ambulance.AmbulanceCosts](storage, "ambulance_costs")
+       defer dbCostsSvc.Disconnect(context.Background())
+
+       router := kafka.NewRouter()
+       ambulance.RegisterCostProjection(router, dbCostsSvc)
+       consumer, err := kafka.NewKafkaConsumer(kafka.ConsumerConfig{}, router)
+       if err != nil {
+           log.Fatalf("Failed to create event consumer: %v", err)
+       }
+       defer consumer.Close()
+       projecting.Store(true)
+       background.Add(1)
+       go func() {
+           defer background.Done()
+           if err := consumer.Run(backgroundCtx); err != nil {
+               projecting.Store(false)
+               log.Printf("⚠️ event consumer stopped, summarizing from the collections: %v", err)
+           }
+       }()
+   }
+
+   // the workflow endpoints proxy the task API of the Camunda engine
+   camundaURL := os.Getenv("AMBULANCE_API_CAMUNDA_URL")
+   if camundaURL == "" {
+       camundaURL = "http://localhost:8082/engine-rest"
+   }
+   camundaClient := camunda.NewClient(camundaURL)
+
+   // procedures are created without their process, it is started in the background
+   background.Add(1)
+   go func() {
+       defer background.Done()
+       ambulance.NewSubmissionStarter(dbProcSvc, eventPublisher, camundaClient, ambulance.SubmissionConfig{}).Run(backgroundCtx)
+   }()
+
+   // the worker authorizes its workflow status updates with this token, without it they are refused
+   workerToken := os.Getenv("AMBULANCE_API_WORKER_TOKEN")
+   if workerToken == "" {
+       log.Println("⚠️ AMBULANCE_API_WORKER_TOKEN is not set, workflow status updates are refused")
+   }
 
    // inject each under its own key
    engine.Use(func(ctx *gin.Context) {
        ctx.Set("db_service_ambulance", dbAmbSvc)
        ctx.Set("db_service_payment",   dbPaySvc)
        ctx.Set("db_service_procedure",  dbProcSvc)
+       ctx.Set("event_publisher",       eventPublisher)
+       ctx.Set("camunda_client",        camundaClient)
+       ctx.Set("worker_token",          workerToken)
+       if projecting.Load() {
+           ctx.Set("db_service_ambulance_costs", dbCostsSvc)
+       }
            ctx.Next()
     })
 
+    // reject requests that do not match the OpenAPI specification before they reach the handlers
+    validator, err := api.ValidateRequests()
+    if err != nil {
+        log.Fatalf("Failed to load OpenAPI specification: %v", err)
+    }
+    engine.Use(validator)
+
     handleFunctions := &ambulance.ApiHandleFunctions{
         AmbulanceManagementAPI: ambulance.NewAmbulanceAPI(),
         PaymentManagementAPI:   ambulance.NewPaymentAPI(),
         ProcedureManagementAPI: ambulance.NewProcedureAPI(),
+        WorkflowManagementAPI:  ambulance.NewWorkflowAPI(),
     }
 
     ambulance.NewRouterWithGinEngine(engine, *handleFunctions)
     engine.GET("/openapi", api.HandleOpenApi)
-    engine.Run(":" + port)
+
+    server := &http.Server{Addr: ":" + port, Handler: engine}
+    signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stopSignals()
+    go func() {
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Fatalf("Server failed: %v", err)
+        }
+    }()
+    <-signals.Done()
+
+    // finish the requests in flight, then stop the background work and flush pending
+    // Kafka messages before the deferred calls close the publishers and databases
+    log.Printf("Shutting down")
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+    defer cancel()
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        log.Printf("⚠️ server shutdown: %v", err)
+    }
+    stopBackground()
+    background.Wait()
+    if sink, ok := eventSink.(*kafka.KafkaPublisher); ok {
+        if err := sink.Shutdown(shutdownCtx); err != nil {
+            log.Printf("⚠️ flushing kafka messages: %v", err)
+        }
+    }
 }
@@ -0,0 +1,207 @@
+// Command ambulance-event-replay rebuilds the projections of the ambulance API by replaying
+// past events from the hospital-events topic or from the outbox collection through the
+// projection handlers. It uses the AMBULANCE_API_* environment of the API service.
+//
+//	ambulance-event-replay -reset                       # rebuild from the oldest retained event
+//	ambulance-event-replay -since 2026-01-01 -dry-run   # show what a replay would change
+//	ambulance-event-replay -source outbox -reset        # rebuild from the outbox collection
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "reflect"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/wac-project/wac-api/internal/ambulance"
+    "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// newDbService creates the DbService for one collection in the configured storage.
+func newDbService[DocType interface{}](storage string, collection string) db_service.DbService[DocType] {
+    if strings.EqualFold(storage, "memory") {
+        log.Printf("Using in-memory storage for collection %v", collection)
+        return db_service.NewMemoryService[DocType]()
+    }
+    return db_service.NewMongoService[DocType](db_service.MongoServiceConfig{Collection: collection})
+}
+
+// rebuild is one projection being replayed.
+type rebuild interface {
+    // prepare registers the handlers, writing to a scratch copy of the projection in dry-run mode.
+    prepare(ctx context.Context, router *kafka.Router) error
+    // report describes the outcome, in dry-run mode the differences to the stored projection.
+    report(ctx context.Context) (string, error)
+    close(ctx context.Context)
+}
+
+// projection rebuilds the read model stored in one collection.
+type projection[DocType any] struct {
+    name     string
+    id       func(document *DocType) string
+    register func(router *kafka.Router, documents db_service.DbService[DocType])
+
+    stored db_service.DbService[DocType]
+    target db_service.DbService[DocType]
+    reset  bool
+    dryRun bool
+}
+
+func (p *projection[DocType]) prepare(ctx context.Context, router *kafka.Router) error {
+    p.target = p.stored
+    if p.dryRun {
+        p.target = db_service.NewMemoryService[DocType]()
+        if !p.reset {
+            documents, err := p.stored.ListDocuments(ctx)
+            if err != nil {
+                return err
+            }
+            for i := range documents {
+                if err := p.target.CreateDocument(ctx, p.id(&documents[i]), &documents[i]); err != nil {
+                    return err
+                }
+            }
+        }
+    } else if p.reset {
+        deleted, err := p.stored.DeleteDocuments(ctx, db_service.And())
+        if err != nil {
+            return err
+        }
+        log.Printf("Deleted %d documents of projection %s", deleted, p.name)
+    }
+    p.register(router, p.target)
+    return nil
+}
+
+func (p *projection[DocType]) report(ctx context.Context) (string, error) {
+    rebuilt, err := p.target.ListDocuments(ctx)
+    if err != nil {
+        return "", err
+    }
+    if !p.dryRun {
+        return fmt.Sprintf("projection %s has %d documents", p.name, len(rebuilt)), nil
+    }
+
+    stored, err := p.stored.ListDocuments(ctx)
+    if err != nil {
+        return "", err
+    }
+    previous := map[string]*DocType{}
+    for i := range stored {
+        previous[p.id(&stored[i])] = &stored[i]
+    }
+    created, changed, unchanged := 0, 0, 0
+    for i := range rebuilt {
+        id := p.id(&rebuilt[i])
+        switch old, ok := previous[id]; {
+        case !ok:
+            created++
+        case reflect.DeepEqual(*old, rebuilt[i]):
+            unchanged++
+        default:
+            changed++
+        }
+        delete(previous, id)
+    }
+    return fmt.Sprintf("projection %s would have %d documents: %d created, %d changed, %d unchanged, %d deleted",
+        p.name, len(rebuilt), created, changed, unchanged, len(previous)), nil
+}
+
+func (p *projection[DocType]) close(ctx context.Context) {
+    p.stored.Disconnect(ctx)
+}
+
+func main() {
+    source := flag.String("source", "kafka", "where to read the events from: kafka or outbox")
+    offset := flag.Int64("offset", 0, "first offset replayed in every partition of the topic")
+    since := flag.String("since", "", "replay only events at or after this RFC 3339 time or date, overrides -offset")
+    names := flag.String("projections", "costs", "comma separated projections to rebuild: costs")
+    reset := flag.Bool("reset", false, "delete the projections before the replay")
+    dryRun := flag.Bool("dry-run", false, "replay into an in-memory copy and report the differences without writing")
+    flag.Parse()
+
+    start := kafka.ReplayStart{Offset: *offset}
+    if *since != "" {
+        var err error
+        if start.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+            if start.Since, err = time.Parse(time.DateOnly, *since); err != nil {
+                log.Fatalf("Invalid -since value %q, expected an RFC 3339 time or a date", *since)
+            }
+        }
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    storage := os.Getenv("AMBULANCE_API_STORAGE")
+    available := map[string]func() rebuild{
+        "costs": func() rebuild {
+            return &projection[ambulance.AmbulanceCosts]{
+                name:     "costs",
+                id:       func(costs *ambulance.AmbulanceCosts) string { return costs.Id },
+                register: ambulance.RegisterCostProjection,
+                stored:   newDbService[ambulance.AmbulanceCosts](storage, "ambulance_costs"),
+                reset:    *reset,
+                dryRun:   *dryRun,
+            }
+        },
+    }
+
+    router := kafka.NewRouter()
+    rebuilds := []rebuild{}
+    for _, name := range strings.Split(*names, ",") {
+        create, ok := available[strings.TrimSpace(name)]
+        if !ok {
+            log.Fatalf("Unknown projection %q", name)
+        }
+        rebuild := create()
+        defer rebuild.close(context.Background())
+        if err := rebuild.prepare(ctx, router); err != nil {
+            log.Fatalf("Failed to prepare projection %s: %v", name, err)
+        }
+        rebuilds = append(rebuilds, rebuild)
+    }
+
+    replayer := kafka.NewReplayer(router)
+    replayer.OnProgress = func(progress kafka.ReplayProgress) {
+        percent := 100.0
+        if progress.Total > 0 {
+            percent = 100 * float64(progress.Done()) / float64(progress.Total)
+        }
+        log.Printf("Replayed %d/%d events (%.0f%%), %d skipped", progress.Done(), progress.Total, percent, progress.Skipped)
+    }
+
+    var err error
+    switch *source {
+    case "kafka":
+        _, err = replayer.ReplayTopic(ctx, kafka.KafkaConfig{}, start)
+    case "outbox":
+        outbox := newDbService[kafka.OutboxMessage](storage, "outbox")
+        defer outbox.Disconnect(context.Background())
+        _, err = replayer.ReplayOutbox(ctx, db_service.NewOutboxStore(outbox), start.Since)
+    default:
+        log.Fatalf("Unknown source %q, expected kafka or outbox", *source)
+    }
+    if err != nil {
+        log.Printf("Replay failed: %v", err)
+    }
+
+    for _, rebuild := range rebuilds {
+        summary, reportErr := rebuild.report(context.Background())
+        if reportErr != nil {
+            log.Printf("⚠️ %v", reportErr)
+            continue
+        }
+        log.Print(summary)
+    }
+    if err != nil {
+        os.Exit(1)
+    }
+}
@@ -37,6 +37,10 @@ type AmbulanceManagementAPI interface {
     // Get list of ambulances 
      GetAmbulances(c *gin.Context)
 
+    // PatchAmbulance Patch /api/ambulances/:ambulanceId
+    // Partially update ambulance details 
+     PatchAmbulance(c *gin.Context)
+
     // UpdateAmbulance Put /api/ambulances/:ambulanceId
     // Update ambulance details 
     UpdateAmbulance(c *gin.Context)
@@ -33,6 +33,10 @@ type PaymentManagementAPI interface {
     // Get list of payment records 
      GetPayments(c *gin.Context)
 
+    // PatchPayment Patch /api/payments/:paymentId
+    // Partially update payment details 
+     PatchPayment(c *gin.Context)
+
     // UpdatePayment Put /api/payments/:paymentId
     // Update payment record details 
      UpdatePayment(c *gin.Context)
@@ -33,6 +33,10 @@ type ProcedureManagementAPI interface {
     // Get list of procedures 
      GetProcedures(c *gin.Context)
 
+    // PatchProcedure Patch /api/procedures/:procedureId
+    // Partially update procedure details 
+     PatchProcedure(c *gin.Context)
+
     // UpdateProcedure Put /api/procedures/:procedureId
     // Update procedure details 
      UpdateProcedure(c *gin.Context)
@@ -0,0 +1,52 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type WorkflowManagementAPI interface {
+
+
+    // ApproveSubmission Post /api/approvals/:taskId/approve
+    // Approve a submitted procedure 
+     ApproveSubmission(c *gin.Context)
+
+    // ClaimApproval Post /api/approvals/:taskId/claim
+    // Claim a pending approval 
+     ClaimApproval(c *gin.Context)
+
+    // CorrectProcedure Put /api/corrections/:taskId
+    // Resubmit a corrected procedure 
+     CorrectProcedure(c *gin.Context)
+
+    // GetApprovals Get /api/approvals
+    // Get list of pending approvals 
+     GetApprovals(c *gin.Context)
+
+    // GetCorrections Get /api/corrections
+    // Get list of procedures awaiting correction 
+     GetCorrections(c *gin.Context)
+
+    // GetProcedureWorkflow Get /api/procedures/:procedureId/workflow
+    // Get the workflow status and history of a procedure 
+     GetProcedureWorkflow(c *gin.Context)
+
+    // RejectSubmission Post /api/approvals/:taskId/reject
+    // Reject a submitted procedure 
+     RejectSubmission(c *gin.Context)
+
+    // UpdateProcedureWorkflowStatus Put /api/procedures/:procedureId/workflow/status
+    // Set the workflow status of a procedure 
+     UpdateProcedureWorkflowStatus(c *gin.Context)
+
+}
\ No newline at end of file
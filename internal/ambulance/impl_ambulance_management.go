@@ -15,13 +15,13 @@
 	 "log"
 	 "net/http"
 	 "time"
-	 "encoding/json"
+	 "errors"
+	 "fmt"
  
 	 "github.com/gin-gonic/gin"
 	 "github.com/google/uuid"
 	 "github.com/wac-project/wac-api/internal/db_service"
-
-    "github.com/wac-project/wac-api/pkg/kafka"
+	 "github.com/wac-project/wac-api/pkg/kafka"
  )
  
  // implAmbulanceAPI implements the AmbulanceManagementAPI interface using the standard DbService interface.
@@ -47,7 +47,7 @@
 	 ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	 defer cancel()
  
-	 ambulance, err := db.FindDocument(ctx, ambulanceId)
+	 ambulance, version, err := db.FindVersionedDocument(ctx, ambulanceId)
 	 if err != nil {
 		 if err == db_service.ErrNotFound {
 			 c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Ambulance not found"})
@@ -57,16 +57,40 @@
 		 }
 		 return
 	 }
+	 if !ifMatchSatisfied(c, version) {
+		 c.JSON(http.StatusPreconditionFailed, gin.H{"status": http.StatusPreconditionFailed, "message": "Ambulance has been modified"})
+		 return
+	 }
  
+	 before := *ambulance
 	 updatedAmbulance, result, statusCode := fn(c, ambulance)
 	 if updatedAmbulance != nil {
-		 err := db.UpdateDocument(ctx, ambulanceId, updatedAmbulance)
-		 if err != nil {
+		 err = db.WithTransaction(ctx, func(ctx context.Context) error {
+			 var err error
+			 if version, err = db.UpdateVersionedDocument(ctx, ambulanceId, updatedAmbulance, version); err != nil {
+				 return err
+			 }
+			 return publishUpdated(ctx, getEventPublisher(c), ambulanceId, &before, updatedAmbulance, func(changes map[string]FieldChange) kafka.Payload {
+				 return AmbulanceUpdated{Ambulance: *updatedAmbulance, Changes: changes}
+			 })
+		 })
+		 switch err {
+		 case nil:
+		 case db_service.ErrVersionMismatch:
+			 c.JSON(http.StatusPreconditionFailed, gin.H{"status": http.StatusPreconditionFailed, "message": "Ambulance has been modified"})
+			 return
+		 case db_service.ErrNotFound:
+			 c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Ambulance not found"})
+			 return
+		 default:
 			 log.Println("UpdateDocument error:", err)
 			 c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Failed to update ambulance"})
 			 return
 		 }
 	 }
+	 if _, ok := result.(*Ambulance); ok && statusCode < http.StatusMultipleChoices {
+		 c.Header("ETag", etag(version))
+	 }
 	 c.JSON(statusCode, result)
  }
  
@@ -84,7 +108,12 @@
 	 ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	 defer cancel()
  
-	 err := db.CreateDocument(ctx, ambulance.Id, &ambulance)
+	 err := db.WithTransaction(ctx, func(ctx context.Context) error {
+		 if err := db.CreateDocument(ctx, ambulance.Id, &ambulance); err != nil {
+			 return err
+		 }
+		 return publishEvent(ctx, getEventPublisher(c), ambulance.Id, AmbulanceCreated{Ambulance: ambulance})
+	 })
 	 if err != nil {
 		 if err == db_service.ErrConflict {
 			 c.JSON(http.StatusConflict, gin.H{"message": "Ambulance with this ID already exists"})
@@ -94,30 +123,118 @@
 		 }
 		 return
 	 }
-
-	 evt := map[string]interface{}{
-    	"type":         "ambulance_created",
-    	"ambulance_id": ambulance.Id,
-    	"status":       ambulance.Status,
-    }
-  	b, _ := json.Marshal(evt)
-	kafka.Send(ctx, []byte(ambulance.Id), b)
  
+	 c.Header("ETag", etag(db_service.InitialVersion))
 	 c.JSON(http.StatusCreated, ambulance)
  }
  
+ // DeleteAmbulance removes the ambulance together with its procedures and their payments.
+ // With ?mode=restrict it refuses to delete an ambulance that still has procedures.
  func (o *implAmbulanceAPI) DeleteAmbulance(c *gin.Context) {
+	 mode := c.DefaultQuery("mode", "cascade")
+	 if mode != "cascade" && mode != "restrict" {
+		 c.JSON(http.StatusBadRequest, gin.H{"message": "mode must be cascade or restrict"})
+		 return
+	 }
+
 	 withAmbulanceByID(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		 db := getDB(c)
 		 ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		 defer cancel()
- 
-		 err := db.DeleteDocument(ctx, ambulance.Id)
-		 if err != nil {
+
+		 var matched *int64
+		 if version, ok := matchedVersion(c); ok {
+			 matched = &version
+		 }
+		 err := deleteAmbulanceCascade(ctx, getDB(c), getProcedureDB(c), getPaymentDB(c), getEventPublisher(c), ambulance, matched, mode == "restrict")
+		 var dependants *errHasDependants
+		 switch {
+		 case err == nil:
+			 return nil, nil, http.StatusNoContent
+		 case errors.As(err, &dependants):
+			 return nil, gin.H{"message": "Ambulance has procedures", "procedures": dependants.count}, http.StatusConflict
+		 case err == db_service.ErrVersionMismatch:
+			 return nil, gin.H{"status": http.StatusPreconditionFailed, "message": "Ambulance has been modified"}, http.StatusPreconditionFailed
+		 case err == db_service.ErrNotFound:
+			 return nil, gin.H{"status": http.StatusNotFound, "message": "Ambulance not found"}, http.StatusNotFound
+		 default:
 			 log.Println("DeleteDocument error:", err)
 			 return nil, gin.H{"message": "Failed to delete ambulance"}, http.StatusInternalServerError
 		 }
-		 return nil, nil, http.StatusNoContent
+	 })
+ }
+
+ // errHasDependants is returned by deleteAmbulanceCascade in restrict mode.
+ type errHasDependants struct {
+	 count int64
+ }
+
+ func (e *errHasDependants) Error() string {
+	 return fmt.Sprintf("ambulance has %d procedures", e.count)
+ }
+
+ // deleteAmbulanceCascade deletes the ambulance, its procedures and the payments of
+ // those procedures in one transaction and records a *_deleted event for each of them.
+ // If version is set, the ambulance is deleted only at that version and the transaction fails with
+ // db_service.ErrVersionMismatch if it was modified meanwhile. If restrict is set, an ambulance with procedures is left untouched and errHasDependants is returned.
+ func deleteAmbulanceCascade(
+	 ctx context.Context,
+	 ambulances db_service.DbService[Ambulance],
+	 procedures db_service.DbService[Procedure],
+	 payments db_service.DbService[Payment],
+	 publisher kafka.EventPublisher,
+	 ambulance *Ambulance,
+	 version *int64,
+	 restrict bool,
+ ) error {
+	 return ambulances.WithTransaction(ctx, func(ctx context.Context) error {
+		 linked := db_service.Eq("ambulance_id", ambulance.Id)
+		 linkedProcedures, err := procedures.FindDocuments(ctx, linked)
+		 if err != nil {
+			 return err
+		 }
+		 if restrict && len(linkedProcedures) > 0 {
+			 return &errHasDependants{count: int64(len(linkedProcedures))}
+		 }
+
+		 // the ambulance goes first, so a failed version check leaves its procedures alone
+		 deleteAmbulance := ambulances.DeleteDocument
+		 if version != nil {
+			 deleteAmbulance = func(ctx context.Context, id string) error {
+				 return ambulances.DeleteVersionedDocument(ctx, id, *version)
+			 }
+		 }
+		 if err := deleteAmbulance(ctx, ambulance.Id); err != nil {
+			 return err
+		 }
+
+		 if len(linkedProcedures) > 0 {
+			 procedureIds := make([]any, len(linkedProcedures))
+			 for i, procedure := range linkedProcedures {
+				 procedureIds[i] = procedure.Id
+			 }
+			 paid := db_service.In("procedure_id", procedureIds...)
+			 linkedPayments, err := payments.FindDocuments(ctx, paid)
+			 if err != nil {
+				 return err
+			 }
+			 if _, err := payments.DeleteDocuments(ctx, paid); err != nil {
+				 return err
+			 }
+			 for i := range linkedPayments {
+				 if err := publishEvent(ctx, publisher, linkedPayments[i].Id, PaymentDeleted{Payment: linkedPayments[i]}); err != nil {
+					 return err
+				 }
+			 }
+			 if _, err := procedures.DeleteDocuments(ctx, linked); err != nil {
+				 return err
+			 }
+			 for i := range linkedProcedures {
+				 if err := publishEvent(ctx, publisher, linkedProcedures[i].Id, ProcedureDeleted{Procedure: linkedProcedures[i]}); err != nil {
+					 return err
+				 }
+			 }
+		 }
+		 return publishEvent(ctx, publisher, ambulance.Id, AmbulanceDeleted{Ambulance: *ambulance})
 	 })
  }
  
@@ -128,71 +245,228 @@
  }
  
  func (o *implAmbulanceAPI) GetAmbulances(c *gin.Context) {
-    db := getDB(c)
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-
-    list, err := db.ListDocuments(ctx)
+    opts, err := parseListOptions(c)
     if err != nil {
-        log.Println("ListDocuments error:", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to list ambulances"})
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid list parameters", "error": err.Error()})
         return
     }
-    c.JSON(http.StatusOK, list)
+    respondWithPage(c, getDB(c), opts, "ambulances")
 }
 
  
  func (o *implAmbulanceAPI) UpdateAmbulance(c *gin.Context) {
 	 withAmbulanceByID(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		 var updated Ambulance
-		 if err := c.ShouldBindJSON(&updated); err != nil {
+		 var replacement Ambulance
+		 if err := c.ShouldBindJSON(&replacement); err != nil {
 			 return nil, gin.H{"message": "Invalid request body", "error": err.Error()}, http.StatusBadRequest
 		 }
- 
-		 if updated.Name != "" {
-			 ambulance.Name = updated.Name
-		 }
-		 if updated.Location != "" {
-			 ambulance.Location = updated.Location
-		 }
-		 if updated.Department != "" {
-			 ambulance.Department = updated.Department
-		 }
-		 if updated.Capacity != 0 {
-			 ambulance.Capacity = updated.Capacity
-		 }
-		 if updated.Status != "" {
-			 ambulance.Status = updated.Status
+		 return replaceAmbulance(ambulance, &replacement)
+	 })
+ }
+
+ // PatchAmbulance applies a JSON Merge Patch or JSON Patch to an ambulance.
+ func (o *implAmbulanceAPI) PatchAmbulance(c *gin.Context) {
+	 withAmbulanceByID(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+		 patched, err := patchDocument(c, ambulance)
+		 if err != nil {
+			 result, status := patchFailure(err)
+			 return nil, result, status
 		 }
- 
-		 return ambulance, ambulance, http.StatusOK
+		 return replaceAmbulance(ambulance, patched)
 	 })
  }
+
+ // replaceAmbulance validates replacement as the new state of ambulance.
+ func replaceAmbulance(ambulance *Ambulance, replacement *Ambulance) (*Ambulance, interface{}, int) {
+	 if replacement.Id != "" && replacement.Id != ambulance.Id {
+		 return nil, gin.H{"message": "Ambulance id cannot be changed"}, http.StatusBadRequest
+	 }
+	 replacement.Id = ambulance.Id
+	 return replacement, replacement, http.StatusOK
+ }
  
  func (o *implAmbulanceAPI) GetAmbulanceSummary(c *gin.Context) {
 	 withAmbulanceByID(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		 summary := gin.H{
-			 "ambulance_id": ambulance.Id,
-			 "total_cost":   0.0, // Placeholder value
+		 from, to := c.Query("from"), c.Query("to")
+		 if !isISODate(from) || !isISODate(to) {
+			 return nil, gin.H{"message": "from and to must be ISO 8601 dates (YYYY-MM-DD)"}, http.StatusBadRequest
+		 }
+
+		 ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		 defer cancel()
+
+		 var summary *GetAmbulanceSummary200Response
+		 var err error
+		 if costs, ok := getCostsDB(c); ok && from == "" && to == "" {
+			 summary, err = summarizeProjection(ctx, costs, getProcedureDB(c), getPaymentDB(c), ambulance.Id)
+		 }
+		 if summary == nil && err == nil {
+			 summary, err = summarizeAmbulance(ctx, getProcedureDB(c), getPaymentDB(c), ambulance.Id, from, to)
+		 }
+		 if err != nil {
+			 log.Println("Aggregate error:", err)
+			 return nil, gin.H{"message": "Failed to summarize ambulance"}, http.StatusInternalServerError
 		 }
 		 return nil, summary, http.StatusOK
 	 })
  }
 
- // GetProceduresByAmbulance returns all procedures associated with a given ambulance.
+ // summarizeAmbulance totals the procedures of an ambulance with a timestamp in [from, to)
+ // and the payments made for them. Empty from or to leave the period open.
+ func summarizeAmbulance(
+	 ctx context.Context,
+	 procedures db_service.DbService[Procedure],
+	 payments db_service.DbService[Payment],
+	 ambulanceId, from, to string,
+ ) (*GetAmbulanceSummary200Response, error) {
+	 filter := db_service.And(
+		 db_service.Eq("ambulance_id", ambulanceId),
+		 db_service.Range("timestamp", optional(from), optional(to)),
+	 )
+	 summary := &GetAmbulanceSummary200Response{
+		 AmbulanceId:     ambulanceId,
+		 From:            from,
+		 To:              to,
+		 VisitTypeCounts: map[string]int64{},
+		 PayerCounts:     map[string]int64{},
+	 }
+
+	 totals, err := procedures.Aggregate(ctx, db_service.Aggregation{Filter: &filter, GroupBy: "ambulance_id", Sum: []string{"price"}})
+	 if err != nil {
+		 return nil, err
+	 }
+	 for _, group := range totals {
+		 summary.ProcedureCount += group.Count
+		 summary.TotalCost += group.Sums["price"]
+	 }
+
+	 for field, counts := range map[string]map[string]int64{"visit_type": summary.VisitTypeCounts, "payer": summary.PayerCounts} {
+		 groups, err := procedures.Aggregate(ctx, db_service.Aggregation{Filter: &filter, GroupBy: field})
+		 if err != nil {
+			 return nil, err
+		 }
+		 for _, group := range groups {
+			 counts[fmt.Sprint(group.Key)] = group.Count
+		 }
+	 }
+
+	 if summary.TotalPaid, err = sumPayments(ctx, payments, procedures, filter); err != nil {
+		 return nil, err
+	 }
+	 summary.OutstandingBalance = summary.TotalCost - summary.TotalPaid
+	 return summary, nil
+ }
+
+ // summarizeProjection summarizes all procedures of an ambulance from the AmbulanceCosts
+ // projection, which may lag behind the procedures collection. It returns nil if the
+ // ambulance has no projection yet. Payments are totalled for the procedures of the
+ // projection only, so that the balance does not count payments of procedures it lacks.
+ func summarizeProjection(
+	 ctx context.Context,
+	 costs db_service.DbService[AmbulanceCosts],
+	 procedures db_service.DbService[Procedure],
+	 payments db_service.DbService[Payment],
+	 ambulanceId string,
+ ) (*GetAmbulanceSummary200Response, error) {
+	 projection, err := costs.FindDocument(ctx, ambulanceId)
+	 if err == db_service.ErrNotFound {
+		 return nil, nil
+	 }
+	 if err != nil {
+		 return nil, err
+	 }
+
+	 summary := &GetAmbulanceSummary200Response{
+		 AmbulanceId:     ambulanceId,
+		 ProcedureCount:  projection.ProcedureCount,
+		 TotalCost:       projection.TotalCost,
+		 VisitTypeCounts: map[string]int64{},
+		 PayerCounts:     map[string]int64{},
+	 }
+	 ids := make([]any, 0, len(projection.Procedures))
+	 for id, procedure := range projection.Procedures {
+		 summary.VisitTypeCounts[procedure.VisitType]++
+		 summary.PayerCounts[procedure.Payer]++
+		 ids = append(ids, id)
+	 }
+	 if summary.TotalPaid, err = sumPayments(ctx, payments, procedures, db_service.In("id", ids...)); err != nil {
+		 return nil, err
+	 }
+	 summary.OutstandingBalance = summary.TotalCost - summary.TotalPaid
+	 return summary, nil
+ }
+
+ // sumPayments totals the payments made for the procedures matching filter.
+ func sumPayments(
+	 ctx context.Context,
+	 payments db_service.DbService[Payment],
+	 procedures db_service.DbService[Procedure],
+	 filter db_service.Filter,
+ ) (float64, error) {
+	 groups, err := payments.Aggregate(ctx, db_service.Aggregation{
+		 Join: &db_service.Join{LocalField: "procedure_id", From: procedures, Filter: &filter},
+		 Sum:  []string{"amount"},
+	 })
+	 if err != nil {
+		 return 0, err
+	 }
+	 total := 0.0
+	 for _, group := range groups {
+		 total += group.Sums["amount"]
+	 }
+	 return total, nil
+ }
+
+ // optional maps an empty query value to an open range bound.
+ func optional(value string) any {
+	 if value == "" {
+		 return nil
+	 }
+	 return value
+ }
+
+ // isISODate accepts empty values and dates (2006-01-02). Timestamps are not accepted, the
+ // bounds are compared with the stored UTC timestamps as strings, which only orders dates correctly.
+ func isISODate(value string) bool {
+	 if value == "" {
+		 return true
+	 }
+	 _, err := time.Parse(time.DateOnly, value)
+	 return err == nil
+ }
+
+ // GetProceduresByAmbulance returns the procedures associated with a given ambulance.
+ // It supports the same filters, sorting and paging as GetProcedures.
 func (o *implAmbulanceAPI) GetProceduresByAmbulance(c *gin.Context) {
     ambulanceID := c.Param("ambulanceId")
     if ambulanceID == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "ambulanceId is required"})
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Ambulance ID is required"})
         return
     }
 
-    // NOTE: replace the following stub with your actual DB/store call.
-    // e.g. procedures, err := o.store.GetProceduresByAmbulanceID(ctx, ambulanceID)
-    // For now, we’ll return an empty slice.
-    procedures := []Procedure{}
+    opts, err := parseListOptions(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid list parameters", "error": err.Error()})
+        return
+    }
 
-    c.JSON(http.StatusOK, procedures)
-}
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    if _, err := getDB(c).FindDocument(ctx, ambulanceID); err != nil {
+        if err == db_service.ErrNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"message": "Ambulance not found"})
+        } else {
+            log.Println("FindDocument error:", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"message": "Internal error"})
+        }
+        return
+    }
 
- 
\ No newline at end of file
+    filter := db_service.Eq("ambulance_id", ambulanceID)
+    if opts.Filter != nil {
+        filter = db_service.And(filter, *opts.Filter)
+    }
+    opts.Filter = &filter
+    respondWithPage(c, getProcedureDB(c), opts, "procedures")
+}
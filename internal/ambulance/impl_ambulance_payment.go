@@ -9,6 +9,7 @@ import (
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
     "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/kafka"
 )
 
 // implPaymentAPI implements the PaymentManagementAPI interface.
@@ -39,7 +40,7 @@ func withPaymentByID(
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    p, err := db.FindDocument(ctx, id)
+    p, version, err := db.FindVersionedDocument(ctx, id)
     if err != nil {
         if err == db_service.ErrNotFound {
             c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
@@ -49,15 +50,40 @@ func withPaymentByID(
         }
         return
     }
+    if !ifMatchSatisfied(c, version) {
+        c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Payment has been modified"})
+        return
+    }
 
+    before := *p
     updated, result, status := fn(c, p)
     if updated != nil {
-        if err := db.UpdateDocument(ctx, id, updated); err != nil {
+        err = db.WithTransaction(ctx, func(ctx context.Context) error {
+            var err error
+            if version, err = db.UpdateVersionedDocument(ctx, id, updated, version); err != nil {
+                return err
+            }
+            return publishUpdated(ctx, getEventPublisher(c), id, &before, updated, func(changes map[string]FieldChange) kafka.Payload {
+                return PaymentUpdated{Payment: *updated, Changes: changes}
+            })
+        })
+        switch err {
+        case nil:
+        case db_service.ErrVersionMismatch:
+            c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Payment has been modified"})
+            return
+        case db_service.ErrNotFound:
+            c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+            return
+        default:
             log.Println("UpdateDocument error:", err)
             c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update payment"})
             return
         }
     }
+    if _, ok := result.(*Payment); ok && status < http.StatusMultipleChoices {
+        c.Header("ETag", etag(version))
+    }
     c.JSON(status, result)
 }
 
@@ -76,7 +102,22 @@ func (o *implPaymentAPI) CreatePayment(c *gin.Context) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    if err := db.CreateDocument(ctx, p.Id, &p); err != nil {
+    if invalid, err := checkReference(ctx, getProcedureDB(c), "procedure_id", p.ProcedureId, "procedure"); err != nil {
+        log.Println("FindDocument error:", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create payment"})
+        return
+    } else if invalid != nil {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Invalid reference", "errors": []FieldError{*invalid}})
+        return
+    }
+
+    err := db.WithTransaction(ctx, func(ctx context.Context) error {
+        if err := db.CreateDocument(ctx, p.Id, &p); err != nil {
+            return err
+        }
+        return publishEvent(ctx, getEventPublisher(c), p.Id, PaymentCreated{Payment: p})
+    })
+    if err != nil {
         switch err {
         case db_service.ErrConflict:
             c.JSON(http.StatusConflict, gin.H{"message": "Payment already exists"})
@@ -86,6 +127,7 @@ func (o *implPaymentAPI) CreatePayment(c *gin.Context) {
         }
         return
     }
+    c.Header("ETag", etag(db_service.InitialVersion))
     c.JSON(http.StatusCreated, p)
 }
 
@@ -96,70 +138,58 @@ func (o *implPaymentAPI) GetPaymentById(c *gin.Context) {
     })
 }
 
+// GetPayments implements GET /api/payments
 func (o *implPaymentAPI) GetPayments(c *gin.Context) {
-    db := getPaymentDB(c)
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-
-    procedureID := c.Query("procedure_id")
-
-    var payments []*Payment
-    var err error
-
-    if procedureID != "" {
-        payments, err = db.FindDocumentsByField(ctx, "procedure_id", procedureID)
-    } else {
-        // ✅ Call your generic method to list all
-        var result []Payment
-        result, err = db.ListDocuments(ctx)
-        if err != nil {
-            log.Println("ListDocuments error:", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve payments"})
-            return
-        }
-
-        c.JSON(http.StatusOK, result)
-        return
-    }
-
+    opts, err := parseListOptions(c)
     if err != nil {
-        log.Println("FindDocumentsByField error:", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve payments"})
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid list parameters", "error": err.Error()})
         return
     }
-
-    // Convert []*Payment to []Payment (if needed)
-    var result []Payment
-    for _, p := range payments {
-        result = append(result, *p)
-    }
-
-    c.JSON(http.StatusOK, result)
+    respondWithPage(c, getPaymentDB(c), opts, "payments")
 }
 
-
-
-
 // UpdatePayment implements PUT /api/payments/:paymentId
+// The request body replaces the stored payment; omitted fields are cleared.
 func (o *implPaymentAPI) UpdatePayment(c *gin.Context) {
     withPaymentByID(c, func(_ *gin.Context, existing *Payment) (*Payment, interface{}, int) {
-        var upd Payment
-        if err := c.ShouldBindJSON(&upd); err != nil {
+        var replacement Payment
+        if err := c.ShouldBindJSON(&replacement); err != nil {
             return nil, gin.H{"message": "Invalid request", "error": err.Error()}, http.StatusBadRequest
         }
-        if upd.Insurance != "" {
-            existing.Insurance = upd.Insurance
-        }
-        if upd.Amount != 0 {
-            existing.Amount = upd.Amount
-        }
-        if upd.Timestamp != "" {
-            existing.Timestamp = upd.Timestamp
+        return replacePayment(c, existing, &replacement)
+    })
+}
+
+// PatchPayment implements PATCH /api/payments/:paymentId
+func (o *implPaymentAPI) PatchPayment(c *gin.Context) {
+    withPaymentByID(c, func(_ *gin.Context, existing *Payment) (*Payment, interface{}, int) {
+        patched, err := patchDocument(c, existing)
+        if err != nil {
+            result, status := patchFailure(err)
+            return nil, result, status
         }
-        return existing, existing, http.StatusOK
+        return replacePayment(c, existing, patched)
     })
 }
 
+// replacePayment validates replacement as the new state of existing.
+func replacePayment(c *gin.Context, existing *Payment, replacement *Payment) (*Payment, interface{}, int) {
+    if replacement.Id != "" && replacement.Id != existing.Id {
+        return nil, gin.H{"message": "Payment id cannot be changed"}, http.StatusBadRequest
+    }
+    replacement.Id = existing.Id
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+    if invalid, err := checkReference(ctx, getProcedureDB(c), "procedure_id", replacement.ProcedureId, "procedure"); err != nil {
+        log.Println("FindDocument error:", err)
+        return nil, gin.H{"message": "Failed to update payment"}, http.StatusInternalServerError
+    } else if invalid != nil {
+        return nil, gin.H{"message": "Invalid reference", "errors": []FieldError{*invalid}}, http.StatusUnprocessableEntity
+    }
+    return replacement, replacement, http.StatusOK
+}
+
 // DeletePayment implements DELETE /api/payments/:paymentId
 func (o *implPaymentAPI) DeletePayment(c *gin.Context) {
     withPaymentByID(c, func(_ *gin.Context, p *Payment) (*Payment, interface{}, int) {
@@ -167,7 +197,19 @@ func (o *implPaymentAPI) DeletePayment(c *gin.Context) {
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        if err := db.DeleteDocument(ctx, p.Id); err != nil {
+        err := db.WithTransaction(ctx, func(ctx context.Context) error {
+            if err := deleteMatched(ctx, c, db, p.Id); err != nil {
+                return err
+            }
+            return publishEvent(ctx, getEventPublisher(c), p.Id, PaymentDeleted{Payment: *p})
+        })
+        switch err {
+        case nil:
+        case db_service.ErrVersionMismatch:
+            return nil, gin.H{"message": "Payment has been modified"}, http.StatusPreconditionFailed
+        case db_service.ErrNotFound:
+            return nil, gin.H{"message": "Payment not found"}, http.StatusNotFound
+        default:
             log.Println("DeleteDocument error:", err)
             return nil, gin.H{"message": "Failed to delete payment"}, http.StatusInternalServerError
         }
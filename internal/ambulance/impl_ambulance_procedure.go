@@ -5,13 +5,11 @@ import (
     "log"
     "net/http"
     "time"
-    "bytes"
-    "encoding/json"
-    "io"
 
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
     "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/kafka"
 )
 
 // implProcedureAPI implements the ProcedureManagementAPI interface.
@@ -42,7 +40,7 @@ func withProcedureByID(
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    proc, err := db.FindDocument(ctx, id)
+    proc, version, err := db.FindVersionedDocument(ctx, id)
     if err != nil {
         if err == db_service.ErrNotFound {
             c.JSON(http.StatusNotFound, gin.H{"message": "Procedure not found"})
@@ -52,19 +50,64 @@ func withProcedureByID(
         }
         return
     }
+    if !ifMatchSatisfied(c, version) {
+        c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Procedure has been modified"})
+        return
+    }
 
+    before := *proc
     updated, result, status := fn(c, proc)
     if updated != nil {
-        if err := db.UpdateDocument(ctx, id, updated); err != nil {
+        version, err = storeProcedure(ctx, c, &before, updated, version)
+        switch err {
+        case nil:
+        case db_service.ErrVersionMismatch:
+            c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Procedure has been modified"})
+            return
+        case db_service.ErrNotFound:
+            c.JSON(http.StatusNotFound, gin.H{"message": "Procedure not found"})
+            return
+        default:
             log.Println("UpdateDocument error:", err)
             c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update procedure"})
             return
         }
     }
+    if _, ok := result.(*Procedure); ok && status < http.StatusMultipleChoices {
+        c.Header("ETag", etag(version))
+    }
     c.JSON(status, result)
 }
 
+// storeProcedure replaces version of the stored procedure before with updated and publishes
+// the change. It returns the new version, or db_service.ErrVersionMismatch if before was modified.
+func storeProcedure(ctx context.Context, c *gin.Context, before *Procedure, updated *Procedure, version int64) (int64, error) {
+    return saveProcedure(ctx, getProcedureDB(c), getEventPublisher(c), before, updated, version)
+}
+
+// saveProcedure is storeProcedure for callers outside of a request.
+func saveProcedure(
+    ctx context.Context,
+    db db_service.DbService[Procedure],
+    publisher kafka.EventPublisher,
+    before *Procedure,
+    updated *Procedure,
+    version int64,
+) (int64, error) {
+    err := db.WithTransaction(ctx, func(ctx context.Context) error {
+        var err error
+        if version, err = db.UpdateVersionedDocument(ctx, before.Id, updated, version); err != nil {
+            return err
+        }
+        return publishUpdated(ctx, publisher, before.Id, before, updated, func(changes map[string]FieldChange) kafka.Payload {
+            return ProcedureUpdated{Procedure: *updated, Changes: changes}
+        })
+    })
+    return version, err
+}
+
 // CreateProcedure implements POST /api/procedures
+// The procedure is created as submitted, a SubmissionStarter starts its process afterwards.
 func (o *implProcedureAPI) CreateProcedure(c *gin.Context) {
     var p Procedure
     if err := c.ShouldBindJSON(&p); err != nil {
@@ -74,12 +117,29 @@ func (o *implProcedureAPI) CreateProcedure(c *gin.Context) {
     if p.Id == "" {
         p.Id = uuid.NewString()
     }
+    p.ProcessInstanceId, p.WorkflowStatus = "", WorkflowSubmitted
+    p.Timestamp = utcTimestamp(p.Timestamp)
 
     db := getProcedureDB(c)
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    if err := db.CreateDocument(ctx, p.Id, &p); err != nil {
+    if invalid, err := checkReference(ctx, getDB(c), "ambulance_id", p.AmbulanceId, "ambulance"); err != nil {
+        log.Println("FindDocument error:", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create procedure"})
+        return
+    } else if invalid != nil {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Invalid reference", "errors": []FieldError{*invalid}})
+        return
+    }
+
+    err := db.WithTransaction(ctx, func(ctx context.Context) error {
+        if err := db.CreateDocument(ctx, p.Id, &p); err != nil {
+            return err
+        }
+        return publishEvent(ctx, getEventPublisher(c), p.Id, ProcedureCreated{Procedure: p})
+    })
+    if err != nil {
         switch err {
         case db_service.ErrConflict:
             c.JSON(http.StatusConflict, gin.H{"message": "Procedure already exists"})
@@ -90,54 +150,7 @@ func (o *implProcedureAPI) CreateProcedure(c *gin.Context) {
         return
     }
 
-    // ✅ Start Camunda BPMN process after saving
-    go func(p Procedure) {
-        client := &http.Client{Timeout: 5 * time.Second}
-
-        procedureJSON, err := json.Marshal(p)
-        if err != nil {
-            log.Println("Failed to marshal procedure to JSON:", err)
-            return
-        }
-
-        payload := map[string]interface{}{
-            "variables": map[string]interface{}{
-                "procedureData": map[string]interface{}{
-                    "value": string(procedureJSON),
-                    "type":  "Json",
-                },
-            },
-            "businessKey": p.Id,
-        }
-
-        body, err := json.Marshal(payload)
-        if err != nil {
-            log.Println("Failed to marshal Camunda payload:", err)
-            return
-        }
-
-        req, err := http.NewRequest("POST", "http://localhost:8082/engine-rest/process-definition/key/SubmitMedicalPerformance/start", bytes.NewBuffer(body))
-        if err != nil {
-            log.Println("Failed to create Camunda request:", err)
-            return
-        }
-        req.Header.Set("Content-Type", "application/json")
-
-        resp, err := client.Do(req)
-        if err != nil {
-            log.Println("Failed to start Camunda process:", err)
-            return
-        }
-        defer resp.Body.Close()
-
-        bodyBytes, _ := io.ReadAll(resp.Body)
-        if resp.StatusCode >= 300 {
-            log.Printf("Camunda returned error: %s\n", string(bodyBytes))
-        } else {
-            log.Println("Camunda process started for procedure", p.Id)
-        }
-    }(p)
-
+    c.Header("ETag", etag(db_service.InitialVersion))
     c.JSON(http.StatusCreated, p)
 }
 
@@ -148,62 +161,70 @@ func (o *implProcedureAPI) GetProcedureById(c *gin.Context) {
     })
 }
 
+// GetProcedures implements GET /api/procedures
 func (o *implProcedureAPI) GetProcedures(c *gin.Context) {
-    db := getProcedureDB(c)
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-
-    ambulanceID := c.Query("ambulance_id")
-
-    var (
-        procedures any
-        err        error
-    )
-
-    if ambulanceID != "" {
-        procedures, err = db.FindDocumentsByField(ctx, "ambulance_id", ambulanceID)
-    } else {
-        procedures, err = db.ListDocuments(ctx)
-    }
-
+    opts, err := parseListOptions(c)
     if err != nil {
-        log.Println("Error retrieving procedures:", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve procedures"})
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid list parameters", "error": err.Error()})
         return
     }
-
-    c.JSON(http.StatusOK, procedures)
+    respondWithPage(c, getProcedureDB(c), opts, "procedures")
 }
 
 // UpdateProcedure implements PUT /api/procedures/:procedureId
+// The request body replaces the stored procedure; omitted fields are cleared.
 func (o *implProcedureAPI) UpdateProcedure(c *gin.Context) {
     withProcedureByID(c, func(_ *gin.Context, existing *Procedure) (*Procedure, interface{}, int) {
-        var upd Procedure
-        if err := c.ShouldBindJSON(&upd); err != nil {
+        var replacement Procedure
+        if err := c.ShouldBindJSON(&replacement); err != nil {
             return nil, gin.H{"message": "Invalid request", "error": err.Error()}, http.StatusBadRequest
         }
-        if upd.Patient != "" {
-            existing.Patient = upd.Patient
-        }
-        if upd.VisitType != "" {
-            existing.VisitType = upd.VisitType
-        }
-        if upd.Price != 0 {
-            existing.Price = upd.Price
-        }
-        if upd.Payer != "" {
-            existing.Payer = upd.Payer
-        }
-        if upd.AmbulanceId != "" {
-            existing.AmbulanceId = upd.AmbulanceId
-        }
-        if upd.Timestamp != "" {
-            existing.Timestamp = upd.Timestamp
+        return replaceProcedure(c, existing, &replacement)
+    })
+}
+
+// PatchProcedure implements PATCH /api/procedures/:procedureId
+func (o *implProcedureAPI) PatchProcedure(c *gin.Context) {
+    withProcedureByID(c, func(_ *gin.Context, existing *Procedure) (*Procedure, interface{}, int) {
+        patched, err := patchDocument(c, existing)
+        if err != nil {
+            result, status := patchFailure(err)
+            return nil, result, status
         }
-        return existing, existing, http.StatusOK
+        return replaceProcedure(c, existing, patched)
     })
 }
 
+// replaceProcedure validates replacement as the new state of existing.
+func replaceProcedure(c *gin.Context, existing *Procedure, replacement *Procedure) (*Procedure, interface{}, int) {
+    if replacement.Id != "" && replacement.Id != existing.Id {
+        return nil, gin.H{"message": "Procedure id cannot be changed"}, http.StatusBadRequest
+    }
+    replacement.Id = existing.Id
+    replacement.ProcessInstanceId, replacement.WorkflowStatus = existing.ProcessInstanceId, existing.WorkflowStatus
+    replacement.Timestamp = utcTimestamp(replacement.Timestamp)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+    if invalid, err := checkReference(ctx, getDB(c), "ambulance_id", replacement.AmbulanceId, "ambulance"); err != nil {
+        log.Println("FindDocument error:", err)
+        return nil, gin.H{"message": "Failed to update procedure"}, http.StatusInternalServerError
+    } else if invalid != nil {
+        return nil, gin.H{"message": "Invalid reference", "errors": []FieldError{*invalid}}, http.StatusUnprocessableEntity
+    }
+    return replacement, replacement, http.StatusOK
+}
+
+// utcTimestamp converts an RFC 3339 timestamp to UTC, so that stored timestamps order as strings.
+// Other values are returned unchanged.
+func utcTimestamp(value string) string {
+    parsed, err := time.Parse(time.RFC3339Nano, value)
+    if err != nil {
+        return value
+    }
+    return parsed.UTC().Format(time.RFC3339Nano)
+}
+
 // DeleteProcedure implements DELETE /api/procedures/:procedureId
 func (o *implProcedureAPI) DeleteProcedure(c *gin.Context) {
     withProcedureByID(c, func(_ *gin.Context, p *Procedure) (*Procedure, interface{}, int) {
@@ -211,7 +232,19 @@ func (o *implProcedureAPI) DeleteProcedure(c *gin.Context) {
         ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
         defer cancel()
 
-        if err := db.DeleteDocument(ctx, p.Id); err != nil {
+        err := db.WithTransaction(ctx, func(ctx context.Context) error {
+            if err := deleteMatched(ctx, c, db, p.Id); err != nil {
+                return err
+            }
+            return publishEvent(ctx, getEventPublisher(c), p.Id, ProcedureDeleted{Procedure: *p})
+        })
+        switch err {
+        case nil:
+        case db_service.ErrVersionMismatch:
+            return nil, gin.H{"message": "Procedure has been modified"}, http.StatusPreconditionFailed
+        case db_service.ErrNotFound:
+            return nil, gin.H{"message": "Procedure not found"}, http.StatusNotFound
+        default:
             log.Println("DeleteDocument error:", err)
             return nil, gin.H{"message": "Failed to delete procedure"}, http.StatusInternalServerError
         }
@@ -2,6 +2,7 @@ package ambulance
 
 import (
     "context"
+    "encoding/json"
     "net/http"
     "net/http/httptest"
     "strings"
@@ -10,7 +11,9 @@ import (
     "github.com/gin-gonic/gin"
     "github.com/stretchr/testify/mock"
     "github.com/stretchr/testify/suite"
+    "github.com/wac-project/wac-api/api"
     "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/kafka"
 )
 
 // DbServiceMock is a testify mock for db_service.DbService[Ambulance]
@@ -28,6 +31,46 @@ func (m *DbServiceMock[DocType]) FindDocument(ctx context.Context, id string) (*
     return args.Get(0).(*DocType), args.Error(1)
 }
 
+func (m *DbServiceMock[DocType]) FindVersionedDocument(ctx context.Context, id string) (*DocType, int64, error) {
+    args := m.Called(ctx, id)
+    return args.Get(0).(*DocType), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *DbServiceMock[DocType]) UpdateVersionedDocument(ctx context.Context, id string, document *DocType, version int64) (int64, error) {
+    args := m.Called(ctx, id, document, version)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *DbServiceMock[DocType]) ListDocuments(ctx context.Context) ([]DocType, error) {
+    args := m.Called(ctx)
+    return args.Get(0).([]DocType), args.Error(1)
+}
+
+func (m *DbServiceMock[DocType]) ListDocumentsPage(ctx context.Context, opts db_service.ListOptions) (*db_service.Page[DocType], error) {
+    args := m.Called(ctx, opts)
+    return args.Get(0).(*db_service.Page[DocType]), args.Error(1)
+}
+
+func (m *DbServiceMock[DocType]) FindDocuments(ctx context.Context, filter db_service.Filter) ([]DocType, error) {
+    args := m.Called(ctx, filter)
+    return args.Get(0).([]DocType), args.Error(1)
+}
+
+func (m *DbServiceMock[DocType]) Aggregate(ctx context.Context, aggregation db_service.Aggregation) ([]db_service.AggregateGroup, error) {
+    args := m.Called(ctx, aggregation)
+    return args.Get(0).([]db_service.AggregateGroup), args.Error(1)
+}
+
+func (m *DbServiceMock[DocType]) DeleteDocuments(ctx context.Context, filter db_service.Filter) (int64, error) {
+    args := m.Called(ctx, filter)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+// WithTransaction runs fn directly, the mock has no transactional state.
+func (m *DbServiceMock[DocType]) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+    return fn(ctx)
+}
+
 func (m *DbServiceMock[DocType]) UpdateDocument(ctx context.Context, id string, document *DocType) error {
     args := m.Called(ctx, id, document)
     return args.Error(0)
@@ -38,6 +81,11 @@ func (m *DbServiceMock[DocType]) DeleteDocument(ctx context.Context, id string)
     return args.Error(0)
 }
 
+func (m *DbServiceMock[DocType]) DeleteVersionedDocument(ctx context.Context, id string, version int64) error {
+    args := m.Called(ctx, id, version)
+    return args.Error(0)
+}
+
 func (m *DbServiceMock[DocType]) Disconnect(ctx context.Context) error {
     args := m.Called(ctx)
     return args.Error(0)
@@ -50,6 +98,8 @@ var _ db_service.DbService[Ambulance] = (*DbServiceMock[Ambulance])(nil)
 type AmbulanceSuite struct {
     suite.Suite
     dbServiceMock *DbServiceMock[Ambulance]
+    events        *kafka.MemoryPublisher
+    publisher     kafka.EventPublisher
 }
 
 func TestAmbulanceSuite(t *testing.T) {
@@ -57,10 +107,14 @@ func TestAmbulanceSuite(t *testing.T) {
 }
 
 func (suite *AmbulanceSuite) SetupTest() {
+    registry, err := kafka.NewSchemaRegistry(api.EventSchemas())
+    suite.Require().NoError(err)
+    suite.events = kafka.NewMemoryPublisher()
+    suite.publisher = kafka.NewValidatingPublisher(registry, suite.events)
     suite.dbServiceMock = &DbServiceMock[Ambulance]{}
-    // Stub FindDocument to return a sample Ambulance
+    // Stub FindVersionedDocument to return a sample Ambulance
     suite.dbServiceMock.
-        On("FindDocument", mock.Anything, "test-ambulance").
+        On("FindVersionedDocument", mock.Anything, "test-ambulance").
         Return(
             &Ambulance{
                 Id:         "test-ambulance",
@@ -70,8 +124,15 @@ func (suite *AmbulanceSuite) SetupTest() {
                 Capacity:   5,
                 Status:     "active",
             },
+            int64(3),
             nil,
         )
+    suite.dbServiceMock.
+        On("FindDocument", mock.Anything, "test-ambulance").
+        Return(&Ambulance{Id: "test-ambulance"}, nil)
+    suite.dbServiceMock.
+        On("FindDocument", mock.Anything, "missing-ambulance").
+        Return((*Ambulance)(nil), db_service.ErrNotFound)
 }
 
 func (suite *AmbulanceSuite) Test_CreateAmbulance_CallsCreateDocument() {
@@ -83,7 +144,8 @@ func (suite *AmbulanceSuite) Test_CreateAmbulance_CallsCreateDocument() {
     gin.SetMode(gin.TestMode)
     recorder := httptest.NewRecorder()
     ctx, _ := gin.CreateTestContext(recorder)
-    ctx.Set("db_service", suite.dbServiceMock)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
     ctx.Request = httptest.NewRequest("POST", "/api/ambulances", strings.NewReader(payload))
     ctx.Request.Header.Set("Content-Type", "application/json")
 
@@ -92,13 +154,18 @@ func (suite *AmbulanceSuite) Test_CreateAmbulance_CallsCreateDocument() {
 
     suite.dbServiceMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
     suite.Equal(http.StatusCreated, recorder.Code)
+    events := suite.events.Events()
+    suite.Require().Len(events, 1)
+    suite.Equal("ambulance_created", events[0].Type)
+    suite.Equal(kafka.SpecVersion, events[0].SpecVersion)
 }
 
 func (suite *AmbulanceSuite) Test_GetAmbulanceById_ReturnsOK() {
     gin.SetMode(gin.TestMode)
     recorder := httptest.NewRecorder()
     ctx, _ := gin.CreateTestContext(recorder)
-    ctx.Set("db_service", suite.dbServiceMock)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
     ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
     ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance", nil)
 
@@ -106,17 +173,61 @@ func (suite *AmbulanceSuite) Test_GetAmbulanceById_ReturnsOK() {
     sut.GetAmbulanceById(ctx)
 
     suite.Equal(http.StatusOK, recorder.Code)
+    suite.Equal(`"3"`, recorder.Header().Get("ETag"))
+}
+
+func (suite *AmbulanceSuite) Test_UpdateAmbulance_StaleIfMatch_ReturnsPreconditionFailed() {
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("PUT", "/api/ambulances/test-ambulance", strings.NewReader(`{"name":"Renamed"}`))
+    ctx.Request.Header.Set("Content-Type", "application/json")
+    ctx.Request.Header.Set("If-Match", `"2"`)
+
+    sut := implAmbulanceAPI{}
+    sut.UpdateAmbulance(ctx)
+
+    suite.Equal(http.StatusPreconditionFailed, recorder.Code)
+    suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateVersionedDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulanceSuite) Test_UpdateAmbulance_ConcurrentUpdate_ReturnsPreconditionFailed() {
+    suite.dbServiceMock.
+        On("UpdateVersionedDocument", mock.Anything, "test-ambulance", mock.Anything, int64(3)).
+        Return(int64(0), db_service.ErrVersionMismatch)
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("PUT", "/api/ambulances/test-ambulance", strings.NewReader(`{"name":"Renamed"}`))
+    ctx.Request.Header.Set("Content-Type", "application/json")
+    ctx.Request.Header.Set("If-Match", `"3"`)
+
+    sut := implAmbulanceAPI{}
+    sut.UpdateAmbulance(ctx)
+
+    suite.Equal(http.StatusPreconditionFailed, recorder.Code)
 }
 
 func (suite *AmbulanceSuite) Test_DeleteAmbulance_CallsDeleteDocument() {
     suite.dbServiceMock.
         On("DeleteDocument", mock.Anything, "test-ambulance").
         Return(nil)
+    procedures, payments := suite.seedProceduresAndPayments()
 
     gin.SetMode(gin.TestMode)
     recorder := httptest.NewRecorder()
     ctx, _ := gin.CreateTestContext(recorder)
-    ctx.Set("db_service", suite.dbServiceMock)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
     ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
     ctx.Request = httptest.NewRequest("DELETE", "/api/ambulances/test-ambulance", nil)
 
@@ -125,13 +236,198 @@ func (suite *AmbulanceSuite) Test_DeleteAmbulance_CallsDeleteDocument() {
 
     suite.dbServiceMock.AssertCalled(suite.T(), "DeleteDocument", mock.Anything, "test-ambulance")
     suite.Equal(http.StatusNoContent, recorder.Code)
+
+    remainingProcedures, _ := procedures.ListDocuments(context.Background())
+    suite.Len(remainingProcedures, 1)
+    suite.Equal("p4", remainingProcedures[0].Id)
+    remainingPayments, _ := payments.ListDocuments(context.Background())
+    suite.Len(remainingPayments, 1)
+    suite.Equal("pay3", remainingPayments[0].Id)
+
+    var deleted []string
+    for _, event := range suite.events.Events() {
+        deleted = append(deleted, event.Type+":"+event.Subject)
+    }
+    suite.Equal([]string{
+        "payment_deleted:pay1", "payment_deleted:pay2",
+        "procedure_deleted:p1", "procedure_deleted:p2", "procedure_deleted:p3",
+        "ambulance_deleted:test-ambulance",
+    }, deleted)
+}
+
+func (suite *AmbulanceSuite) Test_DeleteAmbulance_ConcurrentUpdate_ReturnsPreconditionFailed() {
+    suite.dbServiceMock.
+        On("DeleteVersionedDocument", mock.Anything, "test-ambulance", int64(3)).
+        Return(db_service.ErrVersionMismatch)
+    procedures, payments := suite.seedProceduresAndPayments()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("DELETE", "/api/ambulances/test-ambulance", nil)
+    ctx.Request.Header.Set("If-Match", `"3"`)
+
+    sut := implAmbulanceAPI{}
+    sut.DeleteAmbulance(ctx)
+
+    suite.Equal(http.StatusPreconditionFailed, recorder.Code)
+    suite.dbServiceMock.AssertNotCalled(suite.T(), "DeleteDocument", mock.Anything, mock.Anything)
+    remainingProcedures, _ := procedures.ListDocuments(context.Background())
+    suite.Len(remainingProcedures, 4)
+    suite.Empty(suite.events.Events())
+}
+
+func (suite *AmbulanceSuite) Test_DeleteAmbulance_RestrictWithProcedures_ReturnsConflict() {
+    procedures, payments := suite.seedProceduresAndPayments()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("DELETE", "/api/ambulances/test-ambulance?mode=restrict", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.DeleteAmbulance(ctx)
+
+    suite.Equal(http.StatusConflict, recorder.Code)
+    suite.dbServiceMock.AssertNotCalled(suite.T(), "DeleteDocument", mock.Anything, mock.Anything)
+    remainingProcedures, _ := procedures.ListDocuments(context.Background())
+    suite.Len(remainingProcedures, 4)
+}
+
+// seedProceduresAndPayments creates procedures of test-ambulance (p1-p3) and of another ambulance (p4) with payments.
+func (suite *AmbulanceSuite) seedProceduresAndPayments() (db_service.DbService[Procedure], db_service.DbService[Payment]) {
+    procedures := db_service.NewMemoryService[Procedure]()
+    payments := db_service.NewMemoryService[Payment]()
+    for _, p := range []Procedure{
+        {Id: "p1", AmbulanceId: "test-ambulance", VisitType: "checkup", Payer: "VSZP", Price: 100, Timestamp: "2026-01-10T10:00:00Z"},
+        {Id: "p2", AmbulanceId: "test-ambulance", VisitType: "emergency", Payer: "VSZP", Price: 250.5, Timestamp: "2026-01-20T10:00:00Z"},
+        {Id: "p3", AmbulanceId: "test-ambulance", VisitType: "checkup", Payer: "Dovera", Price: 80, Timestamp: "2025-12-31T10:00:00Z"},
+        {Id: "p4", AmbulanceId: "other-ambulance", VisitType: "checkup", Payer: "VSZP", Price: 999, Timestamp: "2026-01-15T10:00:00Z"},
+    } {
+        suite.Require().NoError(procedures.CreateDocument(context.Background(), p.Id, &p))
+    }
+    for _, p := range []Payment{
+        {Id: "pay1", ProcedureId: "p1", Amount: 100},
+        {Id: "pay2", ProcedureId: "p3", Amount: 80},
+        {Id: "pay3", ProcedureId: "p4", Amount: 999},
+    } {
+        suite.Require().NoError(payments.CreateDocument(context.Background(), p.Id, &p))
+    }
+    return procedures, payments
 }
 
 func (suite *AmbulanceSuite) Test_GetAmbulanceSummary_ReturnsSummary() {
+    procedures, payments := suite.seedProceduresAndPayments()
+
     gin.SetMode(gin.TestMode)
     recorder := httptest.NewRecorder()
     ctx, _ := gin.CreateTestContext(recorder)
-    ctx.Set("db_service", suite.dbServiceMock)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance/summary?from=2026-01-01", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetAmbulanceSummary(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    var summary GetAmbulanceSummary200Response
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &summary))
+    suite.Equal(int64(2), summary.ProcedureCount)
+    suite.Equal(350.5, summary.TotalCost)
+    suite.Equal(100.0, summary.TotalPaid)
+    suite.Equal(250.5, summary.OutstandingBalance)
+    suite.Equal(map[string]int64{"checkup": 1, "emergency": 1}, summary.VisitTypeCounts)
+    suite.Equal(map[string]int64{"VSZP": 2}, summary.PayerCounts)
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulanceSummary_UsesCostProjection() {
+    procedures, payments := suite.seedProceduresAndPayments()
+    costs := db_service.NewMemoryService[AmbulanceCosts]()
+    router := kafka.NewRouter()
+    RegisterCostProjection(router, costs)
+
+    seeded, err := procedures.ListDocuments(context.Background())
+    suite.Require().NoError(err)
+    moved := Procedure{Id: "p4", AmbulanceId: "test-ambulance", VisitType: "checkup", Payer: "VSZP", Price: 999}
+    payloads := []kafka.Payload{}
+    for _, p := range seeded {
+        payloads = append(payloads, ProcedureCreated{Procedure: p})
+    }
+    payloads = append(payloads,
+        ProcedureCreated{Procedure: seeded[1]}, // redelivered
+        ProcedureUpdated{Procedure: moved, Changes: map[string]FieldChange{"ambulance_id": {Old: "other-ambulance", New: "test-ambulance"}}},
+        ProcedureDeleted{Procedure: seeded[2]},
+    )
+    for _, payload := range payloads {
+        event, err := kafka.NewEvent(eventSource, "test", payload)
+        suite.Require().NoError(err)
+        suite.Require().NoError(router.Dispatch(context.Background(), event))
+    }
+
+    // the collection went through the same changes, payments are totalled from it
+    suite.Require().NoError(procedures.UpdateDocument(context.Background(), moved.Id, &moved))
+    suite.Require().NoError(procedures.DeleteDocument(context.Background(), seeded[2].Id))
+
+    other, err := costs.FindDocument(context.Background(), "other-ambulance")
+    suite.Require().NoError(err)
+    suite.Zero(other.ProcedureCount)
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Set("db_service_ambulance_costs", costs)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance/summary", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetAmbulanceSummary(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    var summary GetAmbulanceSummary200Response
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &summary))
+    suite.Equal(int64(3), summary.ProcedureCount)
+    suite.Equal(1349.5, summary.TotalCost)
+    suite.Equal(1099.0, summary.TotalPaid)
+    suite.Equal(map[string]int64{"checkup": 2, "emergency": 1}, summary.VisitTypeCounts)
+    suite.Equal(map[string]int64{"VSZP": 3}, summary.PayerCounts)
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulanceSummary_ProjectionLagsPayments() {
+    procedures, payments := suite.seedProceduresAndPayments()
+    costs := db_service.NewMemoryService[AmbulanceCosts]()
+    router := kafka.NewRouter()
+    RegisterCostProjection(router, costs)
+    seeded, err := procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    event, err := kafka.NewEvent(eventSource, seeded.Id, ProcedureCreated{Procedure: *seeded})
+    suite.Require().NoError(err)
+    suite.Require().NoError(router.Dispatch(context.Background(), event))
+
+    // p1 and p3 are billed but their events have not been projected yet
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Set("db_service_ambulance_costs", costs)
     ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
     ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance/summary", nil)
 
@@ -139,4 +435,321 @@ func (suite *AmbulanceSuite) Test_GetAmbulanceSummary_ReturnsSummary() {
     sut.GetAmbulanceSummary(ctx)
 
     suite.Equal(http.StatusOK, recorder.Code)
+    var summary GetAmbulanceSummary200Response
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &summary))
+    suite.Equal(int64(1), summary.ProcedureCount)
+    suite.Equal(250.5, summary.TotalCost)
+    suite.Zero(summary.TotalPaid)
+    suite.Equal(250.5, summary.OutstandingBalance)
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulanceSummary_RejectsInvalidDate() {
+    // timestamps with an offset would not order correctly against the stored UTC timestamps
+    for _, query := range []string{"to=yesterday", "from=2026-01-10T10:00:00%2B02:00"} {
+        gin.SetMode(gin.TestMode)
+        recorder := httptest.NewRecorder()
+        ctx, _ := gin.CreateTestContext(recorder)
+        ctx.Set("db_service_ambulance", suite.dbServiceMock)
+        ctx.Set("event_publisher", suite.publisher)
+        ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+        ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance/summary?"+query, nil)
+
+        sut := implAmbulanceAPI{}
+        sut.GetAmbulanceSummary(ctx)
+
+        suite.Equal(http.StatusBadRequest, recorder.Code, query)
+    }
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulances_ReturnsPageWithLinkHeader() {
+    suite.dbServiceMock.
+        On("ListDocumentsPage", mock.Anything, db_service.ListOptions{
+            Limit: 1,
+            Sort:  []db_service.SortField{{Field: "name", Descending: true}},
+        }).
+        Return(&db_service.Page[Ambulance]{
+            Items:      []Ambulance{{Id: "test-ambulance"}},
+            Total:      2,
+            Limit:      1,
+            NextCursor: "next-token",
+        }, nil)
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances?limit=1&sort=-name", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetAmbulances(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    suite.Equal("2", recorder.Header().Get("X-Total-Count"))
+    suite.Equal("next-token", recorder.Header().Get("X-Next-Cursor"))
+    suite.Equal(`</api/ambulances?cursor=next-token&limit=1&sort=-name>; rel="next"`, recorder.Header().Get("Link"))
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulances_RejectsInvalidLimit() {
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances?limit=-1", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetAmbulances(ctx)
+
+    suite.Equal(http.StatusBadRequest, recorder.Code)
+    suite.dbServiceMock.AssertNotCalled(suite.T(), "ListDocumentsPage", mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulanceSuite) Test_GetAmbulances_PassesQueryFilters() {
+    filter := db_service.And(
+        db_service.Gte("capacity", "2"),
+        db_service.In("status", "active", "idle"),
+    )
+    suite.dbServiceMock.
+        On("ListDocumentsPage", mock.Anything, db_service.ListOptions{Filter: &filter}).
+        Return(&db_service.Page[Ambulance]{Items: []Ambulance{}}, nil)
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances?status=active&status=idle&capacity[gte]=2", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetAmbulances(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    suite.dbServiceMock.AssertCalled(suite.T(), "ListDocumentsPage", mock.Anything, db_service.ListOptions{Filter: &filter})
+}
+
+func (suite *AmbulanceSuite) Test_GetProceduresByAmbulance_ReturnsFilteredProcedures() {
+    procedures := db_service.NewMemoryService[Procedure]()
+    for _, p := range []Procedure{
+        {Id: "p1", AmbulanceId: "test-ambulance", Price: 100},
+        {Id: "p2", AmbulanceId: "test-ambulance", Price: 300},
+        {Id: "p3", AmbulanceId: "test-ambulance", Price: 200},
+        {Id: "p4", AmbulanceId: "other-ambulance", Price: 500},
+    } {
+        suite.Require().NoError(procedures.CreateDocument(context.Background(), p.Id, &p))
+    }
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances/test-ambulance/procedures?price[gte]=150&sort=-price&limit=1", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetProceduresByAmbulance(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    suite.Equal("2", recorder.Header().Get("X-Total-Count"))
+    var result []Procedure
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &result))
+    suite.Require().Len(result, 1)
+    suite.Equal("p2", result[0].Id)
+}
+
+func (suite *AmbulanceSuite) Test_GetProceduresByAmbulance_UnknownAmbulance_ReturnsNotFound() {
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "missing-ambulance"}}
+    ctx.Request = httptest.NewRequest("GET", "/api/ambulances/missing-ambulance/procedures", nil)
+
+    sut := implAmbulanceAPI{}
+    sut.GetProceduresByAmbulance(ctx)
+
+    suite.Equal(http.StatusNotFound, recorder.Code)
+}
+
+func (suite *AmbulanceSuite) Test_CreateProcedure_UnknownAmbulance_ReturnsUnprocessableEntity() {
+    procedures := db_service.NewMemoryService[Procedure]()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Request = httptest.NewRequest("POST", "/api/procedures", strings.NewReader(`{"patient":"Jan","ambulance_id":"missing-ambulance"}`))
+    ctx.Request.Header.Set("Content-Type", "application/json")
+
+    sut := implProcedureAPI{}
+    sut.CreateProcedure(ctx)
+
+    suite.Equal(http.StatusUnprocessableEntity, recorder.Code)
+    suite.Contains(recorder.Body.String(), `"field":"ambulance_id"`)
+    stored, _ := procedures.ListDocuments(context.Background())
+    suite.Empty(stored)
+}
+
+func (suite *AmbulanceSuite) Test_UpdatePayment_UnknownProcedure_ReturnsUnprocessableEntity() {
+    procedures, payments := suite.seedProceduresAndPayments()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Set("db_service_payment", payments)
+    ctx.Params = []gin.Param{{Key: "paymentId", Value: "pay1"}}
+    ctx.Request = httptest.NewRequest("PUT", "/api/payments/pay1", strings.NewReader(`{"procedure_id":"missing-procedure"}`))
+    ctx.Request.Header.Set("Content-Type", "application/json")
+
+    sut := implPaymentAPI{}
+    sut.UpdatePayment(ctx)
+
+    suite.Equal(http.StatusUnprocessableEntity, recorder.Code)
+    suite.Contains(recorder.Body.String(), `"field":"procedure_id"`)
+    stored, _ := payments.FindDocument(context.Background(), "pay1")
+    suite.Equal("p1", stored.ProcedureId)
+}
+
+func (suite *AmbulanceSuite) Test_PatchProcedure_MergePatchClearsFields() {
+    procedures, _ := suite.seedProceduresAndPayments()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Params = []gin.Param{{Key: "procedureId", Value: "p1"}}
+    ctx.Request = httptest.NewRequest("PATCH", "/api/procedures/p1", strings.NewReader(`{"payer":null,"price":0,"name":"X-ray"}`))
+    ctx.Request.Header.Set("Content-Type", "application/merge-patch+json")
+
+    sut := implProcedureAPI{}
+    sut.PatchProcedure(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    suite.Equal(`"2"`, recorder.Header().Get("ETag"))
+    stored, _ := procedures.FindDocument(context.Background(), "p1")
+    suite.Equal("", stored.Payer)
+    suite.Equal(0.0, stored.Price)
+    suite.Equal("X-ray", stored.Name)
+    suite.Equal("checkup", stored.VisitType)
+
+    events := suite.events.Events()
+    suite.Require().Len(events, 1)
+    suite.Equal("procedure_updated", events[0].Type)
+    suite.Equal("p1", events[0].Subject)
+    suite.Equal("urn:wac-api:events:procedure_updated:v2", events[0].DataSchema)
+    var updated ProcedureUpdated
+    suite.Require().NoError(events[0].DecodeData(&updated))
+    suite.Equal("X-ray", updated.Procedure.Name)
+    suite.Equal(map[string]FieldChange{
+        "name":  {Old: "", New: "X-ray"},
+        "payer": {Old: "VSZP", New: ""},
+        "price": {Old: 100.0, New: 0.0},
+    }, updated.Changes)
+}
+
+func (suite *AmbulanceSuite) Test_ProcedureEvents_WorkflowOnlyInSchemaV2() {
+    registry, err := kafka.NewSchemaRegistry(api.EventSchemas())
+    suite.Require().NoError(err)
+    event, err := kafka.NewEvent(eventSource, "p1", ProcedureCreated{Procedure: Procedure{
+        Id:                "p1",
+        Patient:           "pat1",
+        VisitType:         "checkup",
+        ProcessInstanceId: "pi1",
+        WorkflowStatus:    WorkflowSubmitted,
+    }})
+    suite.Require().NoError(err)
+    suite.Equal("urn:wac-api:events:procedure_created:v2", event.DataSchema)
+    suite.NoError(registry.Validate(event))
+
+    // the published v1 schema stays closed to the workflow fields
+    event.DataSchema = kafka.SchemaURI("procedure_created", 1)
+    suite.ErrorIs(registry.Validate(event), kafka.ErrInvalidEvent)
+}
+
+func (suite *AmbulanceSuite) Test_PatchProcedure_StoresEventInOutboxWithinTransaction() {
+    procedures, _ := suite.seedProceduresAndPayments()
+    outbox := db_service.NewMemoryService[kafka.OutboxMessage]()
+
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    // the outbox refuses messages stored outside a transaction
+    ctx.Set("event_publisher", kafka.NewOutboxPublisher(db_service.NewOutboxStore(outbox)))
+    ctx.Set("db_service_procedure", procedures)
+    ctx.Params = []gin.Param{{Key: "procedureId", Value: "p1"}}
+    ctx.Request = httptest.NewRequest("PATCH", "/api/procedures/p1", strings.NewReader(`{"name":"X-ray"}`))
+    ctx.Request.Header.Set("Content-Type", "application/merge-patch+json")
+
+    sut := implProcedureAPI{}
+    sut.PatchProcedure(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    messages, _ := outbox.ListDocuments(context.Background())
+    suite.Require().Len(messages, 1)
+    event, err := kafka.ParseStructured([]byte(messages[0].Value))
+    suite.Require().NoError(err)
+    suite.Equal("procedure_updated", event.Type)
+    suite.Equal("p1", event.Subject)
+}
+
+func (suite *AmbulanceSuite) Test_PatchAmbulance_JsonPatchSetsZeroCapacity() {
+    suite.dbServiceMock.
+        On("UpdateVersionedDocument", mock.Anything, "test-ambulance", mock.Anything, int64(3)).
+        Return(int64(4), nil)
+
+    payload := `[{"op":"test","path":"/capacity","value":5},{"op":"replace","path":"/capacity","value":0},{"op":"remove","path":"/status"}]`
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("db_service_ambulance", suite.dbServiceMock)
+    ctx.Set("event_publisher", suite.publisher)
+    ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+    ctx.Request = httptest.NewRequest("PATCH", "/api/ambulances/test-ambulance", strings.NewReader(payload))
+    ctx.Request.Header.Set("Content-Type", "application/json-patch+json")
+
+    sut := implAmbulanceAPI{}
+    sut.PatchAmbulance(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    suite.dbServiceMock.AssertCalled(suite.T(), "UpdateVersionedDocument", mock.Anything, "test-ambulance",
+        mock.MatchedBy(func(a *Ambulance) bool {
+            return a.Capacity == 0 && a.Status == "" && a.Name == "TestName"
+        }), int64(3))
+}
+
+func (suite *AmbulanceSuite) Test_PatchAmbulance_RejectsInvalidPatches() {
+    for _, tc := range []struct {
+        contentType string
+        payload     string
+        status      int
+    }{
+        {"text/plain", `{"name":"x"}`, http.StatusUnsupportedMediaType},
+        {"application/merge-patch+json", `{"unknown":1}`, http.StatusBadRequest},
+        {"application/merge-patch+json", `{"id":"other"}`, http.StatusBadRequest},
+        {"application/json-patch+json", `[{"op":"test","path":"/capacity","value":6}]`, http.StatusBadRequest},
+    } {
+        gin.SetMode(gin.TestMode)
+        recorder := httptest.NewRecorder()
+        ctx, _ := gin.CreateTestContext(recorder)
+        ctx.Set("db_service_ambulance", suite.dbServiceMock)
+        ctx.Set("event_publisher", suite.publisher)
+        ctx.Params = []gin.Param{{Key: "ambulanceId", Value: "test-ambulance"}}
+        ctx.Request = httptest.NewRequest("PATCH", "/api/ambulances/test-ambulance", strings.NewReader(tc.payload))
+        ctx.Request.Header.Set("Content-Type", tc.contentType)
+
+        sut := implAmbulanceAPI{}
+        sut.PatchAmbulance(ctx)
+
+        suite.Equal(tc.status, recorder.Code, tc.payload)
+    }
+    suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateVersionedDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
@@ -0,0 +1,50 @@
+package ambulance
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wac-project/wac-api/internal/db_service"
+)
+
+// matchedVersionKey holds the version the If-Match header of the request was checked against.
+const matchedVersionKey = "matched_version"
+
+// etag formats a document version as a strong entity tag.
+func etag(version int64) string {
+    return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchSatisfied reports whether the If-Match request header, if present, matches the given version.
+// A matched version is remembered for matchedVersion.
+func ifMatchSatisfied(c *gin.Context, version int64) bool {
+    header := c.GetHeader("If-Match")
+    if header == "" {
+        return true
+    }
+    current := etag(version)
+    for _, tag := range strings.Split(header, ",") {
+        if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+            c.Set(matchedVersionKey, version)
+            return true
+        }
+    }
+    return false
+}
+
+// matchedVersion returns the version that satisfied the If-Match header, ok is false if the request has none.
+func matchedVersion(c *gin.Context) (version int64, ok bool) {
+    version, ok = c.Value(matchedVersionKey).(int64)
+    return version, ok
+}
+
+// deleteMatched deletes the document id. If the request has an If-Match header the document is deleted
+// only at the matched version, so a concurrent update fails the delete with db_service.ErrVersionMismatch.
+func deleteMatched[DocType interface{}](ctx context.Context, c *gin.Context, db db_service.DbService[DocType], id string) error {
+    if version, ok := matchedVersion(c); ok {
+        return db.DeleteVersionedDocument(ctx, id, version)
+    }
+    return db.DeleteDocument(ctx, id)
+}
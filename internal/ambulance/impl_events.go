@@ -0,0 +1,145 @@
+package ambulance
+
+import (
+    "context"
+    "encoding/json"
+    "reflect"
+
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// The events published on the hospital-events topic. Each type has a JSON Schema
+// in api/events named after the type, e.g. ambulance_updated.v1.json.
+
+// procedureSchemaVersion is the schema version of the procedure events, version 2 added
+// the workflow of the procedure. Published schema versions are never changed.
+const procedureSchemaVersion = 2
+
+// AmbulanceCreated is the data of the ambulance_created event.
+type AmbulanceCreated struct {
+    Ambulance Ambulance `json:"ambulance"`
+}
+
+func (AmbulanceCreated) EventType() string { return "ambulance_created" }
+
+// AmbulanceUpdated is the data of the ambulance_updated event.
+type AmbulanceUpdated struct {
+    Ambulance Ambulance              `json:"ambulance"`
+    Changes   map[string]FieldChange `json:"changes"`
+}
+
+func (AmbulanceUpdated) EventType() string { return "ambulance_updated" }
+
+// AmbulanceDeleted is the data of the ambulance_deleted event, it holds the last state of the ambulance.
+type AmbulanceDeleted struct {
+    Ambulance Ambulance `json:"ambulance"`
+}
+
+func (AmbulanceDeleted) EventType() string { return "ambulance_deleted" }
+
+// ProcedureCreated is the data of the procedure_created event.
+type ProcedureCreated struct {
+    Procedure Procedure `json:"procedure"`
+}
+
+func (ProcedureCreated) EventType() string { return "procedure_created" }
+
+func (ProcedureCreated) SchemaVersion() int { return procedureSchemaVersion }
+
+// ProcedureUpdated is the data of the procedure_updated event.
+type ProcedureUpdated struct {
+    Procedure Procedure              `json:"procedure"`
+    Changes   map[string]FieldChange `json:"changes"`
+}
+
+func (ProcedureUpdated) EventType() string { return "procedure_updated" }
+
+func (ProcedureUpdated) SchemaVersion() int { return procedureSchemaVersion }
+
+// ProcedureDeleted is the data of the procedure_deleted event, it holds the last state of the procedure.
+type ProcedureDeleted struct {
+    Procedure Procedure `json:"procedure"`
+}
+
+func (ProcedureDeleted) EventType() string { return "procedure_deleted" }
+
+func (ProcedureDeleted) SchemaVersion() int { return procedureSchemaVersion }
+
+// PaymentCreated is the data of the payment_created event.
+type PaymentCreated struct {
+    Payment Payment `json:"payment"`
+}
+
+func (PaymentCreated) EventType() string { return "payment_created" }
+
+// PaymentUpdated is the data of the payment_updated event.
+type PaymentUpdated struct {
+    Payment Payment                `json:"payment"`
+    Changes map[string]FieldChange `json:"changes"`
+}
+
+func (PaymentUpdated) EventType() string { return "payment_updated" }
+
+// PaymentDeleted is the data of the payment_deleted event, it holds the last state of the payment.
+type PaymentDeleted struct {
+    Payment Payment `json:"payment"`
+}
+
+func (PaymentDeleted) EventType() string { return "payment_deleted" }
+
+// FieldChange holds the old and new value of one field modified by an *_updated event.
+type FieldChange struct {
+    Old interface{} `json:"old"`
+    New interface{} `json:"new"`
+}
+
+// publishUpdated publishes the event returned by updated if any field differs between before and after.
+func publishUpdated[DocType any](
+    ctx context.Context,
+    publisher kafka.EventPublisher,
+    id string,
+    before *DocType,
+    after *DocType,
+    updated func(changes map[string]FieldChange) kafka.Payload,
+) error {
+    changes, err := changedFields(before, after)
+    if err != nil || len(changes) == 0 {
+        return err
+    }
+    return publishEvent(ctx, publisher, id, updated(changes))
+}
+
+// changedFields compares the JSON representation of two documents field by field.
+func changedFields(before interface{}, after interface{}) (map[string]FieldChange, error) {
+    old, err := jsonFields(before)
+    if err != nil {
+        return nil, err
+    }
+    updated, err := jsonFields(after)
+    if err != nil {
+        return nil, err
+    }
+
+    changes := map[string]FieldChange{}
+    for name, value := range updated {
+        if previous, ok := old[name]; !ok || !reflect.DeepEqual(previous, value) {
+            changes[name] = FieldChange{Old: old[name], New: value}
+        }
+    }
+    for name, value := range old {
+        if _, ok := updated[name]; !ok {
+            changes[name] = FieldChange{Old: value}
+        }
+    }
+    return changes, nil
+}
+
+func jsonFields(document interface{}) (map[string]interface{}, error) {
+    encoded, err := json.Marshal(document)
+    if err != nil {
+        return nil, err
+    }
+    fields := map[string]interface{}{}
+    err = json.Unmarshal(encoded, &fields)
+    return fields, err
+}
@@ -0,0 +1,131 @@
+package ambulance
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wac-project/wac-api/internal/db_service"
+)
+
+// listPagingParams are the query parameters that control paging rather than filtering.
+var listPagingParams = map[string]bool{"limit": true, "offset": true, "cursor": true, "sort": true}
+
+// parseListOptions reads the ?limit=&offset=&cursor=&sort= parameters shared by all list endpoints.
+// All remaining query parameters are filters, see parseFilter.
+func parseListOptions(c *gin.Context) (db_service.ListOptions, error) {
+    opts := db_service.ListOptions{
+        Cursor: c.Query("cursor"),
+        Sort:   db_service.ParseSort(c.Query("sort")),
+    }
+    for name, target := range map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset} {
+        value := c.Query(name)
+        if value == "" {
+            continue
+        }
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 0 {
+            return opts, fmt.Errorf("%s must be a non-negative integer", name)
+        }
+        *target = n
+    }
+    filter, err := parseFilter(c.Request.URL.Query())
+    if err != nil {
+        return opts, err
+    }
+    opts.Filter = filter
+    return opts, nil
+}
+
+// parseFilter combines the filter query parameters into one conjunction:
+// `field=value` matches equal values (repeat the parameter to match any of several values),
+// `field[op]=value` applies one of eq, ne, gt, gte, lt, lte or prefix,
+// and `field[in]=a,b` matches any of a comma separated list,
+// e.g. `?payer=VSZP&price[gte]=100&timestamp[lt]=2026-01-01`.
+func parseFilter(query url.Values) (*db_service.Filter, error) {
+    keys := make([]string, 0, len(query))
+    for key := range query {
+        if !listPagingParams[key] {
+            keys = append(keys, key)
+        }
+    }
+    if len(keys) == 0 {
+        return nil, nil
+    }
+    sort.Strings(keys)
+
+    var conditions []db_service.Filter
+    for _, key := range keys {
+        field, op := key, db_service.OpEq
+        if open := strings.IndexByte(key, '['); open > 0 && strings.HasSuffix(key, "]") {
+            parsed, err := db_service.ParseFilterOp(key[open+1 : len(key)-1])
+            if err != nil {
+                return nil, err
+            }
+            field, op = key[:open], parsed
+        }
+
+        values := query[key]
+        switch {
+        case op == db_service.OpIn:
+            var items []any
+            for _, value := range values {
+                for _, item := range strings.Split(value, ",") {
+                    items = append(items, item)
+                }
+            }
+            conditions = append(conditions, db_service.In(field, items...))
+        case op == db_service.OpEq && len(values) > 1:
+            items := make([]any, len(values))
+            for i, value := range values {
+                items[i] = value
+            }
+            conditions = append(conditions, db_service.In(field, items...))
+        default:
+            for _, value := range values {
+                conditions = append(conditions, db_service.Filter{Op: op, Field: field, Value: value})
+            }
+        }
+    }
+    filter := db_service.And(conditions...)
+    return &filter, nil
+}
+
+// respondWithPage loads one page of documents and writes it as a JSON array.
+// Paging metadata is returned in the X-Total-Count, X-Next-Cursor and Link headers
+// so that the response body stays a plain list.
+func respondWithPage[DocType any](c *gin.Context, db db_service.DbService[DocType], opts db_service.ListOptions, what string) {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    page, err := db.ListDocumentsPage(ctx, opts)
+    if err != nil {
+        if errors.Is(err, db_service.ErrInvalidCursor) || errors.Is(err, db_service.ErrInvalidField) {
+            c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid list parameters", "error": err.Error()})
+            return
+        }
+        log.Println("ListDocumentsPage error:", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve " + what})
+        return
+    }
+
+    c.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+    if page.NextCursor != "" {
+        next := *c.Request.URL
+        query := next.Query()
+        query.Del("offset")
+        query.Set("cursor", page.NextCursor)
+        next.RawQuery = query.Encode()
+        c.Header("X-Next-Cursor", page.NextCursor)
+        c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+    }
+    c.JSON(http.StatusOK, page.Items)
+}
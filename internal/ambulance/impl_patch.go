@@ -0,0 +1,308 @@
+package ambulance
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "mime"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+const (
+    mergePatchContentType = "application/merge-patch+json"
+    jsonPatchContentType  = "application/json-patch+json"
+)
+
+var errUnsupportedPatch = errors.New("unsupported patch media type")
+
+// patchDocument applies the request body to document as an RFC 7396 JSON Merge Patch
+// (application/merge-patch+json or application/json) or an RFC 6902 JSON Patch
+// (application/json-patch+json) and returns the patched copy.
+func patchDocument[DocType any](c *gin.Context, document *DocType) (*DocType, error) {
+    body, err := c.GetRawData()
+    if err != nil {
+        return nil, err
+    }
+    contentType, _, _ := mime.ParseMediaType(c.ContentType())
+    switch contentType {
+    case mergePatchContentType, "application/json", "":
+        return applyPatch(document, func(target any) (any, error) {
+            var patch any
+            if err := json.Unmarshal(body, &patch); err != nil {
+                return nil, err
+            }
+            return mergePatch(target, patch), nil
+        })
+    case jsonPatchContentType:
+        return applyPatch(document, func(target any) (any, error) {
+            var operations []jsonPatchOperation
+            if err := json.Unmarshal(body, &operations); err != nil {
+                return nil, err
+            }
+            return applyJSONPatch(target, operations)
+        })
+    }
+    return nil, fmt.Errorf("%w: %s", errUnsupportedPatch, contentType)
+}
+
+// applyPatch runs patch on the generic JSON form of document and decodes the result.
+// Fields unknown to DocType are rejected.
+func applyPatch[DocType any](document *DocType, patch func(target any) (any, error)) (*DocType, error) {
+    original, err := json.Marshal(document)
+    if err != nil {
+        return nil, err
+    }
+    var target any
+    if err := json.Unmarshal(original, &target); err != nil {
+        return nil, err
+    }
+    patched, err := patch(target)
+    if err != nil {
+        return nil, err
+    }
+    if _, ok := patched.(map[string]any); !ok {
+        return nil, errors.New("patch must result in a JSON object")
+    }
+    encoded, err := json.Marshal(patched)
+    if err != nil {
+        return nil, err
+    }
+
+    var result DocType
+    decoder := json.NewDecoder(bytes.NewReader(encoded))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// mergePatch implements the MergePatch algorithm of RFC 7396: objects are merged
+// recursively, null removes a member and any other value replaces the target.
+func mergePatch(target any, patch any) any {
+    patchObject, ok := patch.(map[string]any)
+    if !ok {
+        return patch
+    }
+    targetObject, ok := target.(map[string]any)
+    if !ok {
+        targetObject = map[string]any{}
+    }
+    for name, value := range patchObject {
+        if value == nil {
+            delete(targetObject, name)
+        } else {
+            targetObject[name] = mergePatch(targetObject[name], value)
+        }
+    }
+    return targetObject
+}
+
+// jsonPatchOperation is one operation of an RFC 6902 JSON Patch document.
+type jsonPatchOperation struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path"`
+    From  string          `json:"from,omitempty"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// applyJSONPatch applies the operations in order; the patch fails as a whole if any operation fails.
+func applyJSONPatch(document any, operations []jsonPatchOperation) (any, error) {
+    for i, operation := range operations {
+        var err error
+        switch operation.Op {
+        case "add", "replace", "test":
+            var value any
+            if len(operation.Value) == 0 {
+                err = errors.New("value is required")
+                break
+            }
+            if err = json.Unmarshal(operation.Value, &value); err != nil {
+                break
+            }
+            switch operation.Op {
+            case "add":
+                document, err = jsonPointerAdd(document, operation.Path, value)
+            case "replace":
+                if _, err = jsonPointerGet(document, operation.Path); err == nil {
+                    document, _, err = jsonPointerRemove(document, operation.Path)
+                }
+                if err == nil {
+                    document, err = jsonPointerAdd(document, operation.Path, value)
+                }
+            case "test":
+                var current any
+                if current, err = jsonPointerGet(document, operation.Path); err == nil && !reflect.DeepEqual(current, value) {
+                    err = errors.New("test failed")
+                }
+            }
+        case "remove":
+            document, _, err = jsonPointerRemove(document, operation.Path)
+        case "move":
+            var value any
+            if document, value, err = jsonPointerRemove(document, operation.From); err == nil {
+                document, err = jsonPointerAdd(document, operation.Path, value)
+            }
+        case "copy":
+            var value any
+            if value, err = jsonPointerGet(document, operation.From); err == nil {
+                document, err = jsonPointerAdd(document, operation.Path, deepCopy(value))
+            }
+        default:
+            err = fmt.Errorf("unknown operation %q", operation.Op)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+        }
+    }
+    return document, nil
+}
+
+// splitPointer parses an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func splitPointer(pointer string) ([]string, error) {
+    if pointer == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(pointer, "/") {
+        return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+    }
+    tokens := strings.Split(pointer[1:], "/")
+    for i, token := range tokens {
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+    if allowEnd && token == "-" {
+        return length, nil
+    }
+    index, err := strconv.Atoi(token)
+    if err != nil || index < 0 || index > length || (index == length && !allowEnd) {
+        return 0, fmt.Errorf("invalid array index %q", token)
+    }
+    return index, nil
+}
+
+func jsonPointerGet(document any, pointer string) (any, error) {
+    tokens, err := splitPointer(pointer)
+    if err != nil {
+        return nil, err
+    }
+    current := document
+    for _, token := range tokens {
+        switch node := current.(type) {
+        case map[string]any:
+            value, ok := node[token]
+            if !ok {
+                return nil, fmt.Errorf("path %q does not exist", pointer)
+            }
+            current = value
+        case []any:
+            index, err := arrayIndex(token, len(node), false)
+            if err != nil {
+                return nil, err
+            }
+            current = node[index]
+        default:
+            return nil, fmt.Errorf("path %q does not exist", pointer)
+        }
+    }
+    return current, nil
+}
+
+// jsonPointerAdd returns document with value added at pointer.
+func jsonPointerAdd(document any, pointer string, value any) (any, error) {
+    tokens, err := splitPointer(pointer)
+    if err != nil {
+        return nil, err
+    }
+    if len(tokens) == 0 {
+        return value, nil
+    }
+    parent, err := jsonPointerGet(document, pointerOf(tokens[:len(tokens)-1]))
+    if err != nil {
+        return nil, err
+    }
+    last := tokens[len(tokens)-1]
+    switch node := parent.(type) {
+    case map[string]any:
+        node[last] = value
+        return document, nil
+    case []any:
+        index, err := arrayIndex(last, len(node), true)
+        if err != nil {
+            return nil, err
+        }
+        node = append(node[:index], append([]any{value}, node[index:]...)...)
+        return jsonPointerAdd(document, pointerOf(tokens[:len(tokens)-1]), node)
+    }
+    return nil, fmt.Errorf("path %q does not exist", pointer)
+}
+
+// jsonPointerRemove returns document without the value at pointer, and the removed value.
+func jsonPointerRemove(document any, pointer string) (any, any, error) {
+    tokens, err := splitPointer(pointer)
+    if err != nil {
+        return nil, nil, err
+    }
+    if len(tokens) == 0 {
+        return nil, document, nil
+    }
+    parent, err := jsonPointerGet(document, pointerOf(tokens[:len(tokens)-1]))
+    if err != nil {
+        return nil, nil, err
+    }
+    last := tokens[len(tokens)-1]
+    switch node := parent.(type) {
+    case map[string]any:
+        value, ok := node[last]
+        if !ok {
+            return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+        }
+        delete(node, last)
+        return document, value, nil
+    case []any:
+        index, err := arrayIndex(last, len(node), false)
+        if err != nil {
+            return nil, nil, err
+        }
+        value := node[index]
+        node = append(node[:index:index], node[index+1:]...)
+        document, err = jsonPointerAdd(document, pointerOf(tokens[:len(tokens)-1]), node)
+        return document, value, err
+    }
+    return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+}
+
+func pointerOf(tokens []string) string {
+    var builder strings.Builder
+    for _, token := range tokens {
+        builder.WriteString("/")
+        builder.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+    }
+    return builder.String()
+}
+
+func deepCopy(value any) any {
+    encoded, _ := json.Marshal(value)
+    var copied any
+    _ = json.Unmarshal(encoded, &copied)
+    return copied
+}
+
+// patchFailure maps an error returned by patchDocument to a response body and status.
+func patchFailure(err error) (interface{}, int) {
+    if errors.Is(err, errUnsupportedPatch) {
+        return gin.H{
+            "message": "Content-Type must be " + mergePatchContentType + " or " + jsonPatchContentType,
+        }, http.StatusUnsupportedMediaType
+    }
+    return gin.H{"message": "Invalid patch", "error": err.Error()}, http.StatusBadRequest
+}
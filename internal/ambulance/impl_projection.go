@@ -0,0 +1,138 @@
+package ambulance
+
+import (
+    "context"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// AmbulanceCosts is the procedure cost projection of one ambulance, kept up to date
+// from procedure events by the handlers of RegisterCostProjection.
+type AmbulanceCosts struct {
+    // Id is the id of the ambulance.
+    Id string `json:"id"`
+
+    ProcedureCount int64 `json:"procedure_count"`
+
+    TotalCost float64 `json:"total_cost"`
+
+    // Procedures holds the last known state of each procedure of the ambulance keyed by
+    // procedure id, so that redelivered events do not count a procedure twice.
+    Procedures map[string]ProcedureCost `json:"procedures"`
+}
+
+// ProcedureCost is the part of a procedure the cost projection needs.
+type ProcedureCost struct {
+    Price     float64 `json:"price"`
+    VisitType string  `json:"visit_type"`
+    Payer     string  `json:"payer"`
+}
+
+// RegisterCostProjection registers the handlers that maintain the AmbulanceCosts projection.
+func RegisterCostProjection(router *kafka.Router, costs db_service.DbService[AmbulanceCosts]) {
+    kafka.Handle(router, func(ctx context.Context, _ *kafka.Event, data ProcedureCreated) error {
+        return setProcedureCost(ctx, costs, &data.Procedure)
+    })
+    kafka.Handle(router, func(ctx context.Context, _ *kafka.Event, data ProcedureUpdated) error {
+        if change, ok := data.Changes["ambulance_id"]; ok {
+            if previous, _ := change.Old.(string); previous != "" && previous != data.Procedure.AmbulanceId {
+                if err := removeProcedureCost(ctx, costs, previous, data.Procedure.Id); err != nil {
+                    return err
+                }
+            }
+        }
+        return setProcedureCost(ctx, costs, &data.Procedure)
+    })
+    kafka.Handle(router, func(ctx context.Context, _ *kafka.Event, data ProcedureDeleted) error {
+        return removeProcedureCost(ctx, costs, data.Procedure.AmbulanceId, data.Procedure.Id)
+    })
+    kafka.Handle(router, func(ctx context.Context, _ *kafka.Event, data AmbulanceDeleted) error {
+        if err := costs.DeleteDocument(ctx, data.Ambulance.Id); err != nil && err != db_service.ErrNotFound {
+            return err
+        }
+        return nil
+    })
+}
+
+// getCostsDB extracts the cost projection DbService from the context. It is only present
+// when the projection is maintained, i.e. when events are consumed.
+func getCostsDB(c *gin.Context) (db_service.DbService[AmbulanceCosts], bool) {
+    value, ok := c.Get("db_service_ambulance_costs")
+    if !ok {
+        return nil, false
+    }
+    costs, ok := value.(db_service.DbService[AmbulanceCosts])
+    return costs, ok
+}
+
+func setProcedureCost(ctx context.Context, costs db_service.DbService[AmbulanceCosts], procedure *Procedure) error {
+    cost := ProcedureCost{Price: procedure.Price, VisitType: procedure.VisitType, Payer: procedure.Payer}
+    return updateCosts(ctx, costs, procedure.AmbulanceId, func(projection *AmbulanceCosts) bool {
+        if current, ok := projection.Procedures[procedure.Id]; ok && current == cost {
+            return false
+        }
+        projection.Procedures[procedure.Id] = cost
+        return true
+    })
+}
+
+func removeProcedureCost(ctx context.Context, costs db_service.DbService[AmbulanceCosts], ambulanceId string, procedureId string) error {
+    return updateCosts(ctx, costs, ambulanceId, func(projection *AmbulanceCosts) bool {
+        if _, ok := projection.Procedures[procedureId]; !ok {
+            return false
+        }
+        delete(projection.Procedures, procedureId)
+        return true
+    })
+}
+
+// updateCosts applies change to the projection of an ambulance and recomputes its totals.
+// change reports whether it modified the projection. Consumers of different partitions
+// may update the same ambulance, so concurrent updates are retried.
+func updateCosts(
+    ctx context.Context,
+    costs db_service.DbService[AmbulanceCosts],
+    ambulanceId string,
+    change func(projection *AmbulanceCosts) bool,
+) error {
+    for {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        projection, version, err := costs.FindVersionedDocument(ctx, ambulanceId)
+        found := err == nil
+        switch {
+        case err == db_service.ErrNotFound:
+            projection = &AmbulanceCosts{Id: ambulanceId}
+        case err != nil:
+            return err
+        }
+        if projection.Procedures == nil {
+            projection.Procedures = map[string]ProcedureCost{}
+        }
+        if !change(projection) {
+            return nil
+        }
+
+        projection.ProcedureCount = int64(len(projection.Procedures))
+        projection.TotalCost = 0
+        for _, procedure := range projection.Procedures {
+            projection.TotalCost += procedure.Price
+        }
+
+        if !found {
+            err = costs.CreateDocument(ctx, ambulanceId, projection)
+            if err == db_service.ErrConflict {
+                continue
+            }
+            return err
+        }
+        _, err = costs.UpdateVersionedDocument(ctx, ambulanceId, projection, version)
+        if err == db_service.ErrVersionMismatch {
+            continue
+        }
+        return err
+    }
+}
@@ -0,0 +1,27 @@
+package ambulance
+
+import (
+    "context"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// eventSource is the CloudEvents source attribute of the events published by this API.
+const eventSource = "/wac-api/ambulance-api-service"
+
+// getEventPublisher extracts the EventPublisher from the context.
+func getEventPublisher(c *gin.Context) kafka.EventPublisher {
+    return c.MustGet("event_publisher").(kafka.EventPublisher)
+}
+
+// publishEvent wraps payload in a CloudEvent about the aggregate with the given id and publishes it.
+// Call it inside the transaction that changes the aggregate: with a kafka.OutboxPublisher the
+// event is then recorded if and only if the change is committed.
+func publishEvent(ctx context.Context, publisher kafka.EventPublisher, aggregateId string, payload kafka.Payload) error {
+    event, err := kafka.NewEvent(eventSource, aggregateId, payload)
+    if err != nil {
+        return err
+    }
+    return publisher.Publish(ctx, event)
+}
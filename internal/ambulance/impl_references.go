@@ -0,0 +1,29 @@
+package ambulance
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/wac-project/wac-api/internal/db_service"
+)
+
+// FieldError describes a problem with one field of a request body or stored document.
+type FieldError struct {
+    Field   string `json:"field"`
+    Message string `json:"message"`
+}
+
+// checkReference verifies that the document with the given id exists in db.
+// A missing document is reported as a FieldError for field, lookup failures as error.
+func checkReference[DocType any](ctx context.Context, db db_service.DbService[DocType], field string, id string, what string) (*FieldError, error) {
+    if id == "" {
+        return &FieldError{Field: field, Message: fmt.Sprintf("%s is required", field)}, nil
+    }
+    if _, err := db.FindDocument(ctx, id); err != nil {
+        if err == db_service.ErrNotFound {
+            return &FieldError{Field: field, Message: fmt.Sprintf("%s %q does not exist", what, id)}, nil
+        }
+        return nil, err
+    }
+    return nil, nil
+}
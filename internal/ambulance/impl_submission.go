@@ -0,0 +1,189 @@
+package ambulance
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/camunda"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// SubmissionConfig tunes a SubmissionStarter. Zero values select the defaults.
+type SubmissionConfig struct {
+    // Interval between polls of the procedures waiting for their process, 5s by default.
+    // It is also the delay before the first retry of a failed start.
+    Interval time.Duration
+
+    // MaxBackoff caps the delay between retries of a failed start, 5m by default.
+    MaxBackoff time.Duration
+
+    // MaxAttempts is how often the start of a process is tried before the procedure is
+    // marked failed, 10 by default.
+    MaxAttempts int
+
+    // StartTimeout limits one start of a process, 10s by default.
+    StartTimeout time.Duration
+}
+
+// SubmissionStarter starts the process of every submitted procedure that has none and records its
+// process instance. Procedures are created without their process, so that creating one does not
+// wait for the workflow engine. A failed start is retried with exponential backoff, the procedure
+// is marked failed once MaxAttempts starts failed.
+//
+// The process is looked up by its business key, the procedure id, before it is started, so a
+// start that timed out but reached the engine is recorded instead of being started again. Run
+// it in a single instance of the API.
+type SubmissionStarter struct {
+    procedures db_service.DbService[Procedure]
+    publisher  kafka.EventPublisher
+    client     *camunda.Client
+    config     SubmissionConfig
+
+    // retries holds the procedures whose start failed, by procedure id
+    retries map[string]*startRetry
+}
+
+// startRetry counts the failed starts of the process of a procedure.
+type startRetry struct {
+    failed int
+    next   time.Time
+}
+
+// NewSubmissionStarter creates a starter for the procedures of the given collection, it publishes
+// their changes with publisher.
+func NewSubmissionStarter(
+    procedures db_service.DbService[Procedure],
+    publisher kafka.EventPublisher,
+    client *camunda.Client,
+    config SubmissionConfig,
+) *SubmissionStarter {
+    if config.Interval <= 0 {
+        config.Interval = 5 * time.Second
+    }
+    if config.MaxBackoff <= 0 {
+        config.MaxBackoff = 5 * time.Minute
+    }
+    if config.MaxAttempts <= 0 {
+        config.MaxAttempts = 10
+    }
+    if config.StartTimeout <= 0 {
+        config.StartTimeout = 10 * time.Second
+    }
+    return &SubmissionStarter{
+        procedures: procedures,
+        publisher:  publisher,
+        client:     client,
+        config:     config,
+        retries:    map[string]*startRetry{},
+    }
+}
+
+// Run starts the processes of waiting procedures every Interval until ctx is done.
+func (s *SubmissionStarter) Run(ctx context.Context) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(s.config.Interval):
+        }
+        if err := s.StartWaiting(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("⚠️ submission starter error: %v", err)
+        }
+    }
+}
+
+// StartWaiting starts the processes of the submitted procedures that have none, skipping those
+// whose next retry is not due yet. Failed starts are retried by later calls.
+func (s *SubmissionStarter) StartWaiting(ctx context.Context) error {
+    waiting, err := s.procedures.FindDocuments(ctx, db_service.And(
+        db_service.Eq("workflow_status", WorkflowSubmitted),
+        db_service.Eq("process_instance_id", ""),
+    ))
+    if err != nil {
+        return err
+    }
+
+    retries := map[string]*startRetry{}
+    for i := range waiting {
+        p := &waiting[i]
+        retry := s.retries[p.Id]
+        if retry != nil && time.Now().Before(retry.next) {
+            retries[p.Id] = retry
+            continue
+        }
+
+        instance, err := s.findOrStart(ctx, p)
+        if err == nil {
+            log.Printf("Process %s handles procedure %s", instance.Id, p.Id)
+            if err := s.link(ctx, p.Id, instance); err != nil {
+                return err
+            }
+            continue
+        }
+
+        if retry == nil {
+            retry = &startRetry{}
+        }
+        retry.failed++
+        if retry.failed >= s.config.MaxAttempts {
+            log.Printf("Failed to start the process of procedure %s %d times, giving up: %v", p.Id, retry.failed, err)
+            if err := s.link(ctx, p.Id, nil); err != nil {
+                return err
+            }
+            continue
+        }
+        delay := min(s.config.Interval<<(retry.failed-1), s.config.MaxBackoff)
+        retry.next = time.Now().Add(delay)
+        retries[p.Id] = retry
+        log.Printf("Failed to start the process of procedure %s, retrying in %v: %v", p.Id, delay, err)
+    }
+    // procedures that are no longer waiting are forgotten
+    s.retries = retries
+    return nil
+}
+
+// findOrStart returns the running process of procedure p, or starts it.
+func (s *SubmissionStarter) findOrStart(ctx context.Context, p *Procedure) (*camunda.ProcessInstance, error) {
+    ctx, cancel := context.WithTimeout(ctx, s.config.StartTimeout)
+    defer cancel()
+
+    running, err := s.client.ProcessInstances(ctx, submissionProcessKey, p.Id)
+    if err != nil {
+        return nil, err
+    }
+    if len(running) > 0 {
+        return &running[0], nil
+    }
+    return s.client.StartProcess(ctx, submissionProcessKey, p.Id, map[string]any{"procedureData": p})
+}
+
+// link records the process instance on the stored procedure with the given id, or the failed
+// status if instance is nil. A process the worker linked meanwhile is kept, and so is a status
+// the worker reported.
+func (s *SubmissionStarter) link(ctx context.Context, id string, instance *camunda.ProcessInstance) error {
+    for {
+        existing, version, err := s.procedures.FindVersionedDocument(ctx, id)
+        if err == db_service.ErrNotFound {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if existing.ProcessInstanceId != "" || (instance == nil && existing.WorkflowStatus != WorkflowSubmitted) {
+            return nil
+        }
+
+        updated := *existing
+        if instance == nil {
+            updated.WorkflowStatus = WorkflowFailed
+        } else {
+            updated.ProcessInstanceId = instance.Id
+        }
+        _, err = saveProcedure(ctx, s.procedures, s.publisher, existing, &updated, version)
+        if err != db_service.ErrVersionMismatch {
+            return err
+        }
+    }
+}
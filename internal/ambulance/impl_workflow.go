@@ -0,0 +1,497 @@
+package ambulance
+
+import (
+    "context"
+    "crypto/subtle"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// ids of the BPMN process handling submitted procedures and of its user tasks
+const (
+    submissionProcessKey = "SubmitMedicalPerformance"
+    approvalTaskKey      = "ApproveSubmission"
+    correctionTaskKey    = "CorrectData"
+)
+
+// Workflow statuses of a procedure, see Procedure.WorkflowStatus.
+const (
+    WorkflowSubmitted        = "submitted"
+    WorkflowValidating       = "validating"
+    WorkflowNeedsCorrection  = "needs_correction"
+    WorkflowAwaitingApproval = "awaiting_approval"
+    WorkflowBilling          = "billing"
+    WorkflowCompleted        = "completed"
+    WorkflowRejected         = "rejected"
+    WorkflowFailed           = "failed"
+)
+
+var workflowStatuses = map[string]bool{
+    WorkflowSubmitted:        true,
+    WorkflowValidating:       true,
+    WorkflowNeedsCorrection:  true,
+    WorkflowAwaitingApproval: true,
+    WorkflowBilling:          true,
+    WorkflowCompleted:        true,
+    WorkflowRejected:         true,
+    WorkflowFailed:           true,
+}
+
+// workflowTransitions lists the statuses that may replace each workflow status. A procedure only
+// moves on to a later step, except for the correction loop, so that the late update of a retried
+// task does not move it back. Completed and rejected procedures keep their status, a failed one
+// only records that its process completed once the incident was resolved. Procedures stored
+// without a status are treated as submitted.
+var workflowTransitions = map[string][]string{
+    WorkflowSubmitted:        {WorkflowValidating, WorkflowNeedsCorrection, WorkflowFailed},
+    WorkflowValidating:       {WorkflowNeedsCorrection, WorkflowAwaitingApproval, WorkflowFailed},
+    WorkflowNeedsCorrection:  {WorkflowValidating, WorkflowFailed},
+    WorkflowAwaitingApproval: {WorkflowBilling, WorkflowRejected, WorkflowFailed},
+    WorkflowBilling:          {WorkflowCompleted, WorkflowFailed},
+    WorkflowFailed:           {WorkflowCompleted},
+}
+
+// errWorkflowConflict reports a workflow status update that does not apply to the stored procedure.
+var errWorkflowConflict = errors.New("workflow status update conflicts with the stored procedure")
+
+// checkWorkflowUpdate returns errWorkflowConflict if procedure p may not move to status, or was
+// recorded with another process instance than the non-empty processInstanceId.
+func checkWorkflowUpdate(p *Procedure, processInstanceId string, status string) error {
+    if processInstanceId != "" && p.ProcessInstanceId != "" && processInstanceId != p.ProcessInstanceId {
+        return fmt.Errorf("%w: procedure %s is handled by process %s, not %s", errWorkflowConflict, p.Id, p.ProcessInstanceId, processInstanceId)
+    }
+    current := p.WorkflowStatus
+    if current == "" {
+        current = WorkflowSubmitted
+    }
+    if status == current || slices.Contains(workflowTransitions[current], status) {
+        return nil
+    }
+    return fmt.Errorf("%w: procedure %s cannot move from %s to %s", errWorkflowConflict, p.Id, current, status)
+}
+
+// authorizeWorker checks the bearer token of the request against the token of the workflow worker,
+// it responds and returns false if they differ. Without a configured token nobody is authorized.
+func authorizeWorker(c *gin.Context) bool {
+    token := c.GetString("worker_token")
+    if token == "" {
+        c.JSON(http.StatusForbidden, gin.H{"message": "Workflow status updates are disabled"})
+        return false
+    }
+    bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+    if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+        c.Header("WWW-Authenticate", "Bearer")
+        c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid worker token"})
+        return false
+    }
+    return true
+}
+
+// implWorkflowAPI implements the WorkflowManagementAPI interface.
+type implWorkflowAPI struct{}
+
+// NewWorkflowAPI returns an implementation of WorkflowManagementAPI.
+func NewWorkflowAPI() WorkflowManagementAPI {
+    return &implWorkflowAPI{}
+}
+
+// getCamundaClient extracts the client of the workflow engine from the context.
+func getCamundaClient(c *gin.Context) *camunda.Client {
+    return c.MustGet("camunda_client").(*camunda.Client)
+}
+
+// workflowFailure responds to a failed call of the workflow engine, what names the missing resource on 404.
+func workflowFailure(c *gin.Context, err error, what string) {
+    var engineErr *camunda.Error
+    if errors.As(err, &engineErr) {
+        switch {
+        case engineErr.StatusCode == http.StatusNotFound:
+            c.JSON(http.StatusNotFound, gin.H{"message": what + " not found"})
+            return
+        case engineErr.Type == "TaskAlreadyClaimedException":
+            c.JSON(http.StatusConflict, gin.H{"message": engineErr.Message})
+            return
+        }
+    }
+    log.Println("Camunda error:", err)
+    c.JSON(http.StatusBadGateway, gin.H{"message": "Workflow engine unavailable"})
+}
+
+// isEngineNotFound reports whether err is a 404 of the workflow engine.
+func isEngineNotFound(err error) bool {
+    var engineErr *camunda.Error
+    return errors.As(err, &engineErr) && engineErr.StatusCode == http.StatusNotFound
+}
+
+// forEachUserTask calls fn with every open user task with the given definition key and its
+// variables names. It responds with the failure and returns false if the engine fails.
+func forEachUserTask(
+    c *gin.Context,
+    ctx context.Context,
+    definitionKey string,
+    names []string,
+    fn func(*camunda.UserTask, camunda.Variables),
+) bool {
+    client := getCamundaClient(c)
+    tasks, err := client.UserTasks(ctx, definitionKey)
+    if err != nil {
+        workflowFailure(c, err, "Task")
+        return false
+    }
+    for i := range tasks {
+        variables, err := client.UserTaskVariables(ctx, tasks[i].Id, names...)
+        if isEngineNotFound(err) {
+            // completed since it was listed
+            continue
+        } else if err != nil {
+            workflowFailure(c, err, "Task")
+            return false
+        }
+        fn(&tasks[i], variables)
+    }
+    return true
+}
+
+// submittedProcedure decodes the procedureData variable, nil if it is missing or unusable.
+func submittedProcedure(taskId string, variables camunda.Variables) *Procedure {
+    procedure, err := camunda.Get[*Procedure](variables, "procedureData")
+    if err != nil {
+        log.Printf("Task %s has no usable procedureData: %v", taskId, err)
+        return nil
+    }
+    return procedure
+}
+
+// GetApprovals implements GET /api/approvals
+func (o *implWorkflowAPI) GetApprovals(c *gin.Context) {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    approvals := []Approval{}
+    ok := forEachUserTask(c, ctx, approvalTaskKey, []string{"procedureData"}, func(task *camunda.UserTask, variables camunda.Variables) {
+        approvals = append(approvals, Approval{
+            TaskId:            task.Id,
+            ProcessInstanceId: task.ProcessInstanceId,
+            Assignee:          task.Assignee,
+            Created:           task.Created,
+            Procedure:         submittedProcedure(task.Id, variables),
+        })
+    })
+    if ok {
+        c.JSON(http.StatusOK, approvals)
+    }
+}
+
+// withApprovalTask loads the Approve Submission task of the request and calls fn with the
+// decision of the request body. The task must not be claimed by another user.
+func withApprovalTask(
+    c *gin.Context,
+    requireReason bool,
+    fn func(context.Context, *camunda.Client, *camunda.UserTask, *ApprovalDecision) error,
+) {
+    var decision ApprovalDecision
+    if err := c.ShouldBindJSON(&decision); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request", "error": err.Error()})
+        return
+    }
+    if strings.TrimSpace(decision.UserId) == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"message": "user_id is required"})
+        return
+    }
+    if requireReason && strings.TrimSpace(decision.Reason) == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"message": "reason is required"})
+        return
+    }
+
+    client := getCamundaClient(c)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    task, err := client.UserTask(ctx, c.Param("taskId"))
+    if err != nil {
+        workflowFailure(c, err, "Approval")
+        return
+    }
+    if task.TaskDefinitionKey != approvalTaskKey {
+        c.JSON(http.StatusNotFound, gin.H{"message": "Approval not found"})
+        return
+    }
+    if task.Assignee != "" && task.Assignee != decision.UserId {
+        c.JSON(http.StatusConflict, gin.H{"message": fmt.Sprintf("Approval is claimed by %s", task.Assignee)})
+        return
+    }
+
+    if err := fn(ctx, client, task, &decision); err != nil {
+        workflowFailure(c, err, "Approval")
+        return
+    }
+    c.JSON(http.StatusNoContent, nil)
+}
+
+// ClaimApproval implements POST /api/approvals/:taskId/claim
+func (o *implWorkflowAPI) ClaimApproval(c *gin.Context) {
+    withApprovalTask(c, false, func(ctx context.Context, client *camunda.Client, task *camunda.UserTask, decision *ApprovalDecision) error {
+        if task.Assignee == decision.UserId {
+            return nil
+        }
+        return client.ClaimUserTask(ctx, task.Id, decision.UserId)
+    })
+}
+
+// ApproveSubmission implements POST /api/approvals/:taskId/approve
+func (o *implWorkflowAPI) ApproveSubmission(c *gin.Context) {
+    withApprovalTask(c, false, func(ctx context.Context, client *camunda.Client, task *camunda.UserTask, decision *ApprovalDecision) error {
+        log.Printf("Procedure of process %s approved by %s", task.ProcessInstanceId, decision.UserId)
+        return completeApproval(ctx, client, task, decision, true)
+    })
+}
+
+// RejectSubmission implements POST /api/approvals/:taskId/reject
+// A rejected submission ends the process without billing.
+func (o *implWorkflowAPI) RejectSubmission(c *gin.Context) {
+    withApprovalTask(c, true, func(ctx context.Context, client *camunda.Client, task *camunda.UserTask, decision *ApprovalDecision) error {
+        // the variables are gone once the process ended
+        variables, err := client.UserTaskVariables(ctx, task.Id, "procedureId", "procedureData")
+        if err != nil {
+            return err
+        }
+        if err := completeApproval(ctx, client, task, decision, false); err != nil {
+            return err
+        }
+        log.Printf("Procedure of process %s rejected by %s: %s", task.ProcessInstanceId, decision.UserId, decision.Reason)
+
+        // no task of the process follows to record the outcome
+        id, err := camunda.Get[string](variables, "procedureId")
+        if err != nil {
+            if submitted := submittedProcedure(task.Id, variables); submitted != nil {
+                id = submitted.Id
+            }
+        }
+        if id != "" {
+            if err := updateWorkflowStatus(ctx, c, id, task.ProcessInstanceId, WorkflowRejected); err != nil {
+                log.Printf("Failed to record the rejection of procedure %s: %v", id, err)
+            }
+        }
+        return nil
+    })
+}
+
+// completeApproval completes the task with the variables read by the Approved? gateway.
+func completeApproval(ctx context.Context, client *camunda.Client, task *camunda.UserTask, decision *ApprovalDecision, approved bool) error {
+    return client.CompleteUserTask(ctx, task.Id, map[string]any{
+        "approved":       approved,
+        "approvedBy":     decision.UserId,
+        "approvalReason": decision.Reason,
+    })
+}
+
+// GetCorrections implements GET /api/corrections
+func (o *implWorkflowAPI) GetCorrections(c *gin.Context) {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    corrections := []Correction{}
+    names := []string{"procedureData", "validationErrors", "invalidProcedureMessage"}
+    ok := forEachUserTask(c, ctx, correctionTaskKey, names, func(task *camunda.UserTask, variables camunda.Variables) {
+        corrections = append(corrections, Correction{
+            TaskId:            task.Id,
+            ProcessInstanceId: task.ProcessInstanceId,
+            Created:           task.Created,
+            Procedure:         submittedProcedure(task.Id, variables),
+            ValidationErrors:  validationErrors(variables),
+        })
+    })
+    if ok {
+        c.JSON(http.StatusOK, corrections)
+    }
+}
+
+// validationErrors returns the problems found by the Validate Data task or, if the procedure
+// data could not be read at all, the message of the Invalid Procedure error.
+func validationErrors(variables camunda.Variables) []FieldError {
+    if problems, err := camunda.Get[[]FieldError](variables, "validationErrors"); err == nil && len(problems) > 0 {
+        return problems
+    }
+    if message, err := camunda.Get[string](variables, "invalidProcedureMessage"); err == nil {
+        return []FieldError{{Field: "procedureData", Message: message}}
+    }
+    return []FieldError{}
+}
+
+// CorrectProcedure implements PUT /api/corrections/:taskId
+// The corrected procedure replaces the stored one, then the task is completed with it as the
+// new procedureData, which is validated again. The procedure is the one the process was
+// started for, named by the business key of the process instance.
+func (o *implWorkflowAPI) CorrectProcedure(c *gin.Context) {
+    var corrected Procedure
+    if err := c.ShouldBindJSON(&corrected); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request", "error": err.Error()})
+        return
+    }
+
+    client := getCamundaClient(c)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    task, err := client.UserTask(ctx, c.Param("taskId"))
+    if err != nil {
+        workflowFailure(c, err, "Correction")
+        return
+    }
+    if task.TaskDefinitionKey != correctionTaskKey {
+        c.JSON(http.StatusNotFound, gin.H{"message": "Correction not found"})
+        return
+    }
+    instance, err := client.ProcessInstance(ctx, task.ProcessInstanceId)
+    if err != nil {
+        workflowFailure(c, err, "Correction")
+        return
+    }
+    id := instance.BusinessKey
+    variables, err := client.UserTaskVariables(ctx, task.Id, "procedureData")
+    if err != nil {
+        workflowFailure(c, err, "Correction")
+        return
+    }
+    // the procedure data of the task must describe the procedure the process was started for
+    submitted := submittedProcedure(task.Id, variables)
+    if id == "" || submitted != nil && submitted.Id != "" && submitted.Id != id {
+        log.Printf("Correction %s of process %s does not name its procedure consistently", task.Id, instance.Id)
+        c.JSON(http.StatusConflict, gin.H{"message": "Correction is not linked to a procedure"})
+        return
+    }
+
+    existing, version, err := getProcedureDB(c).FindVersionedDocument(ctx, id)
+    if err != nil {
+        if err == db_service.ErrNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"message": "Procedure not found"})
+        } else {
+            log.Println("FindDocument error:", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"message": "Internal error"})
+        }
+        return
+    }
+    if !ifMatchSatisfied(c, version) {
+        c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Procedure has been modified"})
+        return
+    }
+    before := *existing
+    updated, result, status := replaceProcedure(c, existing, &corrected)
+    if updated == nil {
+        c.JSON(status, result)
+        return
+    }
+    updated.WorkflowStatus = WorkflowValidating
+    version, err = storeProcedure(ctx, c, &before, updated, version)
+    switch err {
+    case nil:
+    case db_service.ErrVersionMismatch:
+        c.JSON(http.StatusPreconditionFailed, gin.H{"message": "Procedure has been modified"})
+        return
+    default:
+        log.Println("UpdateDocument error:", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update procedure"})
+        return
+    }
+
+    // the stored procedure is already corrected, a failed completion can be retried with the same request
+    err = client.CompleteUserTask(ctx, task.Id, map[string]any{"procedureData": updated, "procedureId": updated.Id})
+    if err != nil {
+        workflowFailure(c, err, "Correction")
+        return
+    }
+    log.Printf("Procedure %s corrected, process %s validates it again", updated.Id, task.ProcessInstanceId)
+    c.Header("ETag", etag(version))
+    c.JSON(http.StatusOK, updated)
+}
+
+// updateWorkflowStatus sets the workflow status of the stored procedure with the given id, reported
+// by the process instance with the given id.
+func updateWorkflowStatus(ctx context.Context, c *gin.Context, id string, processInstanceId string, status string) error {
+    existing, version, err := getProcedureDB(c).FindVersionedDocument(ctx, id)
+    if err != nil || existing.WorkflowStatus == status {
+        return err
+    }
+    if err := checkWorkflowUpdate(existing, processInstanceId, status); err != nil {
+        return err
+    }
+    updated := *existing
+    updated.WorkflowStatus = status
+    _, err = storeProcedure(ctx, c, existing, &updated, version)
+    return err
+}
+
+// UpdateProcedureWorkflowStatus implements PUT /api/procedures/:procedureId/workflow/status
+// The worker reports the progress of the process with it, authorized by its token.
+func (o *implWorkflowAPI) UpdateProcedureWorkflowStatus(c *gin.Context) {
+    if !authorizeWorker(c) {
+        return
+    }
+    withProcedureByID(c, func(_ *gin.Context, existing *Procedure) (*Procedure, interface{}, int) {
+        var update WorkflowStatusUpdate
+        if err := c.ShouldBindJSON(&update); err != nil {
+            return nil, gin.H{"message": "Invalid request", "error": err.Error()}, http.StatusBadRequest
+        }
+        if !workflowStatuses[update.Status] {
+            return nil, gin.H{"message": fmt.Sprintf("Unknown workflow status %q", update.Status)}, http.StatusBadRequest
+        }
+        if err := checkWorkflowUpdate(existing, update.ProcessInstanceId, update.Status); err != nil {
+            log.Println(err)
+            return nil, gin.H{"message": "Workflow status conflict", "error": err.Error()}, http.StatusConflict
+        }
+        updated := *existing
+        updated.WorkflowStatus = update.Status
+        if existing.ProcessInstanceId == "" {
+            // the worker reported the process before the procedure recorded it
+            updated.ProcessInstanceId = update.ProcessInstanceId
+        }
+        if updated == *existing {
+            return nil, existing, http.StatusOK
+        }
+        return &updated, &updated, http.StatusOK
+    })
+}
+
+// GetProcedureWorkflow implements GET /api/procedures/:procedureId/workflow
+func (o *implWorkflowAPI) GetProcedureWorkflow(c *gin.Context) {
+    withProcedureByID(c, func(_ *gin.Context, p *Procedure) (*Procedure, interface{}, int) {
+        workflow := ProcedureWorkflow{
+            ProcedureId:       p.Id,
+            ProcessInstanceId: p.ProcessInstanceId,
+            Status:            p.WorkflowStatus,
+            Activities:        []WorkflowActivity{},
+        }
+        if p.ProcessInstanceId == "" {
+            return nil, workflow, http.StatusOK
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        defer cancel()
+        history, err := getCamundaClient(c).ActivityHistory(ctx, p.ProcessInstanceId)
+        if err != nil {
+            log.Println("Camunda error:", err)
+            return nil, gin.H{"message": "Workflow engine unavailable"}, http.StatusBadGateway
+        }
+        for _, activity := range history {
+            workflow.Activities = append(workflow.Activities, WorkflowActivity{
+                ActivityId: activity.ActivityId,
+                Name:       activity.ActivityName,
+                Type:       activity.ActivityType,
+                Assignee:   activity.Assignee,
+                StartTime:  activity.StartTime,
+                EndTime:    activity.EndTime,
+                Canceled:   activity.Canceled,
+            })
+        }
+        return nil, workflow, http.StatusOK
+    })
+}
@@ -0,0 +1,545 @@
+package ambulance
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/suite"
+    "github.com/wac-project/wac-api/internal/db_service"
+    "github.com/wac-project/wac-api/pkg/camunda"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// fakeTaskService serves the task API of the workflow engine for the tasks it holds and records completions.
+// It also starts processes and serves the process instances and their activity history.
+type fakeTaskService struct {
+    instances map[string]*camunda.ProcessInstance
+    tasks     map[string]*camunda.UserTask
+    variables map[string]camunda.Variables
+    completed map[string]map[string]camunda.Variable
+    started   map[string]map[string]camunda.Variable
+    history   map[string][]camunda.ActivityInstance
+    // onStart, if set, is called with the business key of a process before its start is answered
+    onStart func(businessKey string)
+}
+
+func (e *fakeTaskService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+    if parts[0] == "process-definition" {
+        var body struct {
+            BusinessKey string                      `json:"businessKey"`
+            Variables   map[string]camunda.Variable `json:"variables"`
+        }
+        json.NewDecoder(r.Body).Decode(&body)
+        e.started[body.BusinessKey] = body.Variables
+        if e.onStart != nil {
+            e.onStart(body.BusinessKey)
+        }
+        instance := &camunda.ProcessInstance{Id: "pi-" + body.BusinessKey, BusinessKey: body.BusinessKey}
+        e.instances[instance.Id] = instance
+        json.NewEncoder(w).Encode(instance)
+        return
+    }
+    if len(parts) == 1 && parts[0] == "process-instance" {
+        instances := []camunda.ProcessInstance{}
+        for _, instance := range e.instances {
+            if instance.BusinessKey == r.URL.Query().Get("businessKey") {
+                instances = append(instances, *instance)
+            }
+        }
+        json.NewEncoder(w).Encode(instances)
+        return
+    }
+    if parts[0] == "process-instance" {
+        instance, ok := e.instances[parts[1]]
+        if !ok {
+            w.WriteHeader(http.StatusNotFound)
+            w.Write([]byte(`{"type":"InvalidRequestException","message":"No matching process instance"}`))
+            return
+        }
+        json.NewEncoder(w).Encode(instance)
+        return
+    }
+    if parts[0] == "history" {
+        json.NewEncoder(w).Encode(e.history[r.URL.Query().Get("processInstanceId")])
+        return
+    }
+    if len(parts) == 1 && parts[0] == "task" {
+        tasks := []camunda.UserTask{}
+        for _, task := range e.tasks {
+            if task.TaskDefinitionKey == r.URL.Query().Get("taskDefinitionKey") {
+                tasks = append(tasks, *task)
+            }
+        }
+        json.NewEncoder(w).Encode(tasks)
+        return
+    }
+    task, ok := e.tasks[parts[1]]
+    if !ok {
+        w.WriteHeader(http.StatusNotFound)
+        w.Write([]byte(`{"type":"InvalidRequestException","message":"No matching task"}`))
+        return
+    }
+    switch {
+    case len(parts) == 2:
+        json.NewEncoder(w).Encode(task)
+    case parts[2] == "variables":
+        json.NewEncoder(w).Encode(e.variables[task.Id])
+    case parts[2] == "claim":
+        var body struct {
+            UserId string `json:"userId"`
+        }
+        json.NewDecoder(r.Body).Decode(&body)
+        task.Assignee = body.UserId
+        w.WriteHeader(http.StatusNoContent)
+    case parts[2] == "complete":
+        var body struct {
+            Variables map[string]camunda.Variable `json:"variables"`
+        }
+        json.NewDecoder(r.Body).Decode(&body)
+        e.completed[task.Id] = body.Variables
+        delete(e.tasks, task.Id)
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+type WorkflowSuite struct {
+    suite.Suite
+    engine     *fakeTaskService
+    server     *httptest.Server
+    ambulances db_service.DbService[Ambulance]
+    procedures db_service.DbService[Procedure]
+    events     *kafka.MemoryPublisher
+    sut        implWorkflowAPI
+}
+
+func TestWorkflowSuite(t *testing.T) {
+    suite.Run(t, new(WorkflowSuite))
+}
+
+func (suite *WorkflowSuite) SetupTest() {
+    procedure := suite.variable(Procedure{Id: "p1", Patient: "Peter Horváth", Price: 120})
+    suite.engine = &fakeTaskService{
+        instances: map[string]*camunda.ProcessInstance{
+            "pi3": {Id: "pi3", BusinessKey: "p1"},
+            "pi4": {Id: "pi4", BusinessKey: "p1"},
+        },
+        tasks: map[string]*camunda.UserTask{
+            "t1": {Id: "t1", TaskDefinitionKey: approvalTaskKey, ProcessInstanceId: "pi1"},
+            "t2": {Id: "t2", TaskDefinitionKey: approvalTaskKey, ProcessInstanceId: "pi2", Assignee: "mudr.novak"},
+            "t3": {Id: "t3", TaskDefinitionKey: correctionTaskKey, ProcessInstanceId: "pi3"},
+            "t4": {Id: "t4", TaskDefinitionKey: correctionTaskKey, ProcessInstanceId: "pi4"},
+        },
+        variables: map[string]camunda.Variables{
+            "t1": {"procedureData": procedure},
+            "t3": {"procedureData": procedure, "validationErrors": suite.variable([]FieldError{{Field: "payer", Message: "payer is required"}})},
+            "t4": {"invalidProcedureMessage": suite.variable("missing process variable procedureData")},
+        },
+        completed: map[string]map[string]camunda.Variable{},
+        started:   map[string]map[string]camunda.Variable{},
+        history: map[string][]camunda.ActivityInstance{
+            "pi1": {
+                {ActivityId: "StartEvent_1", ActivityType: "startEvent", StartTime: "2025-05-01T10:00:00.000+0000", EndTime: "2025-05-01T10:00:00.000+0000"},
+                {ActivityId: approvalTaskKey, ActivityName: "Approve Submission", ActivityType: "userTask", Assignee: "mudr.novak", StartTime: "2025-05-01T10:00:01.000+0000"},
+            },
+        },
+    }
+    suite.server = httptest.NewServer(suite.engine)
+
+    suite.ambulances = db_service.NewMemoryService[Ambulance]()
+    suite.Require().NoError(suite.ambulances.CreateDocument(context.Background(), "amb1", &Ambulance{Id: "amb1"}))
+    suite.procedures = db_service.NewMemoryService[Procedure]()
+    suite.Require().NoError(suite.procedures.CreateDocument(context.Background(), "p1", &Procedure{Id: "p1", Patient: "Peter Horváth", Price: 120, AmbulanceId: "amb1"}))
+    suite.events = kafka.NewMemoryPublisher()
+}
+
+func (suite *WorkflowSuite) variable(value any) camunda.Variable {
+    variable, err := camunda.NewVariable(value)
+    suite.Require().NoError(err)
+    return variable
+}
+
+func (suite *WorkflowSuite) TearDownTest() {
+    suite.server.Close()
+}
+
+// request prepares a test context for the task taskId with the given body.
+func (suite *WorkflowSuite) request(method string, taskId string, body string) (*gin.Context, *httptest.ResponseRecorder) {
+    gin.SetMode(gin.TestMode)
+    recorder := httptest.NewRecorder()
+    ctx, _ := gin.CreateTestContext(recorder)
+    ctx.Set("camunda_client", camunda.NewClient(suite.server.URL))
+    ctx.Set("db_service_ambulance", suite.ambulances)
+    ctx.Set("db_service_procedure", suite.procedures)
+    ctx.Set("event_publisher", suite.events)
+    ctx.Set("worker_token", "worker-token")
+    ctx.Params = []gin.Param{{Key: "taskId", Value: taskId}}
+    ctx.Request = httptest.NewRequest(method, "/api/tasks/"+taskId, strings.NewReader(body))
+    ctx.Request.Header.Set("Content-Type", "application/json")
+    return ctx, recorder
+}
+
+func (suite *WorkflowSuite) Test_GetApprovals_ReturnsTasksWithProcedure() {
+    ctx, recorder := suite.request("GET", "", "")
+    suite.sut.GetApprovals(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    var approvals []Approval
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &approvals))
+    suite.Require().Len(approvals, 2)
+    byTask := map[string]Approval{}
+    for _, approval := range approvals {
+        byTask[approval.TaskId] = approval
+    }
+    suite.Equal("pi1", byTask["t1"].ProcessInstanceId)
+    suite.Require().NotNil(byTask["t1"].Procedure)
+    suite.Equal("Peter Horváth", byTask["t1"].Procedure.Patient)
+    suite.Equal("mudr.novak", byTask["t2"].Assignee)
+    suite.Nil(byTask["t2"].Procedure)
+}
+
+func (suite *WorkflowSuite) Test_ClaimApproval_AssignsTask() {
+    ctx, recorder := suite.request("POST", "t1", `{"user_id":"mudr.kral"}`)
+    suite.sut.ClaimApproval(ctx)
+
+    suite.Equal(http.StatusNoContent, recorder.Code)
+    suite.Equal("mudr.kral", suite.engine.tasks["t1"].Assignee)
+}
+
+func (suite *WorkflowSuite) Test_ApproveSubmission_CompletesTask() {
+    ctx, recorder := suite.request("POST", "t2", `{"user_id":"mudr.novak","reason":"ok"}`)
+    suite.sut.ApproveSubmission(ctx)
+
+    suite.Equal(http.StatusNoContent, recorder.Code)
+    variables := camunda.Variables(suite.engine.completed["t2"])
+    approved, err := camunda.Get[bool](variables, "approved")
+    suite.Require().NoError(err)
+    suite.True(approved)
+    approvedBy, _ := camunda.Get[string](variables, "approvedBy")
+    suite.Equal("mudr.novak", approvedBy)
+}
+
+func (suite *WorkflowSuite) Test_ApproveSubmission_ClaimedByAnotherUser_ReturnsConflict() {
+    ctx, recorder := suite.request("POST", "t2", `{"user_id":"mudr.kral"}`)
+    suite.sut.ApproveSubmission(ctx)
+
+    suite.Equal(http.StatusConflict, recorder.Code)
+    suite.Empty(suite.engine.completed)
+}
+
+func (suite *WorkflowSuite) Test_RejectSubmission_RequiresReason() {
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    stored.ProcessInstanceId, stored.WorkflowStatus = "pi1", WorkflowAwaitingApproval
+    suite.Require().NoError(suite.procedures.UpdateDocument(context.Background(), "p1", stored))
+
+    ctx, recorder := suite.request("POST", "t1", `{"user_id":"mudr.kral"}`)
+    suite.sut.RejectSubmission(ctx)
+    suite.Equal(http.StatusBadRequest, recorder.Code)
+
+    ctx, recorder = suite.request("POST", "t1", `{"user_id":"mudr.kral","reason":"duplicate submission"}`)
+    suite.sut.RejectSubmission(ctx)
+    suite.Equal(http.StatusNoContent, recorder.Code)
+    approved, err := camunda.Get[bool](suite.engine.completed["t1"], "approved")
+    suite.Require().NoError(err)
+    suite.False(approved)
+    stored, err = suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal(WorkflowRejected, stored.WorkflowStatus)
+}
+
+func (suite *WorkflowSuite) Test_ApproveSubmission_OtherTasks_ReturnNotFound() {
+    for _, taskId := range []string{"t3", "missing"} {
+        ctx, recorder := suite.request("POST", taskId, `{"user_id":"mudr.kral"}`)
+        suite.sut.ApproveSubmission(ctx)
+        suite.Equal(http.StatusNotFound, recorder.Code, taskId)
+    }
+    suite.Empty(suite.engine.completed)
+}
+
+func (suite *WorkflowSuite) Test_GetApprovals_EngineUnavailable_ReturnsBadGateway() {
+    suite.server.Close()
+    ctx, recorder := suite.request("GET", "", "")
+    suite.sut.GetApprovals(ctx)
+    suite.Equal(http.StatusBadGateway, recorder.Code)
+}
+
+func (suite *WorkflowSuite) Test_GetCorrections_ReturnsValidationErrors() {
+    ctx, recorder := suite.request("GET", "", "")
+    suite.sut.GetCorrections(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    var corrections []Correction
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &corrections))
+    suite.Require().Len(corrections, 2)
+    byTask := map[string]Correction{}
+    for _, correction := range corrections {
+        byTask[correction.TaskId] = correction
+    }
+    suite.Equal("p1", byTask["t3"].Procedure.Id)
+    suite.Equal([]FieldError{{Field: "payer", Message: "payer is required"}}, byTask["t3"].ValidationErrors)
+    suite.Nil(byTask["t4"].Procedure)
+    suite.Equal([]FieldError{{Field: "procedureData", Message: "missing process variable procedureData"}}, byTask["t4"].ValidationErrors)
+}
+
+func (suite *WorkflowSuite) Test_CorrectProcedure_StoresProcedureAndCompletesTask() {
+    ctx, recorder := suite.request("PUT", "t3", `{"patient":"Peter Horváth","visit_type":"checkup","price":120,"payer":"VSZP","ambulance_id":"amb1"}`)
+    suite.sut.CorrectProcedure(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal("VSZP", stored.Payer)
+    suite.Equal(WorkflowValidating, stored.WorkflowStatus)
+    suite.Require().Len(suite.events.Events(), 1)
+    suite.Equal("procedure_updated", suite.events.Events()[0].Type)
+
+    submitted, err := camunda.Get[Procedure](suite.engine.completed["t3"], "procedureData")
+    suite.Require().NoError(err)
+    suite.Equal(*stored, submitted)
+    procedureId, _ := camunda.Get[string](suite.engine.completed["t3"], "procedureId")
+    suite.Equal("p1", procedureId)
+}
+
+func (suite *WorkflowSuite) Test_CorrectProcedure_RejectsInvalidCorrections() {
+    ctx, recorder := suite.request("PUT", "t3", `{"id":"p2","patient":"Peter Horváth","visit_type":"checkup","price":120,"ambulance_id":"amb1"}`)
+    suite.sut.CorrectProcedure(ctx)
+    suite.Equal(http.StatusBadRequest, recorder.Code)
+
+    ctx, recorder = suite.request("PUT", "t3", `{"patient":"Peter Horváth","visit_type":"checkup","price":120,"ambulance_id":"amb404"}`)
+    suite.sut.CorrectProcedure(ctx)
+    suite.Equal(http.StatusUnprocessableEntity, recorder.Code)
+    suite.Empty(suite.events.Events())
+    suite.Empty(suite.engine.completed)
+}
+
+func (suite *WorkflowSuite) Test_CorrectProcedure_UsesProcedureOfProcess() {
+    // t4 has no usable procedureData, the procedure is still the one its process was started for
+    ctx, recorder := suite.request("PUT", "t4", `{"id":"p2","patient":"Jana Kováčová","visit_type":"checkup","price":40,"payer":"VSZP","ambulance_id":"amb1"}`)
+    suite.sut.CorrectProcedure(ctx)
+    suite.Equal(http.StatusBadRequest, recorder.Code)
+
+    ctx, recorder = suite.request("PUT", "t4", `{"patient":"Peter Horváth","visit_type":"checkup","price":120,"payer":"VSZP","ambulance_id":"amb1"}`)
+    suite.sut.CorrectProcedure(ctx)
+    suite.Equal(http.StatusOK, recorder.Code)
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal("VSZP", stored.Payer)
+}
+
+func (suite *WorkflowSuite) Test_CorrectProcedure_InconsistentProcess_ReturnsConflict() {
+    // t5 holds the data of p1 in the process of p2, t6 belongs to a process started without a business key
+    suite.engine.instances["pi5"] = &camunda.ProcessInstance{Id: "pi5", BusinessKey: "p2"}
+    suite.engine.instances["pi6"] = &camunda.ProcessInstance{Id: "pi6"}
+    suite.engine.tasks["t5"] = &camunda.UserTask{Id: "t5", TaskDefinitionKey: correctionTaskKey, ProcessInstanceId: "pi5"}
+    suite.engine.tasks["t6"] = &camunda.UserTask{Id: "t6", TaskDefinitionKey: correctionTaskKey, ProcessInstanceId: "pi6"}
+    suite.engine.variables["t5"] = suite.engine.variables["t3"]
+
+    for _, taskId := range []string{"t5", "t6"} {
+        ctx, recorder := suite.request("PUT", taskId, `{"patient":"Peter Horváth","visit_type":"checkup","price":120,"payer":"VSZP","ambulance_id":"amb1"}`)
+        suite.sut.CorrectProcedure(ctx)
+        suite.Equal(http.StatusConflict, recorder.Code, taskId)
+    }
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Empty(stored.Payer)
+    suite.Empty(suite.events.Events())
+    suite.Empty(suite.engine.completed)
+}
+
+// procedureRequest prepares a test context for the procedure procedureId with the given body.
+func (suite *WorkflowSuite) procedureRequest(method string, procedureId string, body string) (*gin.Context, *httptest.ResponseRecorder) {
+    ctx, recorder := suite.request(method, "", body)
+    ctx.Params = []gin.Param{{Key: "procedureId", Value: procedureId}}
+    return ctx, recorder
+}
+
+func (suite *WorkflowSuite) Test_CreateProcedure_StoresSubmittedProcedure() {
+    ctx, recorder := suite.procedureRequest("POST", "", `{"id":"p2","patient":"Jana Kováčová","visit_type":"checkup","price":40,"ambulance_id":"amb1","timestamp":"2026-01-10T01:30:00+02:00","process_instance_id":"forged"}`)
+    (&implProcedureAPI{}).CreateProcedure(ctx)
+
+    suite.Equal(http.StatusCreated, recorder.Code)
+    suite.Equal(`"1"`, recorder.Header().Get("ETag"))
+    stored, err := suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Empty(stored.ProcessInstanceId)
+    suite.Equal(WorkflowSubmitted, stored.WorkflowStatus)
+    suite.Equal("2026-01-09T23:30:00Z", stored.Timestamp)
+    // the process is started in the background
+    suite.Empty(suite.engine.started)
+}
+
+// submit creates the procedure p2 through the API.
+func (suite *WorkflowSuite) submit() {
+    ctx, recorder := suite.procedureRequest("POST", "", `{"id":"p2","patient":"Jana Kováčová","visit_type":"checkup","price":40,"ambulance_id":"amb1"}`)
+    (&implProcedureAPI{}).CreateProcedure(ctx)
+    suite.Require().Equal(http.StatusCreated, recorder.Code)
+}
+
+func (suite *WorkflowSuite) starter(config SubmissionConfig) *SubmissionStarter {
+    return NewSubmissionStarter(suite.procedures, suite.events, camunda.NewClient(suite.server.URL), config)
+}
+
+func (suite *WorkflowSuite) Test_SubmissionStarter_StartsProcess() {
+    suite.submit()
+    suite.Require().NoError(suite.starter(SubmissionConfig{}).StartWaiting(context.Background()))
+
+    stored, err := suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Equal("pi-p2", stored.ProcessInstanceId)
+    suite.Equal(WorkflowSubmitted, stored.WorkflowStatus)
+    submitted, err := camunda.Get[Procedure](suite.engine.started["p2"], "procedureData")
+    suite.Require().NoError(err)
+    suite.Equal("Jana Kováčová", submitted.Patient)
+    suite.Len(suite.events.Events(), 2)
+}
+
+func (suite *WorkflowSuite) Test_SubmissionStarter_LinksRunningProcess() {
+    // a previous start timed out, but the engine started the process
+    suite.engine.instances["pi9"] = &camunda.ProcessInstance{Id: "pi9", BusinessKey: "p2"}
+    suite.submit()
+    suite.Require().NoError(suite.starter(SubmissionConfig{}).StartWaiting(context.Background()))
+
+    stored, err := suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Equal("pi9", stored.ProcessInstanceId)
+    suite.Empty(suite.engine.started)
+}
+
+func (suite *WorkflowSuite) Test_SubmissionStarter_WorkerReportedFirst_LinksProcess() {
+    // the worker reports the first step of the process before the start is answered
+    suite.engine.onStart = func(businessKey string) {
+        if stored, err := suite.procedures.FindDocument(context.Background(), businessKey); err == nil {
+            stored.WorkflowStatus = WorkflowValidating
+            suite.procedures.UpdateDocument(context.Background(), businessKey, stored)
+        }
+    }
+    suite.submit()
+    suite.Require().NoError(suite.starter(SubmissionConfig{}).StartWaiting(context.Background()))
+
+    stored, err := suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Equal("pi-p2", stored.ProcessInstanceId)
+    suite.Equal(WorkflowValidating, stored.WorkflowStatus)
+}
+
+func (suite *WorkflowSuite) Test_SubmissionStarter_EngineUnavailable_RetriesThenMarksFailed() {
+    suite.submit()
+    suite.server.Close()
+    starter := suite.starter(SubmissionConfig{Interval: time.Millisecond, MaxAttempts: 2})
+
+    suite.Require().NoError(starter.StartWaiting(context.Background()))
+    stored, err := suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Equal(WorkflowSubmitted, stored.WorkflowStatus)
+
+    time.Sleep(5 * time.Millisecond)
+    suite.Require().NoError(starter.StartWaiting(context.Background()))
+    stored, err = suite.procedures.FindDocument(context.Background(), "p2")
+    suite.Require().NoError(err)
+    suite.Empty(stored.ProcessInstanceId)
+    suite.Equal(WorkflowFailed, stored.WorkflowStatus)
+}
+
+// statusRequest prepares a workflow status update of the worker for the procedure procedureId.
+func (suite *WorkflowSuite) statusRequest(procedureId string, body string) (*gin.Context, *httptest.ResponseRecorder) {
+    ctx, recorder := suite.procedureRequest("PUT", procedureId, body)
+    ctx.Request.Header.Set("Authorization", "Bearer worker-token")
+    return ctx, recorder
+}
+
+func (suite *WorkflowSuite) Test_UpdateProcedureWorkflowStatus_StoresKnownStatus() {
+    ctx, recorder := suite.statusRequest("p1", `{"status":"validating"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal(WorkflowValidating, stored.WorkflowStatus)
+    suite.Require().Len(suite.events.Events(), 1)
+
+    ctx, recorder = suite.statusRequest("p1", `{"status":"lost"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusBadRequest, recorder.Code)
+    suite.Len(suite.events.Events(), 1)
+}
+
+func (suite *WorkflowSuite) Test_UpdateProcedureWorkflowStatus_LinksUnrecordedProcess() {
+    ctx, recorder := suite.statusRequest("p1", `{"status":"validating","process_instance_id":"pi7"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusOK, recorder.Code)
+
+    // another process cannot report for the procedure
+    ctx, recorder = suite.statusRequest("p1", `{"status":"awaiting_approval","process_instance_id":"pi8"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusConflict, recorder.Code)
+
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal("pi7", stored.ProcessInstanceId)
+    suite.Equal(WorkflowValidating, stored.WorkflowStatus)
+    suite.Len(suite.events.Events(), 1)
+}
+
+func (suite *WorkflowSuite) Test_UpdateProcedureWorkflowStatus_KeepsLaterStep() {
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    stored.ProcessInstanceId, stored.WorkflowStatus = "pi1", WorkflowCompleted
+    suite.Require().NoError(suite.procedures.UpdateDocument(context.Background(), "p1", stored))
+
+    // the running status of a retried task arrives late
+    ctx, recorder := suite.statusRequest("p1", `{"status":"validating","process_instance_id":"pi1"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusConflict, recorder.Code)
+
+    // repeating the current status changes nothing
+    ctx, recorder = suite.statusRequest("p1", `{"status":"completed","process_instance_id":"pi1"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusOK, recorder.Code)
+
+    stored, err = suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Equal(WorkflowCompleted, stored.WorkflowStatus)
+    suite.Empty(suite.events.Events())
+}
+
+func (suite *WorkflowSuite) Test_UpdateProcedureWorkflowStatus_RequiresWorkerToken() {
+    ctx, recorder := suite.procedureRequest("PUT", "p1", `{"status":"validating"}`)
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusUnauthorized, recorder.Code)
+
+    ctx, recorder = suite.statusRequest("p1", `{"status":"validating"}`)
+    ctx.Set("worker_token", "")
+    suite.sut.UpdateProcedureWorkflowStatus(ctx)
+    suite.Equal(http.StatusForbidden, recorder.Code)
+
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    suite.Empty(stored.WorkflowStatus)
+    suite.Empty(suite.events.Events())
+}
+
+func (suite *WorkflowSuite) Test_GetProcedureWorkflow_ReturnsActivityHistory() {
+    stored, err := suite.procedures.FindDocument(context.Background(), "p1")
+    suite.Require().NoError(err)
+    stored.ProcessInstanceId, stored.WorkflowStatus = "pi1", WorkflowAwaitingApproval
+    suite.Require().NoError(suite.procedures.UpdateDocument(context.Background(), "p1", stored))
+
+    ctx, recorder := suite.procedureRequest("GET", "p1", "")
+    suite.sut.GetProcedureWorkflow(ctx)
+
+    suite.Equal(http.StatusOK, recorder.Code)
+    var workflow ProcedureWorkflow
+    suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &workflow))
+    suite.Equal(WorkflowAwaitingApproval, workflow.Status)
+    suite.Require().Len(workflow.Activities, 2)
+    suite.Equal(approvalTaskKey, workflow.Activities[1].ActivityId)
+    suite.Equal("mudr.novak", workflow.Activities[1].Assignee)
+    suite.Empty(workflow.Activities[1].EndTime)
+}
@@ -0,0 +1,29 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type Approval struct {
+
+    // Identifier of the Approve Submission task.
+    TaskId string `json:"task_id"`
+
+    // Identifier of the process instance handling the procedure.
+    ProcessInstanceId string `json:"process_instance_id"`
+
+    // User who claimed the approval, empty while it is unclaimed.
+    Assignee string `json:"assignee,omitempty"`
+
+    // Time the approval was requested.
+    Created string `json:"created"`
+
+    // Procedure data submitted for approval.
+    Procedure *Procedure `json:"procedure,omitempty"`
+}
@@ -0,0 +1,20 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type ApprovalDecision struct {
+
+    // User who claims, approves or rejects the submission.
+    UserId string `json:"user_id"`
+
+    // Reason for the decision, required when rejecting.
+    Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,29 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type Correction struct {
+
+    // Identifier of the Correct Invalid Data task.
+    TaskId string `json:"task_id"`
+
+    // Identifier of the process instance handling the procedure.
+    ProcessInstanceId string `json:"process_instance_id"`
+
+    // Time the correction was requested.
+    Created string `json:"created"`
+
+    // Procedure data as submitted, absent if it could not be read.
+    Procedure *Procedure `json:"procedure,omitempty"`
+
+    // Problems found in the procedure data.
+    ValidationErrors []FieldError `json:"validation_errors"`
+}
@@ -12,5 +12,30 @@ package ambulance
 
 type GetAmbulanceSummary200Response struct {
 
-	TotalCost float32 `json:"totalCost,omitempty"`
+	// Identifier of the summarized ambulance.
+	AmbulanceId string `json:"ambulanceId"`
+
+	// Start of the summarized period (inclusive), if requested.
+	From string `json:"from,omitempty"`
+
+	// End of the summarized period (exclusive), if requested.
+	To string `json:"to,omitempty"`
+
+	// Number of procedures in the period.
+	ProcedureCount int64 `json:"procedureCount"`
+
+	// Total price of the procedures.
+	TotalCost float64 `json:"totalCost"`
+
+	// Total amount of payments made for the procedures.
+	TotalPaid float64 `json:"totalPaid"`
+
+	// Part of the total cost not yet covered by payments.
+	OutstandingBalance float64 `json:"outstandingBalance"`
+
+	// Number of procedures per visit type.
+	VisitTypeCounts map[string]int64 `json:"visitTypeCounts"`
+
+	// Number of procedures per payer.
+	PayerCounts map[string]int64 `json:"payerCounts"`
 }
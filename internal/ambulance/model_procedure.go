@@ -38,4 +38,10 @@ type Procedure struct {
 
     // Date and time of the procedure in ISO 8601 format.
     Timestamp string `json:"timestamp,omitempty"`
+
+    // Identifier of the process instance handling the submission of the procedure.
+    ProcessInstanceId string `json:"process_instance_id,omitempty"`
+
+    // Step of the submission workflow the procedure is in, set by the workflow.
+    WorkflowStatus string `json:"workflow_status,omitempty"`
 }
@@ -0,0 +1,26 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type ProcedureWorkflow struct {
+
+    // Identifier of the procedure.
+    ProcedureId string `json:"procedure_id"`
+
+    // Identifier of the process instance handling the submission, absent if none was started.
+    ProcessInstanceId string `json:"process_instance_id,omitempty"`
+
+    // Step of the submission workflow the procedure is in.
+    Status string `json:"status"`
+
+    // Activities of the process instance in the order they started.
+    Activities []WorkflowActivity `json:"activities"`
+}
@@ -0,0 +1,35 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type WorkflowActivity struct {
+
+    // Identifier of the activity in the BPMN process, e.g. ValidateData.
+    ActivityId string `json:"activity_id"`
+
+    // Name of the activity.
+    Name string `json:"name,omitempty"`
+
+    // Type of the activity, e.g. userTask or serviceTask.
+    Type string `json:"type"`
+
+    // User who worked on a user task.
+    Assignee string `json:"assignee,omitempty"`
+
+    // Time the activity started.
+    StartTime string `json:"start_time"`
+
+    // Time the activity ended, absent while it runs.
+    EndTime string `json:"end_time,omitempty"`
+
+    // Whether the activity was cancelled, e.g. by an error boundary event.
+    Canceled bool `json:"canceled,omitempty"`
+}
@@ -0,0 +1,20 @@
+/*
+ * Hospital Management API
+ *
+ * API for managing hospital ambulances, procedures, and payments.
+ *
+ * API version: 1.0.0
+ * Contact: xkokavecs@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package ambulance
+
+type WorkflowStatusUpdate struct {
+
+    // New workflow status of the procedure.
+    Status string `json:"status"`
+
+    // Identifier of the process instance reporting the status, it links a procedure whose process was not recorded.
+    ProcessInstanceId string `json:"process_instance_id,omitempty"`
+}
@@ -61,6 +61,7 @@
 	 AmbulanceManagementAPI   AmbulanceManagementAPI
 	 PaymentManagementAPI     PaymentManagementAPI
 	 ProcedureManagementAPI   ProcedureManagementAPI
+	 WorkflowManagementAPI    WorkflowManagementAPI
  }
  
  // getRoutes defines the full route list and their handler bindings.
@@ -73,6 +74,7 @@
 		 {"GetAmbulanceSummary", http.MethodGet, "/api/ambulances/:ambulanceId/summary", handleFunctions.AmbulanceManagementAPI.GetAmbulanceSummary},
 		 {"GetAmbulances", http.MethodGet, "/api/ambulances", handleFunctions.AmbulanceManagementAPI.GetAmbulances},
 		 {"UpdateAmbulance", http.MethodPut, "/api/ambulances/:ambulanceId", handleFunctions.AmbulanceManagementAPI.UpdateAmbulance},
+		 {"PatchAmbulance", http.MethodPatch, "/api/ambulances/:ambulanceId", handleFunctions.AmbulanceManagementAPI.PatchAmbulance},
 		{"GetProceduresByAmbulance", http.MethodGet, "/api/ambulances/:ambulanceId/procedures", handleFunctions.AmbulanceManagementAPI.GetProceduresByAmbulance},
 		
 		 // Payment routes
@@ -81,6 +83,7 @@
 		 {"GetPaymentById", http.MethodGet, "/api/payments/:paymentId", handleFunctions.PaymentManagementAPI.GetPaymentById},
 		 {"GetPayments", http.MethodGet, "/api/payments", handleFunctions.PaymentManagementAPI.GetPayments},
 		 {"UpdatePayment", http.MethodPut, "/api/payments/:paymentId", handleFunctions.PaymentManagementAPI.UpdatePayment},
+		 {"PatchPayment", http.MethodPatch, "/api/payments/:paymentId", handleFunctions.PaymentManagementAPI.PatchPayment},
  
 		 // Procedure routes
 		 {"CreateProcedure", http.MethodPost, "/api/procedures", handleFunctions.ProcedureManagementAPI.CreateProcedure},
@@ -88,6 +91,17 @@
 		 {"GetProcedureById", http.MethodGet, "/api/procedures/:procedureId", handleFunctions.ProcedureManagementAPI.GetProcedureById},
 		 {"GetProcedures", http.MethodGet, "/api/procedures", handleFunctions.ProcedureManagementAPI.GetProcedures},
 		 {"UpdateProcedure", http.MethodPut, "/api/procedures/:procedureId", handleFunctions.ProcedureManagementAPI.UpdateProcedure},
+		 {"PatchProcedure", http.MethodPatch, "/api/procedures/:procedureId", handleFunctions.ProcedureManagementAPI.PatchProcedure},
+ 
+		 // Workflow routes
+		 {"GetApprovals", http.MethodGet, "/api/approvals", handleFunctions.WorkflowManagementAPI.GetApprovals},
+		 {"ClaimApproval", http.MethodPost, "/api/approvals/:taskId/claim", handleFunctions.WorkflowManagementAPI.ClaimApproval},
+		 {"ApproveSubmission", http.MethodPost, "/api/approvals/:taskId/approve", handleFunctions.WorkflowManagementAPI.ApproveSubmission},
+		 {"RejectSubmission", http.MethodPost, "/api/approvals/:taskId/reject", handleFunctions.WorkflowManagementAPI.RejectSubmission},
+		 {"GetCorrections", http.MethodGet, "/api/corrections", handleFunctions.WorkflowManagementAPI.GetCorrections},
+		 {"CorrectProcedure", http.MethodPut, "/api/corrections/:taskId", handleFunctions.WorkflowManagementAPI.CorrectProcedure},
+		 {"GetProcedureWorkflow", http.MethodGet, "/api/procedures/:procedureId/workflow", handleFunctions.WorkflowManagementAPI.GetProcedureWorkflow},
+		 {"UpdateProcedureWorkflowStatus", http.MethodPut, "/api/procedures/:procedureId/workflow/status", handleFunctions.WorkflowManagementAPI.UpdateProcedureWorkflowStatus},
 	 }
  }
  
\ No newline at end of file
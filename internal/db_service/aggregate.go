@@ -0,0 +1,32 @@
+package db_service
+
+// Aggregation describes a grouping of the documents matching Filter. Without
+// GroupBy all matching documents form a single group.
+type Aggregation struct {
+	Filter  *Filter
+	GroupBy string
+	// Sum lists the numeric fields to total within each group.
+	Sum []string
+	// Join, if set, further restricts the aggregated documents to those referencing a
+	// document of another collection.
+	Join *Join
+}
+
+// Join matches the documents whose LocalField holds the id of a document of another
+// collection that matches Filter, e.g. the payments of the procedures of an ambulance.
+// The join runs in the database, the ids of the joined documents are not fetched.
+type Join struct {
+	LocalField string
+	// From is the DbService of the other collection, it must use the same backend.
+	From   any
+	Filter *Filter
+}
+
+// AggregateGroup is one group of an aggregation result.
+type AggregateGroup struct {
+	// Key is the value of the GroupBy field, nil when not grouping.
+	Key   any
+	Count int64
+	// Sums maps each field of Aggregation.Sum to its total.
+	Sums map[string]float64
+}
@@ -0,0 +1,70 @@
+package db_service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrInvalidField = fmt.Errorf("invalid field")
+
+// resolvedField is a top-level field of a document type.
+type resolvedField struct {
+	// Key is the name under which the mongo driver stores the field.
+	Key   string
+	Index []int
+	Type  reflect.Type
+}
+
+// resolveField maps a field name as used by API clients (JSON tag, BSON tag or
+// Go field name) to the field of DocType. Without an explicit bson tag the
+// driver lowercases the Go field name, so e.g. `ambulance_id` (JSON) is stored
+// as `ambulanceid`.
+func resolveField[DocType interface{}](name string) (resolvedField, error) {
+	field, ok := lookupField(reflect.TypeOf((*DocType)(nil)).Elem(), name)
+	if !ok {
+		return resolvedField{}, fmt.Errorf("%w: %q", ErrInvalidField, name)
+	}
+	return resolvedField{Key: bsonKey(field), Index: field.Index, Type: field.Type}, nil
+}
+
+func lookupField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || name == "" {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if jsonKey(field) == name || bsonKey(field) == name || strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func bsonKey(field reflect.StructField) string {
+	if key := tagName(field.Tag.Get("bson")); key != "" {
+		return key
+	}
+	return strings.ToLower(field.Name)
+}
+
+func jsonKey(field reflect.StructField) string {
+	if key := tagName(field.Tag.Get("json")); key != "" {
+		return key
+	}
+	return field.Name
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
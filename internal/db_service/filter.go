@@ -0,0 +1,115 @@
+package db_service
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// FilterOp is the operator of a Filter node.
+type FilterOp string
+
+const (
+	OpEq     FilterOp = "eq"
+	OpNe     FilterOp = "ne"
+	OpIn     FilterOp = "in"
+	OpGt     FilterOp = "gt"
+	OpGte    FilterOp = "gte"
+	OpLt     FilterOp = "lt"
+	OpLte    FilterOp = "lte"
+	OpPrefix FilterOp = "prefix"
+	OpAnd    FilterOp = "and"
+	OpOr     FilterOp = "or"
+)
+
+// Filter is a composable condition on document fields. Leaf nodes compare a
+// field with Value (or Values for OpIn), OpAnd and OpOr combine Filters.
+// Field names are resolved the same way as sort fields, so the JSON names used
+// by the API can be passed directly. String values are converted to the type
+// of the field, which allows filters to be built straight from query strings.
+type Filter struct {
+	Op      FilterOp
+	Field   string
+	Value   any
+	Values  []any
+	Filters []Filter
+}
+
+func Eq(field string, value any) Filter  { return Filter{Op: OpEq, Field: field, Value: value} }
+func Ne(field string, value any) Filter  { return Filter{Op: OpNe, Field: field, Value: value} }
+func Gt(field string, value any) Filter  { return Filter{Op: OpGt, Field: field, Value: value} }
+func Gte(field string, value any) Filter { return Filter{Op: OpGte, Field: field, Value: value} }
+func Lt(field string, value any) Filter  { return Filter{Op: OpLt, Field: field, Value: value} }
+func Lte(field string, value any) Filter { return Filter{Op: OpLte, Field: field, Value: value} }
+
+func In(field string, values ...any) Filter { return Filter{Op: OpIn, Field: field, Values: values} }
+
+// Prefix matches string fields starting with prefix.
+func Prefix(field string, prefix string) Filter {
+	return Filter{Op: OpPrefix, Field: field, Value: prefix}
+}
+
+// Range matches from <= field < to. A nil bound is left open.
+func Range(field string, from any, to any) Filter {
+	var filters []Filter
+	if from != nil {
+		filters = append(filters, Gte(field, from))
+	}
+	if to != nil {
+		filters = append(filters, Lt(field, to))
+	}
+	return And(filters...)
+}
+
+// And matches documents satisfying all filters; without filters it matches everything.
+func And(filters ...Filter) Filter { return Filter{Op: OpAnd, Filters: filters} }
+
+// Or matches documents satisfying any of the filters; without filters it matches nothing.
+func Or(filters ...Filter) Filter { return Filter{Op: OpOr, Filters: filters} }
+
+// ParseFilterOp converts the operator name used in query strings, e.g. `price[gte]=100`.
+func ParseFilterOp(name string) (FilterOp, error) {
+	switch op := FilterOp(name); op {
+	case OpEq, OpNe, OpIn, OpGt, OpGte, OpLt, OpLte, OpPrefix:
+		return op, nil
+	}
+	return "", fmt.Errorf("%w: unknown filter operator %q", ErrInvalidField, name)
+}
+
+// coerceValue converts value to the kind of t. Strings are parsed, numbers are
+// widened, anything else must already be assignable.
+func coerceValue(t reflect.Type, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(value)
+	if s, ok := value.(string); ok && t.Kind() != reflect.String {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not an integer", ErrInvalidField, s)
+			}
+			return reflect.ValueOf(n).Convert(t).Interface(), nil
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidField, s)
+			}
+			return reflect.ValueOf(f).Convert(t).Interface(), nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not a boolean", ErrInvalidField, s)
+			}
+			return b, nil
+		}
+	}
+	if v.Type().ConvertibleTo(t) && v.Kind() != reflect.String && t.Kind() != reflect.String {
+		return v.Convert(t).Interface(), nil
+	}
+	if v.Type().AssignableTo(t) {
+		return value, nil
+	}
+	return nil, fmt.Errorf("%w: %v cannot be compared with a %v field", ErrInvalidField, value, t)
+}
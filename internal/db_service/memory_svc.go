@@ -0,0 +1,535 @@
+package db_service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type memoryEntry struct {
+	raw     bson.Raw
+	version int64
+}
+
+// memorySvc keeps documents in process memory. Documents are stored in their
+// BSON encoding, so field naming, copying and decoding behave as with mongoSvc.
+type memorySvc[DocType interface{}] struct {
+	lock      sync.RWMutex
+	documents map[string]memoryEntry
+}
+
+// NewMemoryService returns a thread-safe DbService that needs no database server.
+// Its contents are lost when the process exits.
+func NewMemoryService[DocType interface{}]() DbService[DocType] {
+	return &memorySvc[DocType]{documents: map[string]memoryEntry{}}
+}
+
+func (m *memorySvc[DocType]) Disconnect(ctx context.Context) error {
+	return nil
+}
+
+func (m *memorySvc[DocType]) CreateDocument(ctx context.Context, id string, document *DocType) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.documents[id]; ok {
+		return ErrConflict
+	}
+	m.remember(ctx, id)
+	m.documents[id] = memoryEntry{raw: raw, version: InitialVersion}
+	return nil
+}
+
+func (m *memorySvc[DocType]) FindDocument(ctx context.Context, id string) (*DocType, error) {
+	document, _, err := m.FindVersionedDocument(ctx, id)
+	return document, err
+}
+
+func (m *memorySvc[DocType]) FindVersionedDocument(ctx context.Context, id string) (*DocType, int64, error) {
+	m.lock.RLock()
+	entry, ok := m.documents[id]
+	m.lock.RUnlock()
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	document, err := decodeEntry[DocType](entry)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &document, entry.version, nil
+}
+
+func (m *memorySvc[DocType]) UpdateDocument(ctx context.Context, id string, document *DocType) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry, ok := m.documents[id]
+	if !ok {
+		return ErrNotFound
+	}
+	m.remember(ctx, id)
+	m.documents[id] = memoryEntry{raw: raw, version: entry.version + 1}
+	return nil
+}
+
+func (m *memorySvc[DocType]) UpdateVersionedDocument(ctx context.Context, id string, document *DocType, version int64) (int64, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return 0, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry, ok := m.documents[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if entry.version != version {
+		return 0, ErrVersionMismatch
+	}
+	m.remember(ctx, id)
+	m.documents[id] = memoryEntry{raw: raw, version: version + 1}
+	return version + 1, nil
+}
+
+func (m *memorySvc[DocType]) DeleteDocument(ctx context.Context, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.documents[id]; !ok {
+		return ErrNotFound
+	}
+	m.remember(ctx, id)
+	delete(m.documents, id)
+	return nil
+}
+
+func (m *memorySvc[DocType]) DeleteVersionedDocument(ctx context.Context, id string, version int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry, ok := m.documents[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if entry.version != version {
+		return ErrVersionMismatch
+	}
+	m.remember(ctx, id)
+	delete(m.documents, id)
+	return nil
+}
+
+func (m *memorySvc[DocType]) DeleteDocuments(ctx context.Context, filter Filter) (int64, error) {
+	match, err := compileFilter[DocType](&filter)
+	if err != nil {
+		return 0, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var deleted int64
+	for id, entry := range m.documents {
+		document, err := decodeEntry[DocType](entry)
+		if err != nil {
+			return deleted, err
+		}
+		if match(reflect.ValueOf(&document).Elem()) {
+			m.remember(ctx, id)
+			delete(m.documents, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memoryTxKey struct{}
+
+// memoryTx collects the changes made within WithTransaction so they can be undone.
+type memoryTx struct {
+	lock sync.Mutex
+	undo []func()
+}
+
+// WithTransaction undoes all changes made through ctx by any memory service if fn fails.
+// Unlike a mongo transaction it does not isolate the changes from concurrent readers.
+func (m *memorySvc[DocType]) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &memoryTx{}
+	err := fn(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		tx.lock.Lock()
+		defer tx.lock.Unlock()
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}
+	return err
+}
+
+// remember records the current state of the document for a rollback of the
+// transaction carried by ctx. The caller must hold the write lock.
+func (m *memorySvc[DocType]) remember(ctx context.Context, id string) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return
+	}
+	previous, existed := m.documents[id]
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	tx.undo = append(tx.undo, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if existed {
+			m.documents[id] = previous
+		} else {
+			delete(m.documents, id)
+		}
+	})
+}
+
+func (m *memorySvc[DocType]) ListDocuments(ctx context.Context) ([]DocType, error) {
+	return m.FindDocuments(ctx, And())
+}
+
+func (m *memorySvc[DocType]) FindDocuments(ctx context.Context, filter Filter) ([]DocType, error) {
+	match, err := compileFilter[DocType](&filter)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := m.snapshot(match)
+	if err != nil {
+		return nil, err
+	}
+	sortDocuments(documents, nil)
+	return documents, nil
+}
+
+func (m *memorySvc[DocType]) ListDocumentsPage(ctx context.Context, opts ListOptions) (*Page[DocType], error) {
+	offset, limit, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	match, err := compileFilter[DocType](opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var sortFields []resolvedSortField
+	for _, field := range opts.Sort {
+		resolved, err := resolveField[DocType](field.Field)
+		if err != nil {
+			return nil, err
+		}
+		sortFields = append(sortFields, resolvedSortField{resolvedField: resolved, Descending: field.Descending})
+	}
+
+	documents, err := m.snapshot(match)
+	if err != nil {
+		return nil, err
+	}
+	sortDocuments(documents, sortFields)
+
+	total := len(documents)
+	start := min(offset, total)
+	end := total
+	if limit > 0 {
+		end = min(start+limit, total)
+	}
+	return newPage(documents[start:end], int64(total), offset, limit, opts.Sort), nil
+}
+
+func (m *memorySvc[DocType]) Aggregate(ctx context.Context, aggregation Aggregation) ([]AggregateGroup, error) {
+	match, err := compileFilter[DocType](aggregation.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var groupBy *resolvedField
+	if aggregation.GroupBy != "" {
+		field, err := resolveField[DocType](aggregation.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		groupBy = &field
+	}
+	sums := make([]resolvedField, len(aggregation.Sum))
+	for i, name := range aggregation.Sum {
+		if sums[i], err = resolveField[DocType](name); err != nil {
+			return nil, err
+		}
+	}
+
+	if aggregation.Join != nil {
+		if match, err = m.joined(match, aggregation.Join); err != nil {
+			return nil, err
+		}
+	}
+
+	documents, err := m.snapshot(match)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []AggregateGroup{}
+	index := map[any]int{}
+	for i := range documents {
+		document := reflect.ValueOf(&documents[i]).Elem()
+		var key any
+		if groupBy != nil {
+			key = document.FieldByIndex(groupBy.Index).Interface()
+		}
+		position, ok := index[key]
+		if !ok {
+			position = len(groups)
+			index[key] = position
+			groups = append(groups, AggregateGroup{Key: key, Sums: map[string]float64{}})
+			for _, name := range aggregation.Sum {
+				groups[position].Sums[name] = 0
+			}
+		}
+		groups[position].Count++
+		for j, field := range sums {
+			groups[position].Sums[aggregation.Sum[j]] += numericValue(document.FieldByIndex(field.Index))
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return compareValues(reflect.ValueOf(groups[i].Key), reflect.ValueOf(groups[j].Key)) < 0
+	})
+	return groups, nil
+}
+
+// memoryJoinable is implemented by the memory services, which can be the From side of a Join.
+type memoryJoinable interface {
+	// matchingIds returns the ids of the documents matching filter.
+	matchingIds(filter *Filter) (map[string]bool, error)
+}
+
+func (m *memorySvc[DocType]) matchingIds(filter *Filter) (map[string]bool, error) {
+	match, err := compileFilter[DocType](filter)
+	if err != nil {
+		return nil, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ids := map[string]bool{}
+	for id, entry := range m.documents {
+		document, err := decodeEntry[DocType](entry)
+		if err != nil {
+			return nil, err
+		}
+		if match(reflect.ValueOf(&document).Elem()) {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+// joined extends match to the documents referencing a document matched by join.
+func (m *memorySvc[DocType]) joined(match func(reflect.Value) bool, join *Join) (func(reflect.Value) bool, error) {
+	from, ok := join.From.(memoryJoinable)
+	if !ok {
+		return nil, fmt.Errorf("cannot join %T to a memory collection", join.From)
+	}
+	local, err := resolveField[DocType](join.LocalField)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := from.matchingIds(join.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return func(document reflect.Value) bool {
+		return match(document) && ids[fmt.Sprint(document.FieldByIndex(local.Index).Interface())]
+	}, nil
+}
+
+// snapshot decodes all documents accepted by match.
+func (m *memorySvc[DocType]) snapshot(match func(reflect.Value) bool) ([]DocType, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	documents := []DocType{}
+	for _, entry := range m.documents {
+		document, err := decodeEntry[DocType](entry)
+		if err != nil {
+			return nil, err
+		}
+		if match(reflect.ValueOf(&document).Elem()) {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+func decodeEntry[DocType interface{}](entry memoryEntry) (DocType, error) {
+	var document DocType
+	err := bson.Unmarshal(entry.raw, &document)
+	return document, err
+}
+
+type resolvedSortField struct {
+	resolvedField
+	Descending bool
+}
+
+// sortDocuments orders documents by the given fields and then by id, like mongoSvc does.
+func sortDocuments[DocType interface{}](documents []DocType, fields []resolvedSortField) {
+	id, idErr := resolveField[DocType]("id")
+	sort.SliceStable(documents, func(i, j int) bool {
+		a := reflect.ValueOf(&documents[i]).Elem()
+		b := reflect.ValueOf(&documents[j]).Elem()
+		for _, field := range fields {
+			if c := compareValues(a.FieldByIndex(field.Index), b.FieldByIndex(field.Index)); c != 0 {
+				return (c < 0) != field.Descending
+			}
+		}
+		if idErr != nil {
+			return false
+		}
+		return compareValues(a.FieldByIndex(id.Index), b.FieldByIndex(id.Index)) < 0
+	})
+}
+
+// compileFilter validates the filter against DocType and returns a predicate
+// evaluating it on a document value.
+func compileFilter[DocType interface{}](filter *Filter) (func(reflect.Value) bool, error) {
+	if filter == nil {
+		return func(reflect.Value) bool { return true }, nil
+	}
+
+	switch filter.Op {
+	case OpAnd, OpOr:
+		parts := make([]func(reflect.Value) bool, 0, len(filter.Filters))
+		for i := range filter.Filters {
+			part, err := compileFilter[DocType](&filter.Filters[i])
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		}
+		all := filter.Op == OpAnd
+		return func(document reflect.Value) bool {
+			for _, part := range parts {
+				if part(document) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	}
+
+	field, err := resolveField[DocType](filter.Field)
+	if err != nil {
+		return nil, err
+	}
+	get := func(document reflect.Value) reflect.Value { return document.FieldByIndex(field.Index) }
+
+	switch filter.Op {
+	case OpIn:
+		values := make([]reflect.Value, 0, len(filter.Values))
+		for _, value := range filter.Values {
+			converted, err := coerceValue(field.Type, value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, reflect.ValueOf(converted))
+		}
+		return func(document reflect.Value) bool {
+			for _, value := range values {
+				if compareValues(get(document), value) == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case OpPrefix:
+		prefix, ok := filter.Value.(string)
+		if !ok || field.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: prefix of %q must be a string", ErrInvalidField, filter.Field)
+		}
+		return func(document reflect.Value) bool {
+			return strings.HasPrefix(get(document).String(), prefix)
+		}, nil
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		converted, err := coerceValue(field.Type, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		value := reflect.ValueOf(converted)
+		accept := map[FilterOp]func(int) bool{
+			OpEq:  func(c int) bool { return c == 0 },
+			OpNe:  func(c int) bool { return c != 0 },
+			OpGt:  func(c int) bool { return c > 0 },
+			OpGte: func(c int) bool { return c >= 0 },
+			OpLt:  func(c int) bool { return c < 0 },
+			OpLte: func(c int) bool { return c <= 0 },
+		}[filter.Op]
+		return func(document reflect.Value) bool {
+			return accept(compareValues(get(document), value))
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown filter operator %q", ErrInvalidField, filter.Op)
+}
+
+// compareValues orders two scalar values of the same kind. Values of other kinds compare equal.
+func compareValues(a reflect.Value, b reflect.Value) int {
+	if !a.IsValid() || !b.IsValid() {
+		return 0
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return compareOrdered(boolToInt(a.Bool()), boolToInt(b.Bool()))
+	}
+	return 0
+}
+
+func compareOrdered[T int64 | uint64 | float64 | int](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// numericValue converts numeric fields to float64, other kinds count as 0 like in a mongo $sum.
+func numericValue(value reflect.Value) float64 {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package db_service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testDocument struct {
+	Id          string  `json:"id"`
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	AmbulanceId string  `json:"ambulance_id"`
+}
+
+type testPayment struct {
+	Id          string  `json:"id"`
+	ProcedureId string  `json:"procedure_id"`
+	Amount      float64 `json:"amount"`
+}
+
+type MemoryServiceSuite struct {
+	suite.Suite
+	ctx context.Context
+	svc DbService[testDocument]
+}
+
+func TestMemoryServiceSuite(t *testing.T) {
+	suite.Run(t, new(MemoryServiceSuite))
+}
+
+func (suite *MemoryServiceSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.svc = NewMemoryService[testDocument]()
+	for _, doc := range []testDocument{
+		{Id: "c", Name: "Checkup", Price: 50, AmbulanceId: "amb1"},
+		{Id: "a", Name: "Consultation", Price: 200, AmbulanceId: "amb1"},
+		{Id: "b", Name: "Surgery", Price: 1500, AmbulanceId: "amb2"},
+	} {
+		suite.Require().NoError(suite.svc.CreateDocument(suite.ctx, doc.Id, &doc))
+	}
+}
+
+func (suite *MemoryServiceSuite) Test_CreateDocument_DuplicateIdConflicts() {
+	err := suite.svc.CreateDocument(suite.ctx, "a", &testDocument{Id: "a"})
+	suite.Equal(ErrConflict, err)
+}
+
+func (suite *MemoryServiceSuite) Test_FindDocument_ReturnsCopy() {
+	doc, err := suite.svc.FindDocument(suite.ctx, "a")
+	suite.Require().NoError(err)
+	doc.Name = "changed"
+
+	again, err := suite.svc.FindDocument(suite.ctx, "a")
+	suite.Require().NoError(err)
+	suite.Equal("Consultation", again.Name)
+
+	_, err = suite.svc.FindDocument(suite.ctx, "missing")
+	suite.Equal(ErrNotFound, err)
+}
+
+func (suite *MemoryServiceSuite) Test_UpdateVersionedDocument_ComparesVersion() {
+	doc, version, err := suite.svc.FindVersionedDocument(suite.ctx, "a")
+	suite.Require().NoError(err)
+	suite.Equal(InitialVersion, version)
+
+	doc.Price = 250
+	version, err = suite.svc.UpdateVersionedDocument(suite.ctx, "a", doc, version)
+	suite.Require().NoError(err)
+	suite.Equal(InitialVersion+1, version)
+
+	_, err = suite.svc.UpdateVersionedDocument(suite.ctx, "a", doc, InitialVersion)
+	suite.Equal(ErrVersionMismatch, err)
+
+	_, err = suite.svc.UpdateVersionedDocument(suite.ctx, "missing", doc, InitialVersion)
+	suite.Equal(ErrNotFound, err)
+}
+
+func (suite *MemoryServiceSuite) Test_DeleteDocument_RemovesDocument() {
+	suite.Require().NoError(suite.svc.DeleteDocument(suite.ctx, "a"))
+	suite.Equal(ErrNotFound, suite.svc.DeleteDocument(suite.ctx, "a"))
+}
+
+func (suite *MemoryServiceSuite) Test_DeleteVersionedDocument_ChecksVersion() {
+	doc, version, err := suite.svc.FindVersionedDocument(suite.ctx, "a")
+	suite.Require().NoError(err)
+	_, err = suite.svc.UpdateVersionedDocument(suite.ctx, "a", doc, version)
+	suite.Require().NoError(err)
+
+	suite.Equal(ErrVersionMismatch, suite.svc.DeleteVersionedDocument(suite.ctx, "a", version))
+	suite.Require().NoError(suite.svc.DeleteVersionedDocument(suite.ctx, "a", version+1))
+	suite.Equal(ErrNotFound, suite.svc.DeleteVersionedDocument(suite.ctx, "a", version+1))
+}
+
+func (suite *MemoryServiceSuite) Test_Aggregate_JoinsOtherService() {
+	payments := NewMemoryService[testPayment]()
+	for _, payment := range []testPayment{
+		{Id: "pay1", ProcedureId: "a", Amount: 150},
+		{Id: "pay2", ProcedureId: "a", Amount: 50},
+		{Id: "pay3", ProcedureId: "c", Amount: 20},
+		{Id: "pay4", ProcedureId: "b", Amount: 1000},
+	} {
+		suite.Require().NoError(payments.CreateDocument(suite.ctx, payment.Id, &payment))
+	}
+
+	filter := Eq("ambulance_id", "amb1")
+	groups, err := payments.Aggregate(suite.ctx, Aggregation{
+		Join: &Join{LocalField: "procedure_id", From: suite.svc, Filter: &filter},
+		Sum:  []string{"amount"},
+	})
+	suite.Require().NoError(err)
+	suite.Require().Len(groups, 1)
+	suite.Equal(int64(3), groups[0].Count)
+	suite.Equal(220.0, groups[0].Sums["amount"])
+
+	_, err = payments.Aggregate(suite.ctx, Aggregation{Join: &Join{LocalField: "procedure_id", From: "procedures"}})
+	suite.Error(err)
+}
+
+func (suite *MemoryServiceSuite) Test_FindDocuments_AppliesFilter() {
+	docs, err := suite.svc.FindDocuments(suite.ctx, And(
+		Eq("ambulance_id", "amb1"),
+		Or(Gte("price", "100"), Prefix("name", "Check")),
+	))
+	suite.Require().NoError(err)
+	suite.Equal([]string{"a", "c"}, ids(docs))
+
+	_, err = suite.svc.FindDocuments(suite.ctx, Eq("unknown", "x"))
+	suite.ErrorIs(err, ErrInvalidField)
+}
+
+func (suite *MemoryServiceSuite) Test_ListDocumentsPage_SortsAndPages() {
+	opts := ListOptions{Limit: 2, Sort: ParseSort("-price")}
+	page, err := suite.svc.ListDocumentsPage(suite.ctx, opts)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"b", "a"}, ids(page.Items))
+	suite.Equal(int64(3), page.Total)
+	suite.NotEmpty(page.NextCursor)
+
+	opts.Cursor = page.NextCursor
+	page, err = suite.svc.ListDocumentsPage(suite.ctx, opts)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"c"}, ids(page.Items))
+	suite.Empty(page.NextCursor)
+
+	opts.Sort = ParseSort("price")
+	_, err = suite.svc.ListDocumentsPage(suite.ctx, opts)
+	suite.ErrorIs(err, ErrInvalidCursor)
+}
+
+func (suite *MemoryServiceSuite) Test_WithTransaction_RollsBackOnError() {
+	other := NewMemoryService[testDocument]()
+	suite.Require().NoError(other.CreateDocument(suite.ctx, "x", &testDocument{Id: "x"}))
+
+	failure := errors.New("failure")
+	err := suite.svc.WithTransaction(suite.ctx, func(ctx context.Context) error {
+		deleted, err := suite.svc.DeleteDocuments(ctx, Eq("ambulance_id", "amb1"))
+		suite.Require().NoError(err)
+		suite.Equal(int64(2), deleted)
+		suite.Require().NoError(other.DeleteDocument(ctx, "x"))
+		suite.Require().NoError(other.CreateDocument(ctx, "y", &testDocument{Id: "y"}))
+		return failure
+	})
+	suite.Equal(failure, err)
+
+	docs, err := suite.svc.ListDocuments(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"a", "b", "c"}, ids(docs))
+	docs, err = other.ListDocuments(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"x"}, ids(docs))
+}
+
+func ids(docs []testDocument) []string {
+	result := make([]string, len(docs))
+	for i, doc := range docs {
+		result[i] = doc.Id
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package db_service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sharedClient is a mongo client used by all services connecting to the same URI.
+// Sharing the client lets a transaction started by one service include the
+// operations of the others, as sessions are bound to the client that created them.
+type sharedClient struct {
+	client *mongo.Client
+	refs   int
+	// transactions caches whether the server supports them, nil until checked
+	transactions          *bool
+	transactionsCheckedAt time.Time
+}
+
+var (
+	sharedClientsLock sync.Mutex
+	sharedClients     = map[string]*sharedClient{}
+)
+
+func acquireClient(ctx context.Context, uri string) (*mongo.Client, error) {
+	sharedClientsLock.Lock()
+	defer sharedClientsLock.Unlock()
+
+	if shared, ok := sharedClients[uri]; ok {
+		shared.refs++
+		return shared.client, nil
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetConnectTimeout(10*time.Second))
+	if err != nil {
+		return nil, err
+	}
+	sharedClients[uri] = &sharedClient{client: client, refs: 1}
+	return client, nil
+}
+
+// releaseClient disconnects the client once the last service using it is done with it.
+func releaseClient(ctx context.Context, uri string) error {
+	sharedClientsLock.Lock()
+	defer sharedClientsLock.Unlock()
+
+	shared, ok := sharedClients[uri]
+	if !ok {
+		return nil
+	}
+	if shared.refs--; shared.refs > 0 {
+		return nil
+	}
+	delete(sharedClients, uri)
+	return shared.client.Disconnect(ctx)
+}
+
+// transactionsRecheckInterval is how long a server is remembered as not supporting transactions.
+// The answer expires as the replica set may still be initiated after the service connected.
+const transactionsRecheckInterval = 30 * time.Second
+
+// queryTransactionSupport is helloSupportsTransactions, replaced by tests that run without a server.
+var queryTransactionSupport = helloSupportsTransactions
+
+// serverSupportsTransactions reports whether the server behind uri can run transactions.
+// A positive answer is cached for the lifetime of the client, a negative one for
+// transactionsRecheckInterval, so writes to a standalone server do not each ask the server.
+func serverSupportsTransactions(ctx context.Context, uri string, client *mongo.Client) (bool, error) {
+	sharedClientsLock.Lock()
+	shared := sharedClients[uri]
+	if shared != nil && shared.transactions != nil &&
+		(*shared.transactions || time.Since(shared.transactionsCheckedAt) < transactionsRecheckInterval) {
+		defer sharedClientsLock.Unlock()
+		return *shared.transactions, nil
+	}
+	sharedClientsLock.Unlock()
+
+	supported, err := queryTransactionSupport(ctx, client)
+	if err != nil {
+		return false, err
+	}
+
+	sharedClientsLock.Lock()
+	defer sharedClientsLock.Unlock()
+	if shared != nil {
+		shared.transactions = &supported
+		shared.transactionsCheckedAt = time.Now()
+	}
+	return supported, nil
+}
+
+// helloSupportsTransactions asks the server whether it is a replica set member or mongos.
+// Standalone servers reject transactions.
+func helloSupportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
@@ -0,0 +1,67 @@
+package db_service
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// filterToBSON translates a Filter on DocType into a mongo query document.
+func filterToBSON[DocType interface{}](filter *Filter) (bson.D, error) {
+	if filter == nil {
+		return bson.D{}, nil
+	}
+
+	switch filter.Op {
+	case OpAnd, OpOr:
+		if filter.Op == OpAnd && len(filter.Filters) == 0 {
+			return bson.D{}, nil
+		}
+		parts := bson.A{}
+		for i := range filter.Filters {
+			part, err := filterToBSON[DocType](&filter.Filters[i])
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 0 {
+			// $or requires a non-empty array, an empty disjunction matches nothing
+			return bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}}, nil
+		}
+		return bson.D{{Key: "$" + string(filter.Op), Value: parts}}, nil
+	}
+
+	field, err := resolveField[DocType](filter.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filter.Op {
+	case OpIn:
+		values := bson.A{}
+		for _, value := range filter.Values {
+			converted, err := coerceValue(field.Type, value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, converted)
+		}
+		return bson.D{{Key: field.Key, Value: bson.D{{Key: "$in", Value: values}}}}, nil
+	case OpPrefix:
+		prefix, ok := filter.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: prefix of %q must be a string", ErrInvalidField, filter.Field)
+		}
+		pattern := "^" + regexp.QuoteMeta(prefix)
+		return bson.D{{Key: field.Key, Value: bson.D{{Key: "$regex", Value: pattern}}}}, nil
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		value, err := coerceValue(field.Type, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{{Key: field.Key, Value: bson.D{{Key: "$" + string(filter.Op), Value: value}}}}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown filter operator %q", ErrInvalidField, filter.Op)
+}
@@ -18,17 +18,36 @@ import (
 type DbService[DocType interface{}] interface {
     CreateDocument(ctx context.Context, id string, document *DocType) error
     FindDocument(ctx context.Context, id string) (*DocType, error)
+    // FindVersionedDocument returns the document together with its current version.
+    FindVersionedDocument(ctx context.Context, id string) (*DocType, int64, error)
     ListDocuments(ctx context.Context) ([]DocType, error)               // ← new
+    ListDocumentsPage(ctx context.Context, opts ListOptions) (*Page[DocType], error)
     UpdateDocument(ctx context.Context, id string, document *DocType) error
+    // UpdateVersionedDocument replaces the document only if it is still at the given version
+    // and returns the new version, otherwise it fails with ErrVersionMismatch.
+    UpdateVersionedDocument(ctx context.Context, id string, document *DocType, version int64) (int64, error)
     DeleteDocument(ctx context.Context, id string) error
+    // DeleteVersionedDocument removes the document only if it is still at the given version,
+    // otherwise it fails with ErrVersionMismatch.
+    DeleteVersionedDocument(ctx context.Context, id string, version int64) error
     Disconnect(ctx context.Context) error
-	FindDocumentsByField(ctx context.Context, fieldName string, value any) ([]*DocType, error)
+    FindDocuments(ctx context.Context, filter Filter) ([]DocType, error)
+    Aggregate(ctx context.Context, aggregation Aggregation) ([]AggregateGroup, error)
+    // DeleteDocuments removes all documents matching the filter and returns their number.
+    DeleteDocuments(ctx context.Context, filter Filter) (int64, error)
+    // WithTransaction runs fn atomically. Operations of other services of the same
+    // backend join the transaction when they are called with the context passed to fn.
+    WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 
 var ErrNotFound = fmt.Errorf("document not found")
 var ErrConflict = fmt.Errorf("conflict: document already exists")
 
+// ErrTransactionsUnsupported is returned by WithTransaction of a mongo service connected to a
+// standalone server, which cannot run transactions.
+var ErrTransactionsUnsupported = fmt.Errorf("MongoDB transactions need a replica set, start mongod with --replSet and initiate it, see deployments/README.md")
+
 type MongoServiceConfig struct {
 	ServerHost string
 	ServerPort int
@@ -124,14 +143,8 @@ func (m *mongoSvc[DocType]) connect(ctx context.Context) (*mongo.Client, error)
 	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
 	defer contextCancel()
 
-	var uri = fmt.Sprintf("mongodb://%v:%v", m.ServerHost, m.ServerPort)
-	log.Printf("Using URI: " + uri)
-
-	if len(m.UserName) != 0 {
-		uri = fmt.Sprintf("mongodb://%v:%v@%v:%v", m.UserName, m.Password, m.ServerHost, m.ServerPort)
-	}
-
-	if client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetConnectTimeout(10*time.Second)); err != nil {
+	log.Printf("Using URI: mongodb://%v:%v", m.ServerHost, m.ServerPort)
+	if client, err := acquireClient(ctx, m.uri()); err != nil {
 		return nil, err
 	} else {
 		m.client.Store(client)
@@ -139,6 +152,13 @@ func (m *mongoSvc[DocType]) connect(ctx context.Context) (*mongo.Client, error)
 	}
 }
 
+func (m *mongoSvc[DocType]) uri() string {
+	if len(m.UserName) != 0 {
+		return fmt.Sprintf("mongodb://%v:%v@%v:%v", m.UserName, m.Password, m.ServerHost, m.ServerPort)
+	}
+	return fmt.Sprintf("mongodb://%v:%v", m.ServerHost, m.ServerPort)
+}
+
 func (m *mongoSvc[DocType]) Disconnect(ctx context.Context) error {
 	client := m.client.Load()
 
@@ -149,7 +169,7 @@ func (m *mongoSvc[DocType]) Disconnect(ctx context.Context) error {
 		client = m.client.Load()
 		defer m.client.Store(nil)
 		if client != nil {
-			if err := client.Disconnect(ctx); err != nil {
+			if err := releaseClient(ctx, m.uri()); err != nil {
 				return err
 			}
 		}
@@ -175,16 +195,25 @@ func (m *mongoSvc[DocType]) CreateDocument(ctx context.Context, id string, docum
 		return result.Err()
 	}
 
-	_, err = collection.InsertOne(ctx, document)
+	versioned, err := withVersion(document, InitialVersion)
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(ctx, versioned)
 	return err
 }
 
 func (m *mongoSvc[DocType]) FindDocument(ctx context.Context, id string) (*DocType, error) {
+	document, _, err := m.FindVersionedDocument(ctx, id)
+	return document, err
+}
+
+func (m *mongoSvc[DocType]) FindVersionedDocument(ctx context.Context, id string) (*DocType, int64, error) {
 	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
 	defer contextCancel()
 	client, err := m.connect(ctx)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	db := client.Database(m.DbName)
 	collection := db.Collection(m.Collection)
@@ -192,36 +221,63 @@ func (m *mongoSvc[DocType]) FindDocument(ctx context.Context, id string) (*DocTy
 	switch result.Err() {
 	case nil:
 	case mongo.ErrNoDocuments:
-		return nil, ErrNotFound
+		return nil, 0, ErrNotFound
 	default:
-		return nil, result.Err()
+		return nil, 0, result.Err()
+	}
+	raw, err := result.Raw()
+	if err != nil {
+		return nil, 0, err
 	}
 	var document *DocType
-	if err := result.Decode(&document); err != nil {
-		return nil, err
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, 0, err
 	}
-	return document, nil
+	return document, decodeVersion(raw), nil
 }
 
+// UpdateDocument replaces the document at whatever version it currently has.
+// A concurrent update between reading the version and replacing the document
+// is reported as ErrVersionMismatch instead of being silently overwritten.
 func (m *mongoSvc[DocType]) UpdateDocument(ctx context.Context, id string, document *DocType) error {
+	_, version, err := m.FindVersionedDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = m.UpdateVersionedDocument(ctx, id, document, version)
+	return err
+}
+
+func (m *mongoSvc[DocType]) UpdateVersionedDocument(ctx context.Context, id string, document *DocType, version int64) (int64, error) {
 	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
 	defer contextCancel()
 	client, err := m.connect(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	db := client.Database(m.DbName)
 	collection := db.Collection(m.Collection)
-	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
-	switch result.Err() {
-	case nil:
-	case mongo.ErrNoDocuments:
-		return ErrNotFound
-	default:
-		return result.Err()
+
+	versioned, err := withVersion(document, version+1)
+	if err != nil {
+		return 0, err
 	}
-	_, err = collection.ReplaceOne(ctx, bson.D{{Key: "id", Value: id}}, document)
-	return err
+	result, err := collection.ReplaceOne(ctx, versionFilter(id, version), versioned)
+	if err != nil {
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		// distinguish a missing document from one that moved on to another version
+		switch err := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Err(); err {
+		case nil:
+			return 0, ErrVersionMismatch
+		case mongo.ErrNoDocuments:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+	return version + 1, nil
 }
 
 func (m *mongoSvc[DocType]) DeleteDocument(ctx context.Context, id string) error {
@@ -245,6 +301,33 @@ func (m *mongoSvc[DocType]) DeleteDocument(ctx context.Context, id string) error
 	return err
 }
 
+func (m *mongoSvc[DocType]) DeleteVersionedDocument(ctx context.Context, id string, version int64) error {
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	collection := client.Database(m.DbName).Collection(m.Collection)
+
+	result, err := collection.DeleteOne(ctx, versionFilter(id, version))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		// distinguish a missing document from one that moved on to another version
+		switch err := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Err(); err {
+		case nil:
+			return ErrVersionMismatch
+		case mongo.ErrNoDocuments:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mongoSvc[DocType]) ListDocuments(ctx context.Context) ([]DocType, error) {
     ctx, cancel := context.WithTimeout(ctx, m.Timeout)
     defer cancel()
@@ -275,37 +358,297 @@ func (m *mongoSvc[DocType]) ListDocuments(ctx context.Context) ([]DocType, error
     return results, nil
 }
 
-func (m *mongoSvc[DocType]) FindDocumentsByField(ctx context.Context, fieldName string, value any) ([]*DocType, error) {
-    ctx, cancel := context.WithTimeout(ctx, m.Timeout)
-    defer cancel()
+func (m *mongoSvc[DocType]) FindDocuments(ctx context.Context, filter Filter) ([]DocType, error) {
+	query, err := filterToBSON[DocType](&filter)
+	if err != nil {
+		return nil, err
+	}
 
-    client, err := m.connect(ctx)
-    if err != nil {
-        return nil, err
-    }
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
 
-    coll := client.Database(m.DbName).Collection(m.Collection)
+	client, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coll := client.Database(m.DbName).Collection(m.Collection)
 
-    filter := bson.D{{Key: fieldName, Value: value}}
-    cursor, err := coll.Find(ctx, filter)
-    if err != nil {
-        return nil, err
-    }
-    defer cursor.Close(ctx)
+	cursor, err := coll.Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
-    var results []*DocType
-    for cursor.Next(ctx) {
-        var doc DocType
-        if err := cursor.Decode(&doc); err != nil {
-            return nil, err
-        }
-        docCopy := doc // capture current doc in memory
-        results = append(results, &docCopy)
-    }
+	results := []DocType{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-    if err := cursor.Err(); err != nil {
-        return nil, err
-    }
+func (m *mongoSvc[DocType]) ListDocumentsPage(ctx context.Context, opts ListOptions) (*Page[DocType], error) {
+	offset, limit, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
 
-    return results, nil
+	filter, err := filterToBSON[DocType](opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// always end with the id so that pages are stable for equal sort values
+	sort := bson.D{}
+	hasID := false
+	for _, field := range opts.Sort {
+		resolved, err := resolveField[DocType](field.Field)
+		if err != nil {
+			return nil, err
+		}
+		key := resolved.Key
+		direction := 1
+		if field.Descending {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: key, Value: direction})
+		hasID = hasID || key == "id"
+	}
+	if !hasID {
+		sort = append(sort, bson.E{Key: "id", Value: 1})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	client, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coll := client.Database(m.DbName).Collection(m.Collection)
+
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOptions := options.Find().SetSort(sort).SetSkip(int64(offset))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cursor, err := coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []DocType
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return newPage(items, total, offset, limit, opts.Sort), nil
+}
+
+func (m *mongoSvc[DocType]) Aggregate(ctx context.Context, aggregation Aggregation) ([]AggregateGroup, error) {
+	match, err := filterToBSON[DocType](aggregation.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupKey any
+	if aggregation.GroupBy != "" {
+		field, err := resolveField[DocType](aggregation.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		groupKey = "$" + field.Key
+	}
+	group := bson.D{{Key: "_id", Value: groupKey}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}
+	for i, name := range aggregation.Sum {
+		field, err := resolveField[DocType](name)
+		if err != nil {
+			return nil, err
+		}
+		// field names may not be valid $group keys, so sums are stored by position
+		group = append(group, bson.E{Key: fmt.Sprintf("sum%d", i), Value: bson.D{{Key: "$sum", Value: "$" + field.Key}}})
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if aggregation.Join != nil {
+		join, err := lookupJoin[DocType](aggregation.Join)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, join...)
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: group}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	client, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coll := client.Database(m.DbName).Collection(m.Collection)
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []AggregateGroup{}
+	for cursor.Next(ctx) {
+		var row bson.M
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		result := AggregateGroup{Key: row["_id"], Count: toInt64(row["count"]), Sums: map[string]float64{}}
+		for i, name := range aggregation.Sum {
+			result.Sums[name] = toFloat64(row[fmt.Sprintf("sum%d", i)])
+		}
+		groups = append(groups, result)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// mongoJoinable is implemented by the mongo services, which can be the From side of a Join.
+type mongoJoinable interface {
+	// lookup returns a $lookup stage adding the documents matching filter whose id is
+	// held by localKey to the as field.
+	lookup(localKey string, filter *Filter, as string) (bson.D, error)
+}
+
+// joinedField holds the documents matched by a Join while the pipeline runs.
+const joinedField = "_joined"
+
+// lookupJoin translates a Join of a DocType collection into aggregation stages.
+func lookupJoin[DocType interface{}](join *Join) ([]bson.D, error) {
+	from, ok := join.From.(mongoJoinable)
+	if !ok {
+		return nil, fmt.Errorf("cannot join %T to a mongo collection", join.From)
+	}
+	local, err := resolveField[DocType](join.LocalField)
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := from.lookup(local.Key, join.Filter, joinedField)
+	if err != nil {
+		return nil, err
+	}
+	matched := bson.D{{Key: "$match", Value: bson.D{{Key: joinedField, Value: bson.D{{Key: "$ne", Value: bson.A{}}}}}}}
+	return []bson.D{lookup, matched}, nil
+}
+
+func (m *mongoSvc[DocType]) lookup(localKey string, filter *Filter, as string) (bson.D, error) {
+	match, err := filterToBSON[DocType](filter)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: m.Collection},
+		{Key: "localField", Value: localKey},
+		{Key: "foreignField", Value: "id"},
+		// only whether a document matches is needed
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$limit", Value: 1}},
+			bson.D{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+		}},
+		{Key: "as", Value: as},
+	}}}, nil
+}
+
+func toInt64(value any) int64 {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func toFloat64(value any) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func (m *mongoSvc[DocType]) DeleteDocuments(ctx context.Context, filter Filter) (int64, error) {
+	query, err := filterToBSON[DocType](&filter)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	client, err := m.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	coll := client.Database(m.DbName).Collection(m.Collection)
+
+	result, err := coll.DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// InTransaction reports whether ctx carries a transaction started by WithTransaction
+// of a mongo or memory service.
+func InTransaction(ctx context.Context) bool {
+	if mongo.SessionFromContext(ctx) != nil {
+		return true
+	}
+	_, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	return ok
+}
+
+// WithTransaction runs fn in a mongo transaction, retrying it on transient errors.
+// Standalone servers do not support transactions, there it fails with ErrTransactionsUnsupported
+// without running fn.
+func (m *mongoSvc[DocType]) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		// already inside a transaction
+		return fn(ctx)
+	}
+
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	supported, err := serverSupportsTransactions(ctx, m.uri(), client)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
 }
@@ -0,0 +1,55 @@
+package db_service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoServiceSuite struct {
+	suite.Suite
+	svc DbService[testDocument]
+}
+
+func TestMongoServiceSuite(t *testing.T) {
+	suite.Run(t, new(MongoServiceSuite))
+}
+
+func (suite *MongoServiceSuite) SetupTest() {
+	// the client connects lazily, no server is contacted unless a test needs one
+	suite.svc = NewMongoService[testDocument](MongoServiceConfig{
+		ServerHost: "localhost",
+		ServerPort: 1,
+		DbName:     "test",
+		Collection: "documents",
+		Timeout:    time.Second,
+	})
+}
+
+func (suite *MongoServiceSuite) TearDownTest() {
+	suite.Require().NoError(suite.svc.Disconnect(context.Background()))
+}
+
+func (suite *MongoServiceSuite) Test_WithTransaction_StandaloneServer_Fails() {
+	queries := 0
+	query := queryTransactionSupport
+	defer func() { queryTransactionSupport = query }()
+	queryTransactionSupport = func(context.Context, *mongo.Client) (bool, error) {
+		queries++
+		return false, nil
+	}
+
+	called := false
+	for i := 0; i < 2; i++ {
+		err := suite.svc.WithTransaction(context.Background(), func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		suite.Equal(ErrTransactionsUnsupported, err)
+	}
+	suite.False(called, "fn must not run without a transaction")
+	suite.Equal(1, queries, "the negative answer is cached")
+}
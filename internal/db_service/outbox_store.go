@@ -0,0 +1,82 @@
+package db_service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// ErrNoTransaction is returned for an outbox message stored outside a transaction, it would
+// be kept even if the change it describes is rolled back.
+var ErrNoTransaction = fmt.Errorf("outbox messages must be stored within a transaction")
+
+// outboxStore keeps the Kafka outbox in a collection of the service's database.
+type outboxStore struct {
+	messages DbService[kafka.OutboxMessage]
+}
+
+// NewOutboxStore returns the kafka.OutboxStore backed by the given collection. Use a
+// service of the same backend as the documents, so that messages join their transactions;
+// Insert fails with ErrNoTransaction unless ctx carries one.
+func NewOutboxStore(messages DbService[kafka.OutboxMessage]) kafka.OutboxStore {
+	return &outboxStore{messages: messages}
+}
+
+func (o *outboxStore) Insert(ctx context.Context, message *kafka.OutboxMessage) error {
+	if !InTransaction(ctx) {
+		return ErrNoTransaction
+	}
+	return o.messages.CreateDocument(ctx, message.Id, message)
+}
+
+func (o *outboxStore) Update(ctx context.Context, message *kafka.OutboxMessage) error {
+	return o.messages.UpdateDocument(ctx, message.Id, message)
+}
+
+func (o *outboxStore) List(ctx context.Context, query kafka.OutboxQuery, limit int) ([]kafka.OutboxMessage, error) {
+	filter := outboxFilter(query)
+	page, err := o.messages.ListDocumentsPage(ctx, ListOptions{
+		Limit:  limit,
+		Sort:   []SortField{{Field: "id"}},
+		Filter: &filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+func (o *outboxStore) Count(ctx context.Context, query kafka.OutboxQuery) (int64, error) {
+	filter := outboxFilter(query)
+	page, err := o.messages.ListDocumentsPage(ctx, ListOptions{Limit: 1, Filter: &filter})
+	if err != nil {
+		return 0, err
+	}
+	return page.Total, nil
+}
+
+func (o *outboxStore) Delete(ctx context.Context, query kafka.OutboxQuery) (int64, error) {
+	return o.messages.DeleteDocuments(ctx, outboxFilter(query))
+}
+
+func outboxFilter(query kafka.OutboxQuery) Filter {
+	filters := []Filter{}
+	if len(query.Statuses) > 0 {
+		statuses := make([]any, len(query.Statuses))
+		for i, status := range query.Statuses {
+			statuses[i] = status
+		}
+		filters = append(filters, In("status", statuses...))
+	}
+	if query.FromId != "" {
+		filters = append(filters, Gte("id", query.FromId))
+	}
+	if query.AfterId != "" {
+		filters = append(filters, Gt("id", query.AfterId))
+	}
+	if query.BeforeId != "" {
+		filters = append(filters, Lt("id", query.BeforeId))
+	}
+	return And(filters...)
+}
@@ -0,0 +1,120 @@
+package db_service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/pkg/kafka"
+)
+
+type OutboxStoreSuite struct {
+	suite.Suite
+	ctx      context.Context
+	messages DbService[kafka.OutboxMessage]
+	store    kafka.OutboxStore
+	ids      []string
+}
+
+func TestOutboxStoreSuite(t *testing.T) {
+	suite.Run(t, new(OutboxStoreSuite))
+}
+
+func (suite *OutboxStoreSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.messages = NewMemoryService[kafka.OutboxMessage]()
+	suite.store = NewOutboxStore(suite.messages)
+	suite.ids = nil
+	err := suite.messages.WithTransaction(suite.ctx, func(ctx context.Context) error {
+		for _, status := range []string{kafka.OutboxSent, kafka.OutboxRejected, kafka.OutboxPending, kafka.OutboxPending} {
+			message := kafka.NewOutboxMessage("amb1", []byte("{}"))
+			message.Status = status
+			if err := suite.store.Insert(ctx, message); err != nil {
+				return err
+			}
+			suite.ids = append(suite.ids, message.Id)
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *OutboxStoreSuite) messageIds(query kafka.OutboxQuery, limit int) []string {
+	messages, err := suite.store.List(suite.ctx, query, limit)
+	suite.Require().NoError(err)
+	ids := []string{}
+	for _, message := range messages {
+		ids = append(ids, message.Id)
+	}
+	return ids
+}
+
+func (suite *OutboxStoreSuite) Test_Insert_OutsideTransaction_Fails() {
+	message := kafka.NewOutboxMessage("amb1", []byte("{}"))
+
+	err := suite.store.Insert(suite.ctx, message)
+	suite.Equal(ErrNoTransaction, err)
+	_, err = suite.messages.FindDocument(suite.ctx, message.Id)
+	suite.Equal(ErrNotFound, err)
+}
+
+func (suite *OutboxStoreSuite) Test_Insert_RolledBackWithTheDocumentChange() {
+	documents := NewMemoryService[testDocument]()
+	message := kafka.NewOutboxMessage("a", []byte("{}"))
+	failure := fmt.Errorf("validation failed")
+
+	err := documents.WithTransaction(suite.ctx, func(ctx context.Context) error {
+		if err := documents.CreateDocument(ctx, "a", &testDocument{Id: "a"}); err != nil {
+			return err
+		}
+		if err := suite.store.Insert(ctx, message); err != nil {
+			return err
+		}
+		return failure
+	})
+	suite.Equal(failure, err)
+	_, err = documents.FindDocument(suite.ctx, "a")
+	suite.Equal(ErrNotFound, err)
+	_, err = suite.messages.FindDocument(suite.ctx, message.Id)
+	suite.Equal(ErrNotFound, err)
+}
+
+func (suite *OutboxStoreSuite) Test_List_SelectsByStatusInIdOrder() {
+	suite.Equal(suite.ids, suite.messageIds(kafka.OutboxQuery{}, 10))
+	suite.Equal(suite.ids[2:], suite.messageIds(kafka.OutboxQuery{Statuses: []string{kafka.OutboxPending}}, 10))
+	suite.Equal(
+		[]string{suite.ids[0], suite.ids[2]},
+		suite.messageIds(kafka.OutboxQuery{Statuses: []string{kafka.OutboxPending, kafka.OutboxSent}}, 2),
+	)
+}
+
+func (suite *OutboxStoreSuite) Test_List_SelectsById() {
+	suite.Equal(suite.ids[1:], suite.messageIds(kafka.OutboxQuery{FromId: suite.ids[1]}, 10))
+	suite.Equal(suite.ids[2:], suite.messageIds(kafka.OutboxQuery{AfterId: suite.ids[1]}, 10))
+	suite.Equal(suite.ids[:2], suite.messageIds(kafka.OutboxQuery{BeforeId: suite.ids[2]}, 10))
+}
+
+func (suite *OutboxStoreSuite) Test_Count_CountsSelectedMessages() {
+	count, err := suite.store.Count(suite.ctx, kafka.OutboxQuery{Statuses: []string{kafka.OutboxPending, kafka.OutboxSent}})
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), count)
+}
+
+func (suite *OutboxStoreSuite) Test_Update_ReplacesMessage() {
+	messages, err := suite.store.List(suite.ctx, kafka.OutboxQuery{Statuses: []string{kafka.OutboxPending}}, 1)
+	suite.Require().NoError(err)
+	message := messages[0]
+	message.Status = kafka.OutboxSent
+	suite.Require().NoError(suite.store.Update(suite.ctx, &message))
+
+	suite.Equal(suite.ids[3:], suite.messageIds(kafka.OutboxQuery{Statuses: []string{kafka.OutboxPending}}, 10))
+}
+
+func (suite *OutboxStoreSuite) Test_Delete_RemovesSelectedMessages() {
+	deleted, err := suite.store.Delete(suite.ctx, kafka.OutboxQuery{Statuses: []string{kafka.OutboxSent, kafka.OutboxPending}, BeforeId: suite.ids[3]})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), deleted)
+
+	suite.Equal([]string{suite.ids[1], suite.ids[3]}, suite.messageIds(kafka.OutboxQuery{}, 10))
+}
@@ -0,0 +1,133 @@
+package db_service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxPageLimit caps the number of documents returned by a single page.
+const MaxPageLimit = 1000
+
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+// SortField is one key of the sort order, e.g. `-price` is {Field: "price", Descending: true}.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ListOptions controls which slice of a collection ListDocumentsPage returns.
+// Limit 0 means no limit. A non-empty Cursor takes precedence over Offset.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   []SortField
+	// Filter restricts the listing to matching documents; nil lists all of them.
+	Filter *Filter
+}
+
+// Page is a slice of a collection together with the information needed to fetch the next one.
+type Page[DocType interface{}] struct {
+	Items      []DocType
+	Total      int64
+	Offset     int
+	Limit      int
+	NextCursor string
+}
+
+// ParseSort parses a comma separated list of fields, each optionally prefixed
+// by `-` for descending or `+` for ascending order, e.g. `-timestamp,price`.
+func ParseSort(value string) []SortField {
+	var fields []SortField
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field := SortField{Field: part}
+		switch part[0] {
+		case '-':
+			field = SortField{Field: part[1:], Descending: true}
+		case '+':
+			field = SortField{Field: part[1:]}
+		}
+		if field.Field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// FormatSort is the inverse of ParseSort.
+func FormatSort(fields []SortField) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Descending {
+			parts = append(parts, "-"+field.Field)
+		} else {
+			parts = append(parts, field.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+type cursorToken struct {
+	Offset int    `json:"o"`
+	Sort   string `json:"s,omitempty"`
+}
+
+// encodeCursor creates an opaque token pointing at offset within a listing ordered by sort.
+func encodeCursor(offset int, sort []SortField) string {
+	b, _ := json.Marshal(cursorToken{Offset: offset, Sort: FormatSort(sort)})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the offset the cursor points at. Cursors are only valid
+// for the sort order they were issued for.
+func decodeCursor(cursor string, sort []SortField) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var token cursorToken
+	if err := json.Unmarshal(b, &token); err != nil || token.Offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	if token.Sort != FormatSort(sort) {
+		return 0, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+	}
+	return token.Offset, nil
+}
+
+// normalize validates the options and resolves the effective offset and limit.
+func (o ListOptions) normalize() (offset int, limit int, err error) {
+	if o.Limit < 0 || o.Offset < 0 {
+		return 0, 0, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidField)
+	}
+	limit = o.Limit
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+	offset = o.Offset
+	if o.Cursor != "" {
+		if offset, err = decodeCursor(o.Cursor, o.Sort); err != nil {
+			return 0, 0, err
+		}
+	}
+	return offset, limit, nil
+}
+
+// newPage wraps items fetched at offset into a Page and computes the next cursor.
+func newPage[DocType interface{}](items []DocType, total int64, offset int, limit int, sort []SortField) *Page[DocType] {
+	if items == nil {
+		items = []DocType{}
+	}
+	page := &Page[DocType]{Items: items, Total: total, Offset: offset, Limit: limit}
+	if next := offset + len(items); limit > 0 && int64(next) < total {
+		page.NextCursor = encodeCursor(next, sort)
+	}
+	return page
+}
@@ -0,0 +1,47 @@
+package db_service
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// InitialVersion is the version of a newly created document. Every update increments it.
+const InitialVersion int64 = 1
+
+// versionField is the key holding the document version next to the DocType fields.
+const versionField = "_version"
+
+var ErrVersionMismatch = fmt.Errorf("conflict: document version mismatch")
+
+// withVersion converts the document to BSON and stamps it with the given version.
+func withVersion(document any, version int64) (bson.D, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return append(doc, bson.E{Key: versionField, Value: version}), nil
+}
+
+// versionFilter matches the document with the given id and version. Documents
+// written before versioning was introduced have no version field and count as version 0.
+func versionFilter(id string, version int64) bson.D {
+	if version == 0 {
+		return bson.D{{Key: "id", Value: id}, {Key: versionField, Value: bson.D{{Key: "$in", Value: bson.A{nil, int64(0)}}}}}
+	}
+	return bson.D{{Key: "id", Value: id}, {Key: versionField, Value: version}}
+}
+
+// decodeVersion reads the version stamped by withVersion from a stored document.
+func decodeVersion(raw bson.Raw) int64 {
+	value, err := raw.LookupErr(versionField)
+	if err != nil {
+		return 0
+	}
+	version, _ := value.AsInt64OK()
+	return version
+}
@@ -0,0 +1,305 @@
+package camunda
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Error is returned for responses of the engine with an unexpected status code.
+type Error struct {
+    StatusCode int
+    Type       string `json:"type"`
+    Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+    if e.Message == "" {
+        return fmt.Sprintf("camunda returned %d", e.StatusCode)
+    }
+    return fmt.Sprintf("camunda returned %d: %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Client calls the REST API of a Camunda 7 engine.
+type Client struct {
+    url  string
+    http *http.Client
+}
+
+// NewClient returns a client for the engine REST API at url, e.g. http://localhost:8082/engine-rest.
+func NewClient(url string) *Client {
+    return &Client{
+        url:  strings.TrimSuffix(url, "/"),
+        http: &http.Client{Timeout: 30 * time.Second},
+    }
+}
+
+// ExternalTask is an external task locked by FetchAndLock.
+type ExternalTask struct {
+    Id                   string    `json:"id"`
+    TopicName            string    `json:"topicName"`
+    WorkerId             string    `json:"workerId"`
+    ActivityId           string    `json:"activityId"`
+    ProcessInstanceId    string    `json:"processInstanceId"`
+    ProcessDefinitionKey string    `json:"processDefinitionKey"`
+    BusinessKey          string    `json:"businessKey"`
+    Retries              *int      `json:"retries"`
+    ErrorMessage         string    `json:"errorMessage"`
+    Priority             int64     `json:"priority"`
+    Variables            Variables `json:"variables"`
+}
+
+// FetchTopic selects the tasks of one topic in a fetch request.
+type FetchTopic struct {
+    TopicName string `json:"topicName"`
+
+    // LockDuration is in milliseconds.
+    LockDuration int64 `json:"lockDuration"`
+
+    // Variables limits the fetched variables, all are fetched if it is empty.
+    Variables []string `json:"variables,omitempty"`
+}
+
+// FetchRequest is the body of POST /external-task/fetchAndLock.
+type FetchRequest struct {
+    WorkerId    string       `json:"workerId"`
+    MaxTasks    int          `json:"maxTasks"`
+    UsePriority bool         `json:"usePriority"`
+    Topics      []FetchTopic `json:"topics"`
+}
+
+// FetchAndLock locks up to request.MaxTasks external tasks of the requested topics for the worker.
+func (c *Client) FetchAndLock(ctx context.Context, request FetchRequest) ([]ExternalTask, error) {
+    tasks := []ExternalTask{}
+    if err := c.post(ctx, "/external-task/fetchAndLock", request, &tasks); err != nil {
+        return nil, err
+    }
+    return tasks, nil
+}
+
+// Complete completes the external task locked by workerId and sets the given process variables.
+func (c *Client) Complete(ctx context.Context, taskId string, workerId string, variables map[string]any) error {
+    encoded, err := NewVariables(variables)
+    if err != nil {
+        return err
+    }
+    body := map[string]any{"workerId": workerId, "variables": encoded}
+    return c.post(ctx, "/external-task/"+url.PathEscape(taskId)+"/complete", body, nil)
+}
+
+// Failure reports a failed attempt of an external task.
+type Failure struct {
+    WorkerId     string `json:"workerId"`
+    ErrorMessage string `json:"errorMessage"`
+    ErrorDetails string `json:"errorDetails,omitempty"`
+
+    // Retries is the number of attempts left, the engine raises an incident when it is 0.
+    Retries int `json:"retries"`
+
+    // RetryTimeout is the delay before the task can be fetched again, in milliseconds.
+    RetryTimeout int64 `json:"retryTimeout"`
+}
+
+// HandleFailure reports a failed attempt of the external task and unlocks it.
+func (c *Client) HandleFailure(ctx context.Context, taskId string, failure Failure) error {
+    return c.post(ctx, "/external-task/"+url.PathEscape(taskId)+"/failure", failure, nil)
+}
+
+// HandleBpmnError throws a BPMN error with the given code from the external task, it is caught
+// by a matching error boundary event of the task. The variables are set before the error is thrown.
+func (c *Client) HandleBpmnError(ctx context.Context, taskId string, workerId string, bpmnError *BpmnError) error {
+    encoded, err := NewVariables(bpmnError.Variables)
+    if err != nil {
+        return err
+    }
+    body := map[string]any{
+        "workerId":     workerId,
+        "errorCode":    bpmnError.Code,
+        "errorMessage": bpmnError.Message,
+        "variables":    encoded,
+    }
+    return c.post(ctx, "/external-task/"+url.PathEscape(taskId)+"/bpmnError", body, nil)
+}
+
+// ExtendLock locks the external task for another lockDuration from now.
+func (c *Client) ExtendLock(ctx context.Context, taskId string, workerId string, lockDuration time.Duration) error {
+    body := map[string]any{"workerId": workerId, "newDuration": lockDuration.Milliseconds()}
+    return c.post(ctx, "/external-task/"+url.PathEscape(taskId)+"/extendLock", body, nil)
+}
+
+// UserTask is a user task of a process instance.
+type UserTask struct {
+    Id                string `json:"id"`
+    Name              string `json:"name"`
+    Assignee          string `json:"assignee"`
+    Created           string `json:"created"`
+    TaskDefinitionKey string `json:"taskDefinitionKey"`
+    ProcessInstanceId string `json:"processInstanceId"`
+}
+
+// UserTasks lists the open user tasks with the given task definition key.
+func (c *Client) UserTasks(ctx context.Context, definitionKey string) ([]UserTask, error) {
+    tasks := []UserTask{}
+    query := url.Values{"taskDefinitionKey": {definitionKey}}
+    if err := c.get(ctx, "/task?"+query.Encode(), &tasks); err != nil {
+        return nil, err
+    }
+    return tasks, nil
+}
+
+// UserTask returns the open user task with the given id, an *Error with StatusCode 404 if there is none.
+func (c *Client) UserTask(ctx context.Context, taskId string) (*UserTask, error) {
+    task := &UserTask{}
+    if err := c.get(ctx, "/task/"+url.PathEscape(taskId), task); err != nil {
+        return nil, err
+    }
+    return task, nil
+}
+
+// UserTaskVariables returns the named variables visible from the user task, all if names is empty.
+// Json variables are returned serialized, Get and Decode unwrap them.
+func (c *Client) UserTaskVariables(ctx context.Context, taskId string, names ...string) (Variables, error) {
+    query := url.Values{"deserializeValues": {"false"}}
+    if len(names) > 0 {
+        query.Set("variableNames", strings.Join(names, ","))
+    }
+    variables := Variables{}
+    if err := c.get(ctx, "/task/"+url.PathEscape(taskId)+"/variables?"+query.Encode(), &variables); err != nil {
+        return nil, err
+    }
+    return variables, nil
+}
+
+// ClaimUserTask assigns the user task to userId. The engine refuses to claim a task
+// that is assigned to another user with a TaskAlreadyClaimedException.
+func (c *Client) ClaimUserTask(ctx context.Context, taskId string, userId string) error {
+    return c.post(ctx, "/task/"+url.PathEscape(taskId)+"/claim", map[string]any{"userId": userId}, nil)
+}
+
+// CompleteUserTask completes the user task and sets the given process variables.
+func (c *Client) CompleteUserTask(ctx context.Context, taskId string, variables map[string]any) error {
+    encoded, err := NewVariables(variables)
+    if err != nil {
+        return err
+    }
+    return c.post(ctx, "/task/"+url.PathEscape(taskId)+"/complete", map[string]any{"variables": encoded}, nil)
+}
+
+// ProcessInstance is a started process instance.
+type ProcessInstance struct {
+    Id           string `json:"id"`
+    DefinitionId string `json:"definitionId"`
+    BusinessKey  string `json:"businessKey"`
+    Ended        bool   `json:"ended"`
+}
+
+// StartProcess starts the latest version of the process definition with the given key.
+func (c *Client) StartProcess(ctx context.Context, definitionKey string, businessKey string, variables map[string]any) (*ProcessInstance, error) {
+    encoded, err := NewVariables(variables)
+    if err != nil {
+        return nil, err
+    }
+    body := map[string]any{"businessKey": businessKey, "variables": encoded}
+    instance := &ProcessInstance{}
+    if err := c.post(ctx, "/process-definition/key/"+url.PathEscape(definitionKey)+"/start", body, instance); err != nil {
+        return nil, err
+    }
+    return instance, nil
+}
+
+// ProcessInstances lists the running instances of the process definition with the given key
+// that were started with businessKey.
+func (c *Client) ProcessInstances(ctx context.Context, definitionKey string, businessKey string) ([]ProcessInstance, error) {
+    instances := []ProcessInstance{}
+    query := url.Values{"processDefinitionKey": {definitionKey}, "businessKey": {businessKey}}
+    if err := c.get(ctx, "/process-instance?"+query.Encode(), &instances); err != nil {
+        return nil, err
+    }
+    return instances, nil
+}
+
+// ProcessInstance returns the running process instance with the given id.
+func (c *Client) ProcessInstance(ctx context.Context, id string) (*ProcessInstance, error) {
+    instance := &ProcessInstance{}
+    if err := c.get(ctx, "/process-instance/"+url.PathEscape(id), instance); err != nil {
+        return nil, err
+    }
+    return instance, nil
+}
+
+// ActivityInstance is an entry of the activity history of a process instance.
+type ActivityInstance struct {
+    Id           string `json:"id"`
+    ActivityId   string `json:"activityId"`
+    ActivityName string `json:"activityName"`
+    ActivityType string `json:"activityType"`
+    Assignee     string `json:"assignee"`
+    StartTime    string `json:"startTime"`
+
+    // EndTime is empty while the activity runs.
+    EndTime  string `json:"endTime"`
+    Canceled bool   `json:"canceled"`
+}
+
+// ActivityHistory lists the activities of the process instance in the order they started,
+// including those of ended instances.
+func (c *Client) ActivityHistory(ctx context.Context, processInstanceId string) ([]ActivityInstance, error) {
+    activities := []ActivityInstance{}
+    query := url.Values{"processInstanceId": {processInstanceId}, "sortBy": {"startTime"}, "sortOrder": {"asc"}}
+    if err := c.get(ctx, "/history/activity-instance?"+query.Encode(), &activities); err != nil {
+        return nil, err
+    }
+    return activities, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, response any) error {
+    return c.do(ctx, http.MethodGet, path, nil, response)
+}
+
+func (c *Client) post(ctx context.Context, path string, request any, response any) error {
+    body, err := json.Marshal(request)
+    if err != nil {
+        return fmt.Errorf("encoding request to %s: %w", path, err)
+    }
+    return c.do(ctx, http.MethodPost, path, bytes.NewReader(body), response)
+}
+
+// do sends a request and decodes the response into response, unless it is nil.
+func (c *Client) do(ctx context.Context, method string, path string, body io.Reader, response any) error {
+    req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+    if err != nil {
+        return err
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    req.Header.Set("Accept", "application/json")
+
+    resp, err := c.http.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode >= 300 {
+        failure := &Error{StatusCode: resp.StatusCode}
+        data, _ := io.ReadAll(resp.Body)
+        if json.Unmarshal(data, failure) != nil {
+            failure.Message = strings.TrimSpace(string(data))
+        }
+        return failure
+    }
+    if response == nil {
+        return nil
+    }
+    if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+        return fmt.Errorf("decoding response of %s: %w", path, err)
+    }
+    return nil
+}
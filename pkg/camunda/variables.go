@@ -0,0 +1,125 @@
+package camunda
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+// ErrMissingVariable is returned by Get for variables that are not set or null.
+var ErrMissingVariable = errors.New("missing process variable")
+
+// Variable is a typed process variable as exchanged with the REST API.
+type Variable struct {
+    Type      string          `json:"type,omitempty"`
+    Value     json.RawMessage `json:"value"`
+    ValueInfo map[string]any  `json:"valueInfo,omitempty"`
+}
+
+// Variables are process variables by name.
+type Variables map[string]Variable
+
+// NewVariable encodes value with the type of the engine that matches its Go type.
+// Strings, booleans, integers and floats map to String, Boolean, Long and Double,
+// nil to Null and any other value to a Json variable. A Variable is used as it is.
+func NewVariable(value any) (Variable, error) {
+    var variableType string
+    switch value := value.(type) {
+    case Variable:
+        return value, nil
+    case nil:
+        return Variable{Type: "Null", Value: json.RawMessage("null")}, nil
+    case string:
+        variableType = "String"
+    case bool:
+        variableType = "Boolean"
+    case int, int64, uint, uint32, uint64:
+        variableType = "Long"
+    case int32, int16, int8, uint16, uint8:
+        variableType = "Integer"
+    case float32, float64:
+        variableType = "Double"
+    default:
+        serialized, err := json.Marshal(value)
+        if err != nil {
+            return Variable{}, err
+        }
+        value = string(serialized)
+        variableType = "Json"
+    }
+
+    encoded, err := json.Marshal(value)
+    if err != nil {
+        return Variable{}, err
+    }
+    return Variable{Type: variableType, Value: encoded}, nil
+}
+
+// NewVariables encodes every value with NewVariable.
+func NewVariables(values map[string]any) (Variables, error) {
+    variables := Variables{}
+    for name, value := range values {
+        variable, err := NewVariable(value)
+        if err != nil {
+            return nil, fmt.Errorf("encoding variable %s: %w", name, err)
+        }
+        variables[name] = variable
+    }
+    return variables, nil
+}
+
+// Get decodes the variable name into T. Json variables are decoded from their serialized
+// value, as are String variables holding JSON when T is not a string.
+func Get[T any](variables Variables, name string) (T, error) {
+    var value T
+    variable, ok := variables[name]
+    if !ok || variable.isNull() {
+        return value, fmt.Errorf("%w %s", ErrMissingVariable, name)
+    }
+
+    err := json.Unmarshal(variable.decoded(), &value)
+    if err != nil && variable.Type == "String" {
+        var serialized string
+        if json.Unmarshal(variable.Value, &serialized) == nil && json.Unmarshal([]byte(serialized), &value) == nil {
+            err = nil
+        }
+    }
+    if err != nil {
+        return value, fmt.Errorf("decoding variable %s: %w", name, err)
+    }
+    return value, nil
+}
+
+// Decode decodes the variables into target, a pointer to a struct whose json field tags name the variables.
+func (v Variables) Decode(target any) error {
+    fields := map[string]json.RawMessage{}
+    for name, variable := range v {
+        if !variable.isNull() {
+            fields[name] = variable.decoded()
+        }
+    }
+    encoded, err := json.Marshal(fields)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(encoded, target)
+}
+
+func (v Variable) isNull() bool {
+    return len(v.Value) == 0 || bytes.Equal(v.Value, []byte("null"))
+}
+
+// decoded returns the JSON value of the variable, unwrapping the serialized value of Json variables.
+func (v Variable) decoded() json.RawMessage {
+    serializedJson := v.Type == "Json" ||
+        (v.Type == "Object" && v.ValueInfo["serializationDataFormat"] == "application/json")
+    if !serializedJson {
+        return v.Value
+    }
+    var serialized string
+    if err := json.Unmarshal(v.Value, &serialized); err != nil || !json.Valid([]byte(serialized)) {
+        return v.Value
+    }
+    return json.RawMessage(serialized)
+}
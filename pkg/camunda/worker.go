@@ -0,0 +1,396 @@
+package camunda
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// ErrInvalidVariables is wrapped by the errors of Typed handlers whose variables do not decode.
+var ErrInvalidVariables = errors.New("invalid process variables")
+
+// BpmnError is returned by handlers for business errors. The worker throws it as a BPMN error,
+// which a matching error boundary event of the task catches, instead of reporting a failure.
+type BpmnError struct {
+    Code    string
+    Message string
+
+    // Variables are set in the process before the error is thrown.
+    Variables map[string]any
+}
+
+// NewBpmnError returns a BPMN error with the given error code and message.
+func NewBpmnError(code string, message string) *BpmnError {
+    return &BpmnError{Code: code, Message: message}
+}
+
+func (e *BpmnError) Error() string {
+    return fmt.Sprintf("BPMN error %s: %s", e.Code, e.Message)
+}
+
+// Duration is a time.Duration written as a string like "90s" or "10m" in configuration files.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+    return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+    var text string
+    if err := json.Unmarshal(data, &text); err != nil {
+        return fmt.Errorf("duration must be a string like \"90s\": %w", err)
+    }
+    parsed, err := time.ParseDuration(text)
+    if err != nil {
+        return err
+    }
+    *d = Duration(parsed)
+    return nil
+}
+
+// Topic configures how the tasks of one topic are fetched. Zero values use the worker defaults.
+type Topic struct {
+    Name string `json:"-"`
+
+    // LockDuration is how long a fetched task stays locked for this worker.
+    LockDuration Duration `json:"lock_duration,omitempty"`
+
+    // Variables limits the fetched variables, all are fetched if it is empty.
+    Variables []string `json:"variables,omitempty"`
+
+    // Retries is the number of times a failed task is retried before the engine raises an incident.
+    Retries int `json:"retries,omitempty"`
+
+    // InvalidVariablesError, if set, is the code of the BPMN error thrown when variables are
+    // missing or do not decode, see ErrMissingVariable and ErrInvalidVariables. Otherwise
+    // such tasks fail without retries.
+    InvalidVariablesError string `json:"invalid_variables_error,omitempty"`
+}
+
+// Config configures a Worker, see LoadConfig.
+type Config struct {
+    URL          string   `json:"camunda_url,omitempty"`
+    WorkerId     string   `json:"worker_id,omitempty"`
+    MaxTasks     int      `json:"max_tasks,omitempty"`
+    PollInterval Duration `json:"poll_interval,omitempty"`
+    LockDuration Duration `json:"lock_duration,omitempty"`
+
+    // Retries is the default number of retries of failed tasks, RetryBackoff the delay before
+    // the first retry. The delay doubles with every retry up to MaxRetryBackoff.
+    Retries         int      `json:"retries,omitempty"`
+    RetryBackoff    Duration `json:"retry_backoff,omitempty"`
+    MaxRetryBackoff Duration `json:"max_retry_backoff,omitempty"`
+
+    // Topics override the settings of registered topics by topic name.
+    Topics map[string]Topic `json:"topics,omitempty"`
+}
+
+// LoadConfig reads the JSON file named by AMBULANCE_WORKER_CONFIG, if set, and overrides it
+// with the environment variables:
+//
+//   - AMBULANCE_WORKER_CAMUNDA_URL, http://localhost:8082/engine-rest by default
+//   - AMBULANCE_WORKER_ID, wac-go-worker by default
+//   - AMBULANCE_WORKER_MAX_TASKS, tasks fetched at once, 5 by default
+//   - AMBULANCE_WORKER_POLL_INTERVAL, wait after an empty fetch, 5s by default
+//   - AMBULANCE_WORKER_LOCK_DURATION, default lock of fetched tasks, 10m by default
+//   - AMBULANCE_WORKER_RETRIES, retries of failed tasks, 3 by default
+//   - AMBULANCE_WORKER_RETRY_BACKOFF, delay before the first retry, 10s by default
+//   - AMBULANCE_WORKER_MAX_RETRY_BACKOFF, longest delay between retries, 10m by default
+//
+// Per-topic settings can only be given in the file, e.g.
+//
+//	{"lock_duration": "5m", "topics": {"taskTopic2": {"lock_duration": "30s", "variables": ["procedureData"]}}}
+func LoadConfig() (Config, error) {
+    config := Config{}
+    if path := os.Getenv("AMBULANCE_WORKER_CONFIG"); path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return config, err
+        }
+        if err := json.Unmarshal(data, &config); err != nil {
+            return config, fmt.Errorf("reading %s: %w", path, err)
+        }
+    }
+
+    if value, ok := os.LookupEnv("AMBULANCE_WORKER_CAMUNDA_URL"); ok {
+        config.URL = value
+    }
+    if value, ok := os.LookupEnv("AMBULANCE_WORKER_ID"); ok {
+        config.WorkerId = value
+    }
+    for name, target := range map[string]*int{
+        "AMBULANCE_WORKER_MAX_TASKS": &config.MaxTasks,
+        "AMBULANCE_WORKER_RETRIES":   &config.Retries,
+    } {
+        if value, ok := os.LookupEnv(name); ok {
+            number, err := strconv.Atoi(value)
+            if err != nil || number <= 0 {
+                return config, fmt.Errorf("invalid %s value %q", name, value)
+            }
+            *target = number
+        }
+    }
+    for name, target := range map[string]*Duration{
+        "AMBULANCE_WORKER_POLL_INTERVAL":     &config.PollInterval,
+        "AMBULANCE_WORKER_LOCK_DURATION":     &config.LockDuration,
+        "AMBULANCE_WORKER_RETRY_BACKOFF":     &config.RetryBackoff,
+        "AMBULANCE_WORKER_MAX_RETRY_BACKOFF": &config.MaxRetryBackoff,
+    } {
+        if value, ok := os.LookupEnv(name); ok {
+            duration, err := time.ParseDuration(value)
+            if err != nil || duration <= 0 {
+                return config, fmt.Errorf("invalid %s value %q", name, value)
+            }
+            *target = Duration(duration)
+        }
+    }
+    return config.withDefaults(), nil
+}
+
+// withDefaults fills the empty fields with the defaults.
+func (config Config) withDefaults() Config {
+    if config.URL == "" {
+        config.URL = "http://localhost:8082/engine-rest"
+    }
+    if config.WorkerId == "" {
+        config.WorkerId = "wac-go-worker"
+    }
+    if config.MaxTasks <= 0 {
+        config.MaxTasks = 5
+    }
+    if config.PollInterval <= 0 {
+        config.PollInterval = Duration(5 * time.Second)
+    }
+    if config.LockDuration <= 0 {
+        config.LockDuration = Duration(10 * time.Minute)
+    }
+    if config.Retries <= 0 {
+        config.Retries = 3
+    }
+    if config.RetryBackoff <= 0 {
+        config.RetryBackoff = Duration(10 * time.Second)
+    }
+    if config.MaxRetryBackoff <= 0 {
+        config.MaxRetryBackoff = Duration(10 * time.Minute)
+    }
+    return config
+}
+
+// HandlerFunc handles one locked external task and returns the process variables to set
+// when the task is completed.
+type HandlerFunc func(ctx context.Context, task *ExternalTask) (map[string]any, error)
+
+// Typed adapts a handler taking the task variables decoded into In, a struct whose json
+// field tags name the variables, see Variables.Decode.
+func Typed[In any](handler func(ctx context.Context, task *ExternalTask, in In) (map[string]any, error)) HandlerFunc {
+    return func(ctx context.Context, task *ExternalTask) (map[string]any, error) {
+        var in In
+        if err := task.Variables.Decode(&in); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidVariables, err)
+        }
+        return handler(ctx, task, in)
+    }
+}
+
+// Worker fetches the external tasks of the registered topics and completes them with
+// the results of their handlers.
+type Worker struct {
+    client     *Client
+    config     Config
+    topics     map[string]Topic
+    handlers   map[string]HandlerFunc
+    onIncident func(ctx context.Context, task *ExternalTask, err error)
+}
+
+// NewWorker returns a worker without topics, empty fields of config use the defaults of LoadConfig.
+func NewWorker(client *Client, config Config) *Worker {
+    return &Worker{
+        client:   client,
+        config:   config.withDefaults(),
+        topics:   map[string]Topic{},
+        handlers: map[string]HandlerFunc{},
+    }
+}
+
+// Register subscribes the worker to topic. Settings of the topic in the configuration
+// take precedence over the given ones.
+func (w *Worker) Register(topic Topic, handler HandlerFunc) {
+    if configured, ok := w.config.Topics[topic.Name]; ok {
+        if configured.LockDuration > 0 {
+            topic.LockDuration = configured.LockDuration
+        }
+        if configured.Variables != nil {
+            topic.Variables = configured.Variables
+        }
+        if configured.Retries > 0 {
+            topic.Retries = configured.Retries
+        }
+        if configured.InvalidVariablesError != "" {
+            topic.InvalidVariablesError = configured.InvalidVariablesError
+        }
+    }
+    if topic.LockDuration <= 0 {
+        topic.LockDuration = w.config.LockDuration
+    }
+    if topic.Retries <= 0 {
+        topic.Retries = w.config.Retries
+    }
+    w.topics[topic.Name] = topic
+    w.handlers[topic.Name] = handler
+}
+
+// OnIncident sets a function called after a failure without retries left was reported,
+// i.e. when the engine raised an incident for the task.
+func (w *Worker) OnIncident(fn func(ctx context.Context, task *ExternalTask, err error)) {
+    w.onIncident = fn
+}
+
+// Run fetches and handles tasks until ctx is done. Tasks are handled one after another.
+func (w *Worker) Run(ctx context.Context) {
+    request := FetchRequest{WorkerId: w.config.WorkerId, MaxTasks: w.config.MaxTasks, UsePriority: true}
+    for _, topic := range w.topics {
+        request.Topics = append(request.Topics, FetchTopic{
+            TopicName:    topic.Name,
+            LockDuration: time.Duration(topic.LockDuration).Milliseconds(),
+            Variables:    topic.Variables,
+        })
+    }
+    sort.Slice(request.Topics, func(i, j int) bool { return request.Topics[i].TopicName < request.Topics[j].TopicName })
+    log.Printf("Worker %s fetching %d topics from %s", w.config.WorkerId, len(request.Topics), w.client.url)
+
+    for ctx.Err() == nil {
+        tasks, err := w.client.FetchAndLock(ctx, request)
+        if err != nil && ctx.Err() == nil {
+            log.Printf("Fetch error: %v", err)
+        }
+        for i := range tasks {
+            w.handle(ctx, &tasks[i])
+        }
+        if len(tasks) == 0 {
+            sleep(ctx, time.Duration(w.config.PollInterval))
+        }
+    }
+}
+
+// handle runs the handler of a task, extending its lock while the handler runs, and
+// completes the task or reports the error of the handler.
+func (w *Worker) handle(ctx context.Context, task *ExternalTask) {
+    handler, ok := w.handlers[task.TopicName]
+    if !ok {
+        log.Printf("No handler for topic %s", task.TopicName)
+        return
+    }
+    topic := w.topics[task.TopicName]
+
+    handlerCtx, cancel := context.WithCancel(ctx)
+    extending := make(chan struct{})
+    go func() {
+        defer close(extending)
+        w.extendLock(handlerCtx, cancel, task, time.Duration(topic.LockDuration))
+    }()
+    variables, err := handler(handlerCtx, task)
+    cancel()
+    <-extending
+
+    switch {
+    case err == nil:
+        err = w.client.Complete(ctx, task.Id, w.config.WorkerId, variables)
+    case errors.As(err, new(*BpmnError)):
+        err = w.throw(ctx, task, err)
+    case topic.InvalidVariablesError != "" && (errors.Is(err, ErrMissingVariable) || errors.Is(err, ErrInvalidVariables)):
+        err = w.throw(ctx, task, &BpmnError{Code: topic.InvalidVariablesError, Message: err.Error()})
+    default:
+        err = w.fail(ctx, task, topic, err)
+    }
+    if err != nil {
+        log.Printf("[%s] Failed to report the outcome of task %s: %v", task.TopicName, task.Id, err)
+    }
+}
+
+// throw throws the BPMN error returned by a handler.
+func (w *Worker) throw(ctx context.Context, task *ExternalTask, err error) error {
+    var bpmnError *BpmnError
+    errors.As(err, &bpmnError)
+    log.Printf("[%s] Task %s raised %v", task.TopicName, task.Id, bpmnError)
+    return w.client.HandleBpmnError(ctx, task.Id, w.config.WorkerId, bpmnError)
+}
+
+// fail reports a failed attempt. The first failure sets the retries of the topic, later ones
+// count them down; errors about the variables are not retried.
+func (w *Worker) fail(ctx context.Context, task *ExternalTask, topic Topic, err error) error {
+    retries := topic.Retries
+    if task.Retries != nil {
+        retries = *task.Retries - 1
+    }
+    if errors.Is(err, ErrMissingVariable) || errors.Is(err, ErrInvalidVariables) {
+        retries = 0
+    }
+    retries = max(retries, 0)
+
+    // the delay doubles with every attempt since the first failure
+    backoff := time.Duration(w.config.RetryBackoff)
+    for attempt := retries; attempt < topic.Retries && backoff < time.Duration(w.config.MaxRetryBackoff); attempt++ {
+        backoff *= 2
+    }
+    backoff = min(backoff, time.Duration(w.config.MaxRetryBackoff))
+
+    log.Printf("[%s] Task %s failed, %d retries left: %v", task.TopicName, task.Id, retries, err)
+    message := err.Error()
+    if len(message) > maxErrorMessage {
+        message = message[:maxErrorMessage]
+    }
+    failure := Failure{
+        WorkerId:     w.config.WorkerId,
+        ErrorMessage: message,
+        ErrorDetails: err.Error(),
+        Retries:      retries,
+        RetryTimeout: backoff.Milliseconds(),
+    }
+    if err := w.client.HandleFailure(ctx, task.Id, failure); err != nil {
+        return err
+    }
+    if retries == 0 && w.onIncident != nil {
+        w.onIncident(ctx, task, err)
+    }
+    return nil
+}
+
+// maxErrorMessage is the length of the error message column of the engine.
+const maxErrorMessage = 666
+
+// extendLock extends the lock of task every half lock duration until ctx is done. If the lock
+// cannot be extended, the task is likely locked by another worker now, so the handler is cancelled.
+func (w *Worker) extendLock(ctx context.Context, cancel context.CancelFunc, task *ExternalTask, lockDuration time.Duration) {
+    ticker := time.NewTicker(lockDuration / 2)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if err := w.client.ExtendLock(ctx, task.Id, w.config.WorkerId, lockDuration); err != nil {
+                if ctx.Err() == nil {
+                    log.Printf("[%s] Failed to extend the lock of task %s, cancelling it: %v", task.TopicName, task.Id, err)
+                    cancel()
+                }
+                return
+            }
+        }
+    }
+}
+
+// sleep waits for delay or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) {
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+    case <-ctx.Done():
+    }
+}
@@ -0,0 +1,297 @@
+package camunda
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/suite"
+)
+
+// fakeEngine serves fetchAndLock with the queued tasks, once, and records the other requests.
+type fakeEngine struct {
+    lock      sync.Mutex
+    tasks     []ExternalTask
+    fetches   []FetchRequest
+    completed map[string]map[string]Variable
+    reported  map[string][]map[string]any
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    e.lock.Lock()
+    defer e.lock.Unlock()
+    switch {
+    case r.URL.Path == "/external-task/fetchAndLock":
+        var request FetchRequest
+        json.NewDecoder(r.Body).Decode(&request)
+        e.fetches = append(e.fetches, request)
+        json.NewEncoder(w).Encode(e.tasks)
+        e.tasks = nil
+    case strings.HasSuffix(r.URL.Path, "/complete"):
+        var body struct {
+            Variables map[string]Variable `json:"variables"`
+        }
+        json.NewDecoder(r.Body).Decode(&body)
+        e.completed[strings.Split(r.URL.Path, "/")[2]] = body.Variables
+        w.WriteHeader(http.StatusNoContent)
+    case strings.HasPrefix(r.URL.Path, "/external-task/"):
+        var body map[string]any
+        json.NewDecoder(r.Body).Decode(&body)
+        e.reported[r.URL.Path] = append(e.reported[r.URL.Path], body)
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        w.WriteHeader(http.StatusNotFound)
+        w.Write([]byte(`{"type":"InvalidRequestException","message":"not found"}`))
+    }
+}
+
+type WorkerSuite struct {
+    suite.Suite
+    engine *fakeEngine
+    server *httptest.Server
+    client *Client
+}
+
+func TestWorkerSuite(t *testing.T) {
+    suite.Run(t, new(WorkerSuite))
+}
+
+func (suite *WorkerSuite) SetupTest() {
+    suite.engine = &fakeEngine{completed: map[string]map[string]Variable{}, reported: map[string][]map[string]any{}}
+    suite.server = httptest.NewServer(suite.engine)
+    suite.client = NewClient(suite.server.URL + "/")
+}
+
+func (suite *WorkerSuite) TearDownTest() {
+    suite.server.Close()
+}
+
+// run runs worker until the engine saw two fetches, i.e. the queued tasks were handled.
+func (suite *WorkerSuite) run(worker *Worker) {
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        worker.Run(ctx)
+        close(done)
+    }()
+    suite.Eventually(func() bool {
+        suite.engine.lock.Lock()
+        defer suite.engine.lock.Unlock()
+        return len(suite.engine.fetches) >= 2
+    }, time.Second, time.Millisecond)
+    cancel()
+    <-done
+}
+
+func (suite *WorkerSuite) Test_Run_DispatchesByTopicAndCompletesWithTypedVariables() {
+    procedure, _ := NewVariable(map[string]any{"id": "p1", "price": 12.5})
+    suite.engine.tasks = []ExternalTask{
+        {Id: "t1", TopicName: "save", Variables: Variables{"procedureData": procedure}},
+        {Id: "t2", TopicName: "notify"},
+    }
+    type saveInput struct {
+        ProcedureData struct {
+            Id    string  `json:"id"`
+            Price float64 `json:"price"`
+        } `json:"procedureData"`
+    }
+    var saved saveInput
+    worker := NewWorker(suite.client, Config{PollInterval: Duration(time.Millisecond)})
+    worker.Register(Topic{Name: "save"}, Typed(func(_ context.Context, _ *ExternalTask, in saveInput) (map[string]any, error) {
+        saved = in
+        return map[string]any{"procedureId": in.ProcedureData.Id, "valid": true, "count": 2}, nil
+    }))
+    worker.Register(Topic{Name: "notify"}, func(context.Context, *ExternalTask) (map[string]any, error) {
+        return nil, nil
+    })
+
+    suite.run(worker)
+    suite.Equal("p1", saved.ProcedureData.Id)
+    suite.Equal(12.5, saved.ProcedureData.Price)
+    suite.Equal(map[string]Variable{
+        "procedureId": {Type: "String", Value: json.RawMessage(`"p1"`)},
+        "valid":       {Type: "Boolean", Value: json.RawMessage(`true`)},
+        "count":       {Type: "Long", Value: json.RawMessage(`2`)},
+    }, suite.engine.completed["t1"])
+    suite.Contains(suite.engine.completed, "t2")
+}
+
+func (suite *WorkerSuite) Test_Run_ReportsFailuresWithRetriesAndBackoff() {
+    retries := 2
+    suite.engine.tasks = []ExternalTask{
+        {Id: "first", TopicName: "billing"},
+        {Id: "retried", TopicName: "billing", Retries: &retries},
+        {Id: "invalid", TopicName: "save"},
+    }
+    worker := NewWorker(suite.client, Config{PollInterval: Duration(time.Millisecond), Retries: 3})
+    worker.Register(Topic{Name: "billing"}, func(context.Context, *ExternalTask) (map[string]any, error) {
+        return nil, errors.New("payments unavailable")
+    })
+    worker.Register(Topic{Name: "save"}, func(context.Context, *ExternalTask) (map[string]any, error) {
+        return nil, fmt.Errorf("%w procedureData", ErrMissingVariable)
+    })
+    incidents := []string{}
+    worker.OnIncident(func(_ context.Context, task *ExternalTask, err error) {
+        incidents = append(incidents, task.Id+": "+err.Error())
+    })
+
+    suite.run(worker)
+    failure := func(retries float64, timeout float64, message string) []map[string]any {
+        return []map[string]any{{
+            "workerId":     "wac-go-worker",
+            "errorMessage": message,
+            "errorDetails": message,
+            "retries":      retries,
+            "retryTimeout": timeout,
+        }}
+    }
+    suite.Equal(failure(3, 10000, "payments unavailable"), suite.engine.reported["/external-task/first/failure"])
+    suite.Equal(failure(1, 40000, "payments unavailable"), suite.engine.reported["/external-task/retried/failure"])
+    suite.Equal(failure(0, 80000, "missing process variable procedureData"), suite.engine.reported["/external-task/invalid/failure"])
+    suite.Equal([]string{"invalid: missing process variable procedureData"}, incidents)
+}
+
+func (suite *WorkerSuite) Test_Run_ThrowsBpmnErrors() {
+    suite.engine.tasks = []ExternalTask{
+        {Id: "rejected", TopicName: "validate"},
+        {Id: "undecodable", TopicName: "save", Variables: Variables{"procedureData": {Type: "Json", Value: json.RawMessage(`"[]"`)}}},
+    }
+    worker := NewWorker(suite.client, Config{PollInterval: Duration(time.Millisecond)})
+    worker.Register(Topic{Name: "validate"}, func(context.Context, *ExternalTask) (map[string]any, error) {
+        return nil, &BpmnError{Code: "INVALID", Message: "price is negative", Variables: map[string]any{"valid": false}}
+    })
+    type saveInput struct {
+        ProcedureData struct{ Id string } `json:"procedureData"`
+    }
+    worker.Register(Topic{Name: "save", InvalidVariablesError: "INVALID"}, Typed(func(context.Context, *ExternalTask, saveInput) (map[string]any, error) {
+        return nil, nil
+    }))
+
+    suite.run(worker)
+    suite.Equal([]map[string]any{{
+        "workerId":     "wac-go-worker",
+        "errorCode":    "INVALID",
+        "errorMessage": "price is negative",
+        "variables":    map[string]any{"valid": map[string]any{"type": "Boolean", "value": false}},
+    }}, suite.engine.reported["/external-task/rejected/bpmnError"])
+    suite.Require().Len(suite.engine.reported["/external-task/undecodable/bpmnError"], 1)
+    thrown := suite.engine.reported["/external-task/undecodable/bpmnError"][0]
+    suite.Equal("INVALID", thrown["errorCode"])
+    suite.Contains(thrown["errorMessage"], ErrInvalidVariables.Error())
+}
+
+func (suite *WorkerSuite) Test_Run_ExtendsLockOfLongRunningHandlers() {
+    suite.engine.tasks = []ExternalTask{{Id: "slow", TopicName: "notify"}}
+    worker := NewWorker(suite.client, Config{PollInterval: Duration(time.Millisecond)})
+    worker.Register(Topic{Name: "notify", LockDuration: Duration(20 * time.Millisecond)}, func(context.Context, *ExternalTask) (map[string]any, error) {
+        time.Sleep(50 * time.Millisecond)
+        return nil, nil
+    })
+
+    suite.run(worker)
+    suite.Contains(suite.engine.completed, "slow")
+    extended := suite.engine.reported["/external-task/slow/extendLock"]
+    suite.GreaterOrEqual(len(extended), 2)
+    suite.Equal(map[string]any{"workerId": "wac-go-worker", "newDuration": float64(20)}, extended[0])
+}
+
+func (suite *WorkerSuite) Test_Register_AppliesTopicConfiguration() {
+    worker := NewWorker(suite.client, Config{
+        WorkerId:     "test-worker",
+        PollInterval: Duration(time.Millisecond),
+        Topics: map[string]Topic{
+            "validate": {LockDuration: Duration(30 * time.Second), Variables: []string{"procedureData"}},
+        },
+    })
+    handler := func(context.Context, *ExternalTask) (map[string]any, error) { return nil, nil }
+    worker.Register(Topic{Name: "validate", LockDuration: Duration(time.Hour)}, handler)
+    worker.Register(Topic{Name: "billing", Variables: []string{"procedureId"}}, handler)
+
+    suite.run(worker)
+    suite.Equal(FetchRequest{
+        WorkerId:    "test-worker",
+        MaxTasks:    5,
+        UsePriority: true,
+        Topics: []FetchTopic{
+            {TopicName: "billing", LockDuration: 600000, Variables: []string{"procedureId"}},
+            {TopicName: "validate", LockDuration: 30000, Variables: []string{"procedureData"}},
+        },
+    }, suite.engine.fetches[0])
+}
+
+func (suite *WorkerSuite) Test_Client_ReturnsEngineErrors() {
+    tasks, err := suite.client.UserTasks(context.Background(), "ApproveSubmission")
+    suite.Nil(tasks)
+    suite.Equal(&Error{StatusCode: http.StatusNotFound, Type: "InvalidRequestException", Message: "not found"}, err)
+}
+
+func (suite *WorkerSuite) Test_Get_DecodesVariableTypes() {
+    variables := Variables{
+        "json":   {Type: "Json", Value: json.RawMessage(`"{\"id\":\"p1\"}"`)},
+        "string": {Type: "String", Value: json.RawMessage(`"{\"id\":\"p2\"}"`)},
+        "number": {Type: "Long", Value: json.RawMessage(`7`)},
+        "null":   {Type: "Null", Value: json.RawMessage(`null`)},
+    }
+    type procedure struct {
+        Id string `json:"id"`
+    }
+
+    fromJson, err := Get[procedure](variables, "json")
+    suite.Require().NoError(err)
+    suite.Equal("p1", fromJson.Id)
+    fromString, err := Get[procedure](variables, "string")
+    suite.Require().NoError(err)
+    suite.Equal("p2", fromString.Id)
+    raw, err := Get[string](variables, "string")
+    suite.Require().NoError(err)
+    suite.Equal(`{"id":"p2"}`, raw)
+    number, err := Get[int](variables, "number")
+    suite.Require().NoError(err)
+    suite.Equal(7, number)
+
+    _, err = Get[string](variables, "null")
+    suite.ErrorIs(err, ErrMissingVariable)
+    _, err = Get[string](variables, "absent")
+    suite.ErrorIs(err, ErrMissingVariable)
+}
+
+func (suite *WorkerSuite) Test_LoadConfig_OverridesFileWithEnvironment() {
+    path := filepath.Join(suite.T().TempDir(), "worker.json")
+    suite.Require().NoError(os.WriteFile(path, []byte(`{
+        "worker_id": "from-file",
+        "max_tasks": 10,
+        "lock_duration": "5m",
+        "topics": {"taskTopic2": {"lock_duration": "30s"}}
+    }`), 0o600))
+    suite.T().Setenv("AMBULANCE_WORKER_CONFIG", path)
+    suite.T().Setenv("AMBULANCE_WORKER_ID", "from-env")
+    suite.T().Setenv("AMBULANCE_WORKER_POLL_INTERVAL", "1s")
+    suite.T().Setenv("AMBULANCE_WORKER_RETRIES", "5")
+
+    config, err := LoadConfig()
+    suite.Require().NoError(err)
+    suite.Equal(Config{
+        URL:             "http://localhost:8082/engine-rest",
+        WorkerId:        "from-env",
+        MaxTasks:        10,
+        PollInterval:    Duration(time.Second),
+        LockDuration:    Duration(5 * time.Minute),
+        Retries:         5,
+        RetryBackoff:    Duration(10 * time.Second),
+        MaxRetryBackoff: Duration(10 * time.Minute),
+        Topics:          map[string]Topic{"taskTopic2": {LockDuration: Duration(30 * time.Second)}},
+    }, config)
+
+    suite.T().Setenv("AMBULANCE_WORKER_LOCK_DURATION", "soon")
+    _, err = LoadConfig()
+    suite.Error(err)
+}
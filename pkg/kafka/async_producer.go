@@ -0,0 +1,260 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+var (
+    // ErrQueueFull is returned by AsyncProducer.Send under OverflowReject when the queue is full.
+    ErrQueueFull = errors.New("kafka producer queue is full")
+    // ErrProducerClosed is returned by AsyncProducer.Send after Close was called.
+    ErrProducerClosed = errors.New("kafka producer is closed")
+    // ErrDropped is reported to the delivery callback of messages evicted under OverflowDropOldest.
+    ErrDropped = errors.New("message dropped from the full kafka producer queue")
+)
+
+// OverflowPolicy decides what AsyncProducer.Send does when the queue is full.
+type OverflowPolicy int
+
+const (
+    // OverflowBlock waits for room in the queue until the context of Send is done.
+    OverflowBlock OverflowPolicy = iota
+    // OverflowReject fails Send with ErrQueueFull.
+    OverflowReject
+    // OverflowDropOldest evicts the oldest queued message, reporting ErrDropped for it.
+    OverflowDropOldest
+)
+
+// DeliveryFunc is called once for every message accepted by AsyncProducer.Send, with the
+// error of the write or nil. It runs on the producer goroutine, or on the goroutine of Send
+// for dropped messages, and must not block.
+type DeliveryFunc func(message kafka.Message, err error)
+
+// AsyncConfig tunes an AsyncProducer. Zero values select the defaults.
+type AsyncConfig struct {
+    // QueueSize is the number of messages waiting to be written, 1000 by default.
+    QueueSize int
+
+    // BatchSize is the maximum number of messages per write, 100 by default.
+    BatchSize int
+
+    // BatchTimeout is how long a message waits for its batch to fill, 100ms by default.
+    BatchTimeout time.Duration
+
+    // WriteTimeout limits the write of one batch, 10s by default.
+    WriteTimeout time.Duration
+
+    Overflow OverflowPolicy
+
+    // OnDelivery, if set, is told the outcome of every message.
+    OnDelivery DeliveryFunc
+}
+
+// AsyncProducer queues messages in memory and writes them in batches on a background goroutine.
+// Messages are written in the order they were queued. Close drains the queue.
+type AsyncProducer struct {
+    writer MessageWriter
+    config AsyncConfig
+    queue  chan kafka.Message
+
+    // lock guards closed; senders hold it for reading so that Close can wait for them
+    lock    sync.RWMutex
+    closed  bool
+    closing chan struct{}
+    drain   chan struct{}
+    stopped chan struct{}
+    once    sync.Once
+
+    writeCtx    context.Context
+    cancelWrite context.CancelFunc
+}
+
+// NewAsyncProducer starts a producer writing with writer, which it owns and closes on Close.
+func NewAsyncProducer(writer MessageWriter, config AsyncConfig) *AsyncProducer {
+    if config.QueueSize <= 0 {
+        config.QueueSize = 1000
+    }
+    if config.BatchSize <= 0 {
+        config.BatchSize = 100
+    }
+    if config.BatchTimeout <= 0 {
+        config.BatchTimeout = 100 * time.Millisecond
+    }
+    if config.WriteTimeout <= 0 {
+        config.WriteTimeout = 10 * time.Second
+    }
+
+    writeCtx, cancelWrite := context.WithCancel(context.Background())
+    p := &AsyncProducer{
+        writer:      writer,
+        config:      config,
+        queue:       make(chan kafka.Message, config.QueueSize),
+        closing:     make(chan struct{}),
+        drain:       make(chan struct{}),
+        stopped:     make(chan struct{}),
+        writeCtx:    writeCtx,
+        cancelWrite: cancelWrite,
+    }
+    go p.run()
+    return p
+}
+
+// Send queues message. It returns once the message is queued, the outcome of the write is
+// reported to AsyncConfig.OnDelivery. When the queue is full it applies the overflow policy.
+func (p *AsyncProducer) Send(ctx context.Context, message kafka.Message) error {
+    p.lock.RLock()
+    defer p.lock.RUnlock()
+    if p.closed {
+        return ErrProducerClosed
+    }
+
+    select {
+    case p.queue <- message:
+        return nil
+    default:
+    }
+
+    switch p.config.Overflow {
+    case OverflowReject:
+        return ErrQueueFull
+    case OverflowDropOldest:
+        for {
+            select {
+            case p.queue <- message:
+                return nil
+            case oldest := <-p.queue:
+                p.deliver([]kafka.Message{oldest}, ErrDropped)
+            }
+        }
+    default:
+        select {
+        case p.queue <- message:
+            return nil
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-p.closing:
+            return ErrProducerClosed
+        }
+    }
+}
+
+// SendAsync queues a message with the given key and value without waiting for room in the
+// queue. A full queue or a closed producer is reported to AsyncConfig.OnDelivery like a
+// failed write, so every message has exactly one outcome.
+func (p *AsyncProducer) SendAsync(key, value []byte) {
+    message := kafka.Message{Key: key, Value: value}
+    // a done context makes Send return instead of waiting under OverflowBlock
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if err := p.Send(ctx, message); err != nil {
+        if errors.Is(err, context.Canceled) {
+            err = ErrQueueFull
+        }
+        p.deliver([]kafka.Message{message}, err)
+    }
+}
+
+// Pending returns the number of queued messages not yet handed to the writer.
+func (p *AsyncProducer) Pending() int {
+    return len(p.queue)
+}
+
+// Close stops accepting messages, writes the queued ones and closes the writer.
+// If ctx is done first, the remaining messages are reported as failed with the context error.
+// Close may be called more than once.
+func (p *AsyncProducer) Close(ctx context.Context) error {
+    p.once.Do(func() {
+        close(p.closing)
+        p.lock.Lock()
+        p.closed = true
+        p.lock.Unlock()
+        close(p.drain)
+    })
+
+    var err error
+    select {
+    case <-p.stopped:
+    case <-ctx.Done():
+        err = ctx.Err()
+        p.cancelWrite()
+        <-p.stopped
+    }
+    p.cancelWrite()
+    return errors.Join(err, p.writer.Close())
+}
+
+// run collects queued messages into batches until the queue is drained after Close.
+func (p *AsyncProducer) run() {
+    defer close(p.stopped)
+
+    batch := make([]kafka.Message, 0, p.config.BatchSize)
+    timer := time.NewTimer(p.config.BatchTimeout)
+    timer.Stop()
+    add := func(message kafka.Message) {
+        if len(batch) == 0 {
+            timer.Reset(p.config.BatchTimeout)
+        }
+        batch = append(batch, message)
+        if len(batch) >= p.config.BatchSize {
+            timer.Stop()
+            p.write(batch)
+            batch = make([]kafka.Message, 0, p.config.BatchSize)
+        }
+    }
+
+    for {
+        select {
+        case message := <-p.queue:
+            add(message)
+        case <-timer.C:
+            p.write(batch)
+            batch = make([]kafka.Message, 0, p.config.BatchSize)
+        case <-p.drain:
+            for {
+                select {
+                case message := <-p.queue:
+                    add(message)
+                default:
+                    timer.Stop()
+                    if len(batch) > 0 {
+                        p.write(batch)
+                    }
+                    return
+                }
+            }
+        }
+    }
+}
+
+func (p *AsyncProducer) write(batch []kafka.Message) {
+    if len(batch) == 0 {
+        return
+    }
+    ctx, cancel := context.WithTimeout(p.writeCtx, p.config.WriteTimeout)
+    defer cancel()
+    err := p.writer.WriteMessages(ctx, batch...)
+
+    // kafka.Writer reports the outcome of each message of a partially written batch
+    var perMessage kafka.WriteErrors
+    if errors.As(err, &perMessage) && len(perMessage) == len(batch) {
+        for i := range batch {
+            p.deliver(batch[i:i+1], perMessage[i])
+        }
+        return
+    }
+    p.deliver(batch, err)
+}
+
+func (p *AsyncProducer) deliver(messages []kafka.Message, err error) {
+    if p.config.OnDelivery == nil {
+        return
+    }
+    for _, message := range messages {
+        p.config.OnDelivery(message, err)
+    }
+}
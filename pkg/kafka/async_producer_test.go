@@ -0,0 +1,167 @@
+package kafka
+
+import (
+    "context"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+    "github.com/stretchr/testify/suite"
+)
+
+// gatedWriter records batches; while gate is set, writes wait for it to close or for their context.
+type gatedWriter struct {
+    lock    sync.Mutex
+    batches [][]string
+    gate    chan struct{}
+    writing chan struct{}
+}
+
+func (w *gatedWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+    if w.gate != nil {
+        w.writing <- struct{}{}
+        select {
+        case <-w.gate:
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+    w.lock.Lock()
+    defer w.lock.Unlock()
+    batch := []string{}
+    for _, message := range messages {
+        batch = append(batch, string(message.Value))
+    }
+    w.batches = append(w.batches, batch)
+    return nil
+}
+
+func (w *gatedWriter) Close() error { return nil }
+
+func (w *gatedWriter) written() [][]string {
+    w.lock.Lock()
+    defer w.lock.Unlock()
+    return append([][]string(nil), w.batches...)
+}
+
+type AsyncProducerSuite struct {
+    suite.Suite
+    writer    *gatedWriter
+    lock      sync.Mutex
+    delivered map[string]error
+}
+
+func TestAsyncProducerSuite(t *testing.T) {
+    suite.Run(t, new(AsyncProducerSuite))
+}
+
+func (suite *AsyncProducerSuite) SetupTest() {
+    suite.writer = &gatedWriter{}
+    suite.delivered = map[string]error{}
+}
+
+func (suite *AsyncProducerSuite) producer(config AsyncConfig) *AsyncProducer {
+    config.OnDelivery = func(message kafka.Message, err error) {
+        suite.lock.Lock()
+        defer suite.lock.Unlock()
+        suite.delivered[string(message.Value)] = err
+    }
+    return NewAsyncProducer(suite.writer, config)
+}
+
+func (suite *AsyncProducerSuite) gate() {
+    suite.writer.gate = make(chan struct{})
+    suite.writer.writing = make(chan struct{}, 10)
+}
+
+func (suite *AsyncProducerSuite) send(producer *AsyncProducer, values ...string) {
+    for _, value := range values {
+        suite.Require().NoError(producer.Send(context.Background(), kafka.Message{Value: []byte(value)}))
+    }
+}
+
+func (suite *AsyncProducerSuite) Test_Close_WritesQueuedMessagesInBatches() {
+    producer := suite.producer(AsyncConfig{BatchSize: 3, BatchTimeout: time.Hour})
+    suite.send(producer, "1", "2", "3", "4", "5", "6", "7")
+
+    suite.Require().NoError(producer.Close(context.Background()))
+    suite.Equal([][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7"}}, suite.writer.written())
+    suite.Len(suite.delivered, 7)
+    for _, err := range suite.delivered {
+        suite.NoError(err)
+    }
+    suite.ErrorIs(producer.Send(context.Background(), kafka.Message{}), ErrProducerClosed)
+}
+
+func (suite *AsyncProducerSuite) Test_Send_FlushesPartialBatchAfterTimeout() {
+    producer := suite.producer(AsyncConfig{BatchTimeout: time.Millisecond})
+    defer producer.Close(context.Background())
+    suite.send(producer, "1")
+
+    suite.Eventually(func() bool { return len(suite.writer.written()) == 1 }, time.Second, time.Millisecond)
+}
+
+func (suite *AsyncProducerSuite) Test_Send_AppliesOverflowPolicy() {
+    for _, policy := range []OverflowPolicy{OverflowReject, OverflowDropOldest, OverflowBlock} {
+        suite.SetupTest()
+        suite.gate()
+        producer := suite.producer(AsyncConfig{QueueSize: 1, BatchSize: 1, Overflow: policy})
+        suite.send(producer, "first")
+        <-suite.writer.writing
+        suite.send(producer, "second")
+
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+        err := producer.Send(ctx, kafka.Message{Value: []byte("third")})
+        cancel()
+        switch policy {
+        case OverflowReject:
+            suite.ErrorIs(err, ErrQueueFull)
+        case OverflowDropOldest:
+            suite.NoError(err)
+            suite.ErrorIs(suite.delivered["second"], ErrDropped)
+        case OverflowBlock:
+            suite.ErrorIs(err, context.DeadlineExceeded)
+        }
+
+        close(suite.writer.gate)
+        suite.Require().NoError(producer.Close(context.Background()))
+        suite.NoError(suite.delivered["first"])
+    }
+}
+
+func (suite *AsyncProducerSuite) Test_SendAsync_ReportsFullQueue() {
+    suite.gate()
+    producer := suite.producer(AsyncConfig{QueueSize: 1, BatchSize: 1})
+    suite.send(producer, "first")
+    <-suite.writer.writing
+
+    producer.SendAsync(nil, []byte("second"))
+    producer.SendAsync(nil, []byte("third"))
+    suite.ErrorIs(suite.delivered["third"], ErrQueueFull)
+
+    close(suite.writer.gate)
+    suite.Require().NoError(producer.Close(context.Background()))
+    suite.NoError(suite.delivered["second"])
+    suite.Equal([][]string{{"first"}, {"second"}}, suite.writer.written())
+}
+
+func (suite *AsyncProducerSuite) Test_Close_ReportsUnwrittenMessagesWhenContextExpires() {
+    suite.gate()
+    producer := suite.producer(AsyncConfig{BatchSize: 1})
+    suite.send(producer, "first", "second")
+    <-suite.writer.writing
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    writing := suite.writer.writing
+    go func() {
+        for range writing {
+        }
+    }()
+    suite.ErrorIs(producer.Close(ctx), context.DeadlineExceeded)
+    close(writing)
+    suite.ErrorIs(suite.delivered["first"], context.Canceled)
+    suite.ErrorIs(suite.delivered["second"], context.Canceled)
+    suite.Empty(suite.writer.written())
+}
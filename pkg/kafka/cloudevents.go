@@ -0,0 +1,185 @@
+package kafka
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/segmentio/kafka-go"
+)
+
+// SpecVersion is the CloudEvents specification version of Event.
+const SpecVersion = "1.0"
+
+// Content types of the CloudEvents Kafka protocol binding.
+const (
+    JSONContentType       = "application/json"
+    StructuredContentType = "application/cloudevents+json; charset=UTF-8"
+)
+
+// ErrNotCloudEvent is returned by ParseMessage for messages that carry no CloudEvent.
+var ErrNotCloudEvent = errors.New("message is not a CloudEvent")
+
+// ContentMode selects how an Event is mapped onto a Kafka message.
+type ContentMode int
+
+const (
+    // BinaryMode stores the attributes in ce_* headers and the data as the message value.
+    BinaryMode ContentMode = iota
+    // StructuredMode stores the whole event as JSON in the message value.
+    StructuredMode
+)
+
+// ParseContentMode accepts "binary" and "structured"; the empty string selects BinaryMode.
+func ParseContentMode(mode string) (ContentMode, error) {
+    switch strings.ToLower(mode) {
+    case "", "binary":
+        return BinaryMode, nil
+    case "structured":
+        return StructuredMode, nil
+    }
+    return BinaryMode, fmt.Errorf("unknown CloudEvents content mode %q", mode)
+}
+
+// Payload is implemented by the data of every event type.
+// Payloads may also implement SchemaVersion() int to select a schema version other than 1.
+type Payload interface {
+    EventType() string
+}
+
+// Event is a CloudEvents 1.0 envelope with JSON data.
+type Event struct {
+    SpecVersion     string          `json:"specversion"`
+    Id              string          `json:"id"`
+    Source          string          `json:"source"`
+    Type            string          `json:"type"`
+    Subject         string          `json:"subject,omitempty"`
+    Time            time.Time       `json:"time"`
+    DataContentType string          `json:"datacontenttype,omitempty"`
+    DataSchema      string          `json:"dataschema,omitempty"`
+    Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// NewEvent wraps payload in an envelope. Subject is the id of the aggregate the event belongs to.
+func NewEvent(source string, subject string, payload Payload) (*Event, error) {
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return nil, err
+    }
+    id, err := uuid.NewV7()
+    if err != nil {
+        return nil, err
+    }
+    version := 1
+    if versioned, ok := payload.(interface{ SchemaVersion() int }); ok {
+        version = versioned.SchemaVersion()
+    }
+    return &Event{
+        SpecVersion:     SpecVersion,
+        Id:              id.String(),
+        Source:          source,
+        Type:            payload.EventType(),
+        Subject:         subject,
+        Time:            time.Now().UTC(),
+        DataContentType: JSONContentType,
+        DataSchema:      SchemaURI(payload.EventType(), version),
+        Data:            data,
+    }, nil
+}
+
+// DecodeData unmarshals the event data into payload.
+func (e *Event) DecodeData(payload interface{}) error {
+    return json.Unmarshal(e.Data, payload)
+}
+
+// Message maps the event onto a Kafka message with the given key.
+func (e *Event) Message(mode ContentMode, key string) (kafka.Message, error) {
+    if mode == StructuredMode {
+        value, err := json.Marshal(e)
+        if err != nil {
+            return kafka.Message{}, err
+        }
+        return kafka.Message{
+            Key:     []byte(key),
+            Value:   value,
+            Headers: []kafka.Header{{Key: "content-type", Value: []byte(StructuredContentType)}},
+        }, nil
+    }
+
+    headers := []kafka.Header{
+        {Key: "ce_specversion", Value: []byte(e.SpecVersion)},
+        {Key: "ce_id", Value: []byte(e.Id)},
+        {Key: "ce_source", Value: []byte(e.Source)},
+        {Key: "ce_type", Value: []byte(e.Type)},
+        {Key: "ce_time", Value: []byte(e.Time.Format(time.RFC3339Nano))},
+    }
+    for _, optional := range [][2]string{
+        {"ce_subject", e.Subject},
+        {"ce_dataschema", e.DataSchema},
+        {"content-type", e.DataContentType},
+    } {
+        if optional[1] != "" {
+            headers = append(headers, kafka.Header{Key: optional[0], Value: []byte(optional[1])})
+        }
+    }
+    return kafka.Message{Key: []byte(key), Value: e.Data, Headers: headers}, nil
+}
+
+// ParseMessage reads an event from a Kafka message in either content mode.
+func ParseMessage(message kafka.Message) (*Event, error) {
+    headers := map[string]string{}
+    for _, header := range message.Headers {
+        headers[strings.ToLower(header.Key)] = string(header.Value)
+    }
+
+    if strings.HasPrefix(headers["content-type"], "application/cloudevents+json") {
+        return ParseStructured(message.Value)
+    }
+    if headers["ce_specversion"] == "" {
+        return nil, ErrNotCloudEvent
+    }
+
+    event := &Event{
+        SpecVersion:     headers["ce_specversion"],
+        Id:              headers["ce_id"],
+        Source:          headers["ce_source"],
+        Type:            headers["ce_type"],
+        Subject:         headers["ce_subject"],
+        DataContentType: headers["content-type"],
+        DataSchema:      headers["ce_dataschema"],
+        Data:            message.Value,
+    }
+    if value := headers["ce_time"]; value != "" {
+        var err error
+        if event.Time, err = time.Parse(time.RFC3339Nano, value); err != nil {
+            return nil, fmt.Errorf("invalid ce_time: %w", err)
+        }
+    }
+    return event, event.validate()
+}
+
+// ParseStructured reads an event in the structured JSON format.
+func ParseStructured(value []byte) (*Event, error) {
+    var event Event
+    if err := json.Unmarshal(value, &event); err != nil {
+        return nil, err
+    }
+    if event.SpecVersion == "" {
+        return nil, ErrNotCloudEvent
+    }
+    return &event, event.validate()
+}
+
+// validate checks that the required context attributes are present.
+func (e *Event) validate() error {
+    if e.SpecVersion != SpecVersion {
+        return fmt.Errorf("unsupported CloudEvents specversion %q", e.SpecVersion)
+    }
+    if e.Id == "" || e.Source == "" || e.Type == "" {
+        return fmt.Errorf("%w: id, source and type are required", ErrNotCloudEvent)
+    }
+    return nil
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+    "context"
+    "testing"
+    "testing/fstest"
+
+    "github.com/stretchr/testify/suite"
+)
+
+type testCreated struct {
+    Name string `json:"name"`
+}
+
+func (testCreated) EventType() string { return "test_created" }
+
+type CloudEventsSuite struct {
+    suite.Suite
+    registry *SchemaRegistry
+}
+
+func TestCloudEventsSuite(t *testing.T) {
+    suite.Run(t, new(CloudEventsSuite))
+}
+
+func (suite *CloudEventsSuite) SetupTest() {
+    var err error
+    suite.registry, err = NewSchemaRegistry(fstest.MapFS{
+        "name.json": {Data: []byte(`{"type": "string", "minLength": 1}`)},
+        "test_created.v1.json": {Data: []byte(`{
+            "type": "object",
+            "required": ["name"],
+            "properties": {"name": {"$ref": "name.json"}}
+        }`)},
+    })
+    suite.Require().NoError(err)
+}
+
+func (suite *CloudEventsSuite) Test_Message_RoundTripsInBothModes() {
+    event, err := NewEvent("/test", "agg1", testCreated{Name: "first"})
+    suite.Require().NoError(err)
+    suite.Equal("urn:wac-api:events:test_created:v1", event.DataSchema)
+
+    for _, mode := range []ContentMode{BinaryMode, StructuredMode} {
+        message, err := event.Message(mode, "agg1")
+        suite.Require().NoError(err)
+        suite.Equal("agg1", string(message.Key))
+
+        parsed, err := ParseMessage(message)
+        suite.Require().NoError(err)
+        suite.Equal(event.Id, parsed.Id)
+        suite.Equal(event.Type, parsed.Type)
+        suite.Equal(event.Subject, parsed.Subject)
+        suite.True(event.Time.Equal(parsed.Time))
+
+        var data testCreated
+        suite.Require().NoError(parsed.DecodeData(&data))
+        suite.Equal("first", data.Name)
+    }
+}
+
+func (suite *CloudEventsSuite) Test_Message_BinaryModeUsesHeaders() {
+    event, err := NewEvent("/test", "agg1", testCreated{Name: "first"})
+    suite.Require().NoError(err)
+
+    message, err := event.Message(BinaryMode, "agg1")
+    suite.Require().NoError(err)
+    headers := map[string]string{}
+    for _, header := range message.Headers {
+        headers[header.Key] = string(header.Value)
+    }
+    suite.Equal("1.0", headers["ce_specversion"])
+    suite.Equal("test_created", headers["ce_type"])
+    suite.Equal("application/json", headers["content-type"])
+    suite.JSONEq(`{"name":"first"}`, string(message.Value))
+}
+
+func (suite *CloudEventsSuite) Test_ParseMessage_RejectsPlainMessages() {
+    event, err := NewEvent("/test", "agg1", testCreated{Name: "first"})
+    suite.Require().NoError(err)
+    message, err := event.Message(BinaryMode, "agg1")
+    suite.Require().NoError(err)
+    message.Headers = nil
+
+    _, err = ParseMessage(message)
+    suite.ErrorIs(err, ErrNotCloudEvent)
+}
+
+func (suite *CloudEventsSuite) Test_SchemaRegistry_ValidatesData() {
+    event, err := NewEvent("/test", "agg1", testCreated{Name: "first"})
+    suite.Require().NoError(err)
+    suite.NoError(suite.registry.Validate(event))
+
+    event, err = NewEvent("/test", "agg1", testCreated{})
+    suite.Require().NoError(err)
+    suite.ErrorIs(suite.registry.Validate(event), ErrInvalidEvent)
+    published := NewMemoryPublisher()
+    suite.ErrorIs(NewValidatingPublisher(suite.registry, published).Publish(context.Background(), event), ErrInvalidEvent)
+    suite.Empty(published.Events())
+
+    event.DataSchema = SchemaURI("test_created", 2)
+    suite.ErrorIs(suite.registry.Validate(event), ErrUnknownSchema)
+}
@@ -0,0 +1,314 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// HandlerFunc handles one event. Events are delivered at least once, so handlers must be idempotent.
+type HandlerFunc func(ctx context.Context, event *Event) error
+
+// Router dispatches events to the handlers registered for their type.
+type Router struct {
+    handlers map[string][]HandlerFunc
+}
+
+// NewRouter returns a router without handlers.
+func NewRouter() *Router {
+    return &Router{handlers: map[string][]HandlerFunc{}}
+}
+
+// HandleFunc registers handler for events of the given type.
+func (r *Router) HandleFunc(eventType string, handler HandlerFunc) {
+    r.handlers[eventType] = append(r.handlers[eventType], handler)
+}
+
+// Handle registers a handler for the event type of T, which receives the decoded event data.
+// Data that does not decode into T is a permanent failure.
+func Handle[T Payload](r *Router, handler func(ctx context.Context, event *Event, data T) error) {
+    var zero T
+    r.HandleFunc(zero.EventType(), func(ctx context.Context, event *Event) error {
+        var data T
+        if err := event.DecodeData(&data); err != nil {
+            return Permanent(fmt.Errorf("decoding %s data: %w", event.Type, err))
+        }
+        return handler(ctx, event, data)
+    })
+}
+
+// Dispatch runs the handlers registered for the type of event in registration order and
+// returns the first error. Events without handlers are ignored.
+func (r *Router) Dispatch(ctx context.Context, event *Event) error {
+    for _, handler := range r.handlers[event.Type] {
+        if err := handler(ctx, event); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// permanentError marks failures that retrying cannot fix.
+type permanentError struct {
+    err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the Consumer sends the event to the dead-letter topic without retrying.
+func Permanent(err error) error {
+    return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+    var permanent *permanentError
+    return errors.As(err, &permanent)
+}
+
+// Headers added to the messages on the dead-letter topic.
+const (
+    DeadLetterTopicHeader     = "dlq_topic"
+    DeadLetterPartitionHeader = "dlq_partition"
+    DeadLetterOffsetHeader    = "dlq_offset"
+    DeadLetterAttemptsHeader  = "dlq_attempts"
+    DeadLetterErrorHeader     = "dlq_error"
+)
+
+// ConsumerConfig configures a Consumer. Zero values select the defaults, which are read
+// from the AMBULANCE_API_KAFKA_* environment variables where noted.
+type ConsumerConfig struct {
+    // KafkaConfig holds the brokers, topic and connection security, see NewKafkaPublisher.
+    KafkaConfig
+
+    // GroupId is the consumer group, AMBULANCE_API_KAFKA_GROUP_ID or ambulance-api by default.
+    GroupId string
+
+    // DeadLetterTopic receives the messages that could not be handled,
+    // AMBULANCE_API_KAFKA_DEAD_LETTER_TOPIC or the topic name with a .dlq suffix by default.
+    DeadLetterTopic string
+
+    // MaxAttempts is the number of times a message is handled before it is dead-lettered,
+    // AMBULANCE_API_KAFKA_MAX_ATTEMPTS or 5 by default.
+    MaxAttempts int
+
+    // InitialBackoff is the delay before the first retry, 500ms by default. It doubles
+    // with every retry up to MaxBackoff, 30s by default.
+    InitialBackoff time.Duration
+    MaxBackoff     time.Duration
+}
+
+// withDefaults fills the empty fields from the environment.
+func (config ConsumerConfig) withDefaults() (ConsumerConfig, error) {
+    var err error
+    if config.KafkaConfig, err = config.KafkaConfig.withDefaults(); err != nil {
+        return config, err
+    }
+    if config.GroupId == "" {
+        config.GroupId = os.Getenv("AMBULANCE_API_KAFKA_GROUP_ID")
+    }
+    if config.GroupId == "" {
+        config.GroupId = "ambulance-api"
+    }
+    if config.DeadLetterTopic == "" {
+        config.DeadLetterTopic = os.Getenv("AMBULANCE_API_KAFKA_DEAD_LETTER_TOPIC")
+    }
+    if config.DeadLetterTopic == "" {
+        config.DeadLetterTopic = config.Topic + ".dlq"
+    }
+    if value := os.Getenv("AMBULANCE_API_KAFKA_MAX_ATTEMPTS"); config.MaxAttempts == 0 && value != "" {
+        if config.MaxAttempts, err = strconv.Atoi(value); err != nil {
+            return config, fmt.Errorf("invalid AMBULANCE_API_KAFKA_MAX_ATTEMPTS value %q: %w", value, err)
+        }
+    }
+    return config.withRetryDefaults(), nil
+}
+
+func (config ConsumerConfig) withRetryDefaults() ConsumerConfig {
+    if config.MaxAttempts <= 0 {
+        config.MaxAttempts = 5
+    }
+    if config.InitialBackoff <= 0 {
+        config.InitialBackoff = 500 * time.Millisecond
+    }
+    if config.MaxBackoff <= 0 {
+        config.MaxBackoff = 30 * time.Second
+    }
+    return config
+}
+
+// MessageReader is the part of kafka.Reader used by the Consumer.
+type MessageReader interface {
+    FetchMessage(ctx context.Context) (kafka.Message, error)
+    CommitMessages(ctx context.Context, messages ...kafka.Message) error
+    Close() error
+}
+
+// MessageWriter is the part of kafka.Writer used by the Consumer.
+type MessageWriter interface {
+    WriteMessages(ctx context.Context, messages ...kafka.Message) error
+    Close() error
+}
+
+// Consumer reads CloudEvents of a consumer group and dispatches them with a Router.
+// The offset of a message is committed only after it was handled or dead-lettered,
+// so delivery is at-least-once.
+type Consumer struct {
+    reader      MessageReader
+    deadLetters MessageWriter
+    router      *Router
+    config      ConsumerConfig
+}
+
+// NewConsumer creates a consumer from a reader of the consumer group and a writer of
+// the dead-letter topic. Only the retry settings of config are used.
+func NewConsumer(reader MessageReader, deadLetters MessageWriter, router *Router, config ConsumerConfig) *Consumer {
+    return &Consumer{reader: reader, deadLetters: deadLetters, router: router, config: config.withRetryDefaults()}
+}
+
+// NewKafkaConsumer creates a consumer of the configured topic and consumer group, see ConsumerConfig.
+// A new group starts with the oldest retained message.
+func NewKafkaConsumer(config ConsumerConfig, router *Router) (*Consumer, error) {
+    config, err := config.withDefaults()
+    if err != nil {
+        return nil, err
+    }
+    tlsConfig, err := config.tlsConfig()
+    if err != nil {
+        return nil, err
+    }
+    mechanism, err := config.saslMechanism()
+    if err != nil {
+        return nil, err
+    }
+    transport, err := config.transport()
+    if err != nil {
+        return nil, err
+    }
+
+    log.Printf("Consuming Kafka topic %v as group %v, dead letters go to %v", config.Topic, config.GroupId, config.DeadLetterTopic)
+    reader := kafka.NewReader(kafka.ReaderConfig{
+        Brokers:     config.Brokers,
+        GroupID:     config.GroupId,
+        Topic:       config.Topic,
+        StartOffset: kafka.FirstOffset,
+        Dialer: &kafka.Dialer{
+            Timeout:       10 * time.Second,
+            DualStack:     true,
+            TLS:           tlsConfig,
+            SASLMechanism: mechanism,
+        },
+    })
+    deadLetters := &kafka.Writer{
+        Addr:                   kafka.TCP(config.Brokers...),
+        Topic:                  config.DeadLetterTopic,
+        Balancer:               &kafka.Hash{},
+        RequiredAcks:           kafka.RequireAll,
+        AllowAutoTopicCreation: true,
+        WriteTimeout:           config.WriteTimeout,
+        Transport:              transport,
+    }
+    return NewConsumer(reader, deadLetters, router, config), nil
+}
+
+// Run handles messages until ctx is done, which is not reported as an error.
+// Other errors stop the consumer without committing the current message.
+func (c *Consumer) Run(ctx context.Context) error {
+    for {
+        message, err := c.reader.FetchMessage(ctx)
+        if err != nil {
+            return c.stopped(ctx, err)
+        }
+        if err := c.handle(ctx, message); err != nil {
+            return c.stopped(ctx, err)
+        }
+        if err := c.reader.CommitMessages(ctx, message); err != nil {
+            return c.stopped(ctx, err)
+        }
+    }
+}
+
+// Close closes the reader and the dead-letter writer.
+func (c *Consumer) Close() error {
+    return errors.Join(c.reader.Close(), c.deadLetters.Close())
+}
+
+func (c *Consumer) stopped(ctx context.Context, err error) error {
+    if ctx.Err() != nil {
+        return nil
+    }
+    return err
+}
+
+// handle dispatches one message, retrying with backoff, and dead-letters it when
+// it is not a CloudEvent, fails permanently or runs out of attempts.
+func (c *Consumer) handle(ctx context.Context, message kafka.Message) error {
+    event, err := ParseMessage(message)
+    if err != nil {
+        return c.deadLetter(ctx, message, err, 0)
+    }
+
+    delay := c.config.InitialBackoff
+    for attempt := 1; ; attempt++ {
+        err := c.router.Dispatch(ctx, event)
+        if err == nil {
+            return nil
+        }
+        if IsPermanent(err) || attempt >= c.config.MaxAttempts {
+            return c.deadLetter(ctx, message, err, attempt)
+        }
+        log.Printf("⚠️ handling %s event %s failed (attempt %d): %v", event.Type, event.Id, attempt, err)
+        if err := sleep(ctx, delay); err != nil {
+            return err
+        }
+        delay = min(delay*2, c.config.MaxBackoff)
+    }
+}
+
+// deadLetter copies message to the dead-letter topic together with the reason,
+// retrying until the write succeeds or ctx is done.
+func (c *Consumer) deadLetter(ctx context.Context, message kafka.Message, cause error, attempts int) error {
+    log.Printf("⚠️ dead-lettering message %s/%d/%d: %v", message.Topic, message.Partition, message.Offset, cause)
+    headers := append([]kafka.Header(nil), message.Headers...)
+    for _, header := range [][2]string{
+        {DeadLetterTopicHeader, message.Topic},
+        {DeadLetterPartitionHeader, strconv.Itoa(message.Partition)},
+        {DeadLetterOffsetHeader, strconv.FormatInt(message.Offset, 10)},
+        {DeadLetterAttemptsHeader, strconv.Itoa(attempts)},
+        {DeadLetterErrorHeader, cause.Error()},
+    } {
+        headers = append(headers, kafka.Header{Key: header[0], Value: []byte(header[1])})
+    }
+    deadLetter := kafka.Message{Key: message.Key, Value: message.Value, Headers: headers}
+
+    delay := c.config.InitialBackoff
+    for {
+        err := c.deadLetters.WriteMessages(ctx, deadLetter)
+        if err == nil {
+            return nil
+        }
+        log.Printf("⚠️ writing to the dead-letter topic failed: %v", err)
+        if err := sleep(ctx, delay); err != nil {
+            return err
+        }
+        delay = min(delay*2, c.config.MaxBackoff)
+    }
+}
+
+// sleep waits for delay or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-time.After(delay):
+        return nil
+    }
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "io"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+    "github.com/stretchr/testify/suite"
+)
+
+// fakeTopic serves queued messages to a Consumer and records commits and writes.
+type fakeTopic struct {
+    messages  []kafka.Message
+    committed []int64
+    written   []kafka.Message
+    writeErrs int
+}
+
+func (t *fakeTopic) FetchMessage(ctx context.Context) (kafka.Message, error) {
+    if len(t.messages) == 0 {
+        return kafka.Message{}, io.EOF
+    }
+    message := t.messages[0]
+    t.messages = t.messages[1:]
+    return message, nil
+}
+
+func (t *fakeTopic) CommitMessages(_ context.Context, messages ...kafka.Message) error {
+    for _, message := range messages {
+        t.committed = append(t.committed, message.Offset)
+    }
+    return nil
+}
+
+func (t *fakeTopic) WriteMessages(_ context.Context, messages ...kafka.Message) error {
+    if t.writeErrs > 0 {
+        t.writeErrs--
+        return errors.New("broker unavailable")
+    }
+    t.written = append(t.written, messages...)
+    return nil
+}
+
+func (t *fakeTopic) Close() error { return nil }
+
+type ConsumerSuite struct {
+    suite.Suite
+    topic    *fakeTopic
+    router   *Router
+    handled  []string
+    failures int
+}
+
+func TestConsumerSuite(t *testing.T) {
+    suite.Run(t, new(ConsumerSuite))
+}
+
+func (suite *ConsumerSuite) SetupTest() {
+    suite.topic = &fakeTopic{}
+    suite.router = NewRouter()
+    suite.handled = nil
+    suite.failures = 0
+    Handle(suite.router, func(_ context.Context, _ *Event, data testCreated) error {
+        if data.Name == "poison" {
+            return Permanent(errors.New("cannot handle poison"))
+        }
+        if suite.failures > 0 {
+            suite.failures--
+            return errors.New("database unavailable")
+        }
+        suite.handled = append(suite.handled, data.Name)
+        return nil
+    })
+}
+
+func (suite *ConsumerSuite) enqueue(names ...string) {
+    for _, name := range names {
+        event, err := NewEvent("/test", name, testCreated{Name: name})
+        suite.Require().NoError(err)
+        message, err := event.Message(BinaryMode, name)
+        suite.Require().NoError(err)
+        message.Topic = "hospital-events"
+        message.Offset = int64(len(suite.topic.committed) + len(suite.topic.messages))
+        suite.topic.messages = append(suite.topic.messages, message)
+    }
+}
+
+func (suite *ConsumerSuite) consumer() *Consumer {
+    return NewConsumer(suite.topic, suite.topic, suite.router, ConsumerConfig{
+        MaxAttempts:    3,
+        InitialBackoff: time.Millisecond,
+        MaxBackoff:     time.Millisecond,
+    })
+}
+
+func (suite *ConsumerSuite) Test_Run_HandlesAndCommitsInOrder() {
+    suite.enqueue("first", "second")
+
+    err := suite.consumer().Run(context.Background())
+    suite.ErrorIs(err, io.EOF)
+    suite.Equal([]string{"first", "second"}, suite.handled)
+    suite.Equal([]int64{0, 1}, suite.topic.committed)
+    suite.Empty(suite.topic.written)
+}
+
+func (suite *ConsumerSuite) Test_Run_RetriesTransientFailures() {
+    suite.enqueue("first")
+    suite.failures = 2
+
+    suite.ErrorIs(suite.consumer().Run(context.Background()), io.EOF)
+    suite.Equal([]string{"first"}, suite.handled)
+    suite.Equal([]int64{0}, suite.topic.committed)
+    suite.Empty(suite.topic.written)
+}
+
+func (suite *ConsumerSuite) Test_Run_DeadLettersAfterMaxAttempts() {
+    suite.enqueue("first", "second")
+    suite.failures = 3
+    suite.topic.writeErrs = 1
+
+    suite.ErrorIs(suite.consumer().Run(context.Background()), io.EOF)
+    suite.Equal([]string{"second"}, suite.handled)
+    suite.Equal([]int64{0, 1}, suite.topic.committed)
+    suite.Require().Len(suite.topic.written, 1)
+    headers := headerMap(suite.topic.written[0])
+    suite.Equal("hospital-events", headers[DeadLetterTopicHeader])
+    suite.Equal("3", headers[DeadLetterAttemptsHeader])
+    suite.Equal("database unavailable", headers[DeadLetterErrorHeader])
+    suite.Equal("test_created", headers["ce_type"])
+}
+
+func (suite *ConsumerSuite) Test_Run_DeadLettersPoisonMessagesWithoutRetry() {
+    suite.enqueue("poison")
+    suite.topic.messages = append(suite.topic.messages, kafka.Message{Offset: 1, Value: []byte("not an event")})
+
+    suite.ErrorIs(suite.consumer().Run(context.Background()), io.EOF)
+    suite.Empty(suite.handled)
+    suite.Equal([]int64{0, 1}, suite.topic.committed)
+    suite.Require().Len(suite.topic.written, 2)
+    suite.Equal("1", headerMap(suite.topic.written[0])[DeadLetterAttemptsHeader])
+    suite.Equal("0", headerMap(suite.topic.written[1])[DeadLetterAttemptsHeader])
+}
+
+func headerMap(message kafka.Message) map[string]string {
+    headers := map[string]string{}
+    for _, header := range message.Headers {
+        headers[header.Key] = string(header.Value)
+    }
+    return headers
+}
+
+func (suite *ConsumerSuite) Test_Run_StopsWithoutCommitWhenCanceled() {
+    suite.enqueue("first")
+    suite.failures = 1
+    ctx, cancel := context.WithCancel(context.Background())
+    consumer := NewConsumer(suite.topic, suite.topic, suite.router, ConsumerConfig{InitialBackoff: time.Hour})
+    cancel()
+
+    suite.NoError(consumer.Run(ctx))
+    suite.Empty(suite.topic.committed)
+}
@@ -0,0 +1,270 @@
+package kafka
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+    "github.com/segmentio/kafka-go/sasl"
+    "github.com/segmentio/kafka-go/sasl/plain"
+    "github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// KafkaConfig configures a KafkaPublisher. Zero values are read from the
+// AMBULANCE_API_KAFKA_* environment variables, see NewKafkaPublisher.
+type KafkaConfig struct {
+    Brokers []string
+    Topic   string
+
+    // ContentMode selects how events are mapped onto Kafka messages. BinaryMode, the zero
+    // value, is overridden by AMBULANCE_API_KAFKA_CONTENT_MODE.
+    ContentMode ContentMode
+
+    // TLS enables TLS, it is implied by TLSCAFile and TLSSkipVerify.
+    TLS           bool
+    TLSCAFile     string
+    TLSSkipVerify bool
+
+    // SASLMechanism is one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, empty disables SASL.
+    SASLMechanism string
+    SASLUsername  string
+    SASLPassword  string
+
+    // WriteTimeout limits a single publish, 10s by default.
+    WriteTimeout time.Duration
+}
+
+// KafkaPublisher publishes events to one Kafka topic. Messages are partitioned by
+// the hash of their key, so the events of one aggregate keep their order.
+// The messages of concurrent publishes and of PublishAsync are written in batches by an
+// AsyncProducer.
+type KafkaPublisher struct {
+    producer     *AsyncProducer
+    mode         ContentMode
+    writeTimeout time.Duration
+}
+
+// publisherBatchTimeout is how long a message waits for the messages of concurrent publishes.
+// It is short since every Publish waits for the write.
+const publisherBatchTimeout = 5 * time.Millisecond
+
+// NewKafkaPublisher creates a publisher for the given configuration. Empty fields are
+// filled from the environment:
+//
+//   - AMBULANCE_API_KAFKA_BROKERS, comma separated, localhost:9092 by default
+//   - AMBULANCE_API_KAFKA_TOPIC, hospital-events by default
+//   - AMBULANCE_API_KAFKA_CONTENT_MODE, binary or structured
+//   - AMBULANCE_API_KAFKA_TLS, AMBULANCE_API_KAFKA_TLS_CA_FILE, AMBULANCE_API_KAFKA_TLS_SKIP_VERIFY
+//   - AMBULANCE_API_KAFKA_SASL_MECHANISM, AMBULANCE_API_KAFKA_SASL_USERNAME, AMBULANCE_API_KAFKA_SASL_PASSWORD
+//
+// No connection is made until the first event is published.
+func NewKafkaPublisher(config KafkaConfig) (*KafkaPublisher, error) {
+    config, err := config.withDefaults()
+    if err != nil {
+        return nil, err
+    }
+    transport, err := config.transport()
+    if err != nil {
+        return nil, err
+    }
+
+    log.Printf("Publishing events to Kafka topic %v at %v", config.Topic, strings.Join(config.Brokers, ","))
+    writer := &kafka.Writer{
+        Addr:         kafka.TCP(config.Brokers...),
+        Topic:        config.Topic,
+        Balancer:     &kafka.Hash{},
+        RequiredAcks: kafka.RequireAll,
+        // the producer collects the batches, the writer sends them right away
+        BatchTimeout: time.Millisecond,
+        WriteTimeout: config.WriteTimeout,
+        Transport:    transport,
+    }
+    return newKafkaPublisher(writer, config.ContentMode, config.WriteTimeout), nil
+}
+
+func newKafkaPublisher(writer MessageWriter, mode ContentMode, writeTimeout time.Duration) *KafkaPublisher {
+    return &KafkaPublisher{
+        producer: NewAsyncProducer(writer, AsyncConfig{
+            BatchTimeout: publisherBatchTimeout,
+            WriteTimeout: writeTimeout,
+            OnDelivery: func(message kafka.Message, err error) {
+                message.WriterData.(func(error))(err)
+            },
+        }),
+        mode:         mode,
+        writeTimeout: writeTimeout,
+    }
+}
+
+// Publish returns once the broker acknowledged the event.
+func (p *KafkaPublisher) Publish(ctx context.Context, event *Event) error {
+    // buffered so that the delivery callback never blocks
+    delivered := make(chan error, 1)
+    if err := p.PublishAsync(ctx, event, func(err error) { delivered <- err }); err != nil {
+        return err
+    }
+    select {
+    case err := <-delivered:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// PublishAsync queues the event, done is told whether the broker acknowledged it.
+func (p *KafkaPublisher) PublishAsync(ctx context.Context, event *Event, done func(err error)) error {
+    message, err := event.Message(p.mode, event.Subject)
+    if err != nil {
+        return err
+    }
+    message.WriterData = done
+    return p.producer.Send(ctx, message)
+}
+
+// Shutdown writes the queued messages until ctx is done and closes the publisher.
+func (p *KafkaPublisher) Shutdown(ctx context.Context) error {
+    return p.producer.Close(ctx)
+}
+
+// Close is Shutdown limited by the write timeout.
+func (p *KafkaPublisher) Close() error {
+    ctx, cancel := context.WithTimeout(context.Background(), p.writeTimeout)
+    defer cancel()
+    return p.Shutdown(ctx)
+}
+
+// withDefaults fills the empty fields from the environment.
+func (config KafkaConfig) withDefaults() (KafkaConfig, error) {
+    enviro := func(name string, defaultValue string) string {
+        if value, ok := os.LookupEnv(name); ok {
+            return value
+        }
+        return defaultValue
+    }
+    enviroBool := func(name string) (bool, error) {
+        value := enviro(name, "")
+        if value == "" {
+            return false, nil
+        }
+        enabled, err := strconv.ParseBool(value)
+        if err != nil {
+            return false, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+        }
+        return enabled, nil
+    }
+
+    if len(config.Brokers) == 0 {
+        for _, broker := range strings.Split(enviro("AMBULANCE_API_KAFKA_BROKERS", "localhost:9092"), ",") {
+            if broker = strings.TrimSpace(broker); broker != "" {
+                config.Brokers = append(config.Brokers, broker)
+            }
+        }
+        if len(config.Brokers) == 0 {
+            return config, fmt.Errorf("AMBULANCE_API_KAFKA_BROKERS lists no brokers")
+        }
+    }
+
+    if config.Topic == "" {
+        config.Topic = enviro("AMBULANCE_API_KAFKA_TOPIC", "hospital-events")
+    }
+
+    if config.ContentMode == BinaryMode {
+        mode, err := ParseContentMode(enviro("AMBULANCE_API_KAFKA_CONTENT_MODE", ""))
+        if err != nil {
+            return config, err
+        }
+        config.ContentMode = mode
+    }
+
+    if !config.TLS {
+        enabled, err := enviroBool("AMBULANCE_API_KAFKA_TLS")
+        if err != nil {
+            return config, err
+        }
+        config.TLS = enabled
+    }
+    if config.TLSCAFile == "" {
+        config.TLSCAFile = enviro("AMBULANCE_API_KAFKA_TLS_CA_FILE", "")
+    }
+    if !config.TLSSkipVerify {
+        skip, err := enviroBool("AMBULANCE_API_KAFKA_TLS_SKIP_VERIFY")
+        if err != nil {
+            return config, err
+        }
+        config.TLSSkipVerify = skip
+    }
+
+    if config.SASLMechanism == "" {
+        config.SASLMechanism = enviro("AMBULANCE_API_KAFKA_SASL_MECHANISM", "")
+    }
+    if config.SASLUsername == "" {
+        config.SASLUsername = enviro("AMBULANCE_API_KAFKA_SASL_USERNAME", "")
+    }
+    if config.SASLPassword == "" {
+        config.SASLPassword = enviro("AMBULANCE_API_KAFKA_SASL_PASSWORD", "")
+    }
+
+    if config.WriteTimeout <= 0 {
+        config.WriteTimeout = 10 * time.Second
+    }
+    return config, nil
+}
+
+// transport builds the TLS and SASL settings, it returns nil when neither is configured.
+func (config KafkaConfig) transport() (kafka.RoundTripper, error) {
+    tlsConfig, err := config.tlsConfig()
+    if err != nil {
+        return nil, err
+    }
+    mechanism, err := config.saslMechanism()
+    if err != nil {
+        return nil, err
+    }
+    if tlsConfig == nil && mechanism == nil {
+        return nil, nil
+    }
+    return &kafka.Transport{TLS: tlsConfig, SASL: mechanism}, nil
+}
+
+func (config KafkaConfig) tlsConfig() (*tls.Config, error) {
+    if !config.TLS && config.TLSCAFile == "" && !config.TLSSkipVerify {
+        return nil, nil
+    }
+    tlsConfig := &tls.Config{
+        MinVersion:         tls.VersionTLS12,
+        InsecureSkipVerify: config.TLSSkipVerify,
+    }
+    if config.TLSCAFile != "" {
+        pem, err := os.ReadFile(config.TLSCAFile)
+        if err != nil {
+            return nil, fmt.Errorf("reading Kafka CA file: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("no certificates found in %s", config.TLSCAFile)
+        }
+        tlsConfig.RootCAs = pool
+    }
+    return tlsConfig, nil
+}
+
+func (config KafkaConfig) saslMechanism() (sasl.Mechanism, error) {
+    switch strings.ToUpper(config.SASLMechanism) {
+    case "":
+        return nil, nil
+    case "PLAIN":
+        return plain.Mechanism{Username: config.SASLUsername, Password: config.SASLPassword}, nil
+    case "SCRAM-SHA-256":
+        return scram.Mechanism(scram.SHA256, config.SASLUsername, config.SASLPassword)
+    case "SCRAM-SHA-512":
+        return scram.Mechanism(scram.SHA512, config.SASLUsername, config.SASLPassword)
+    }
+    return nil, fmt.Errorf("unsupported SASL mechanism %q", config.SASLMechanism)
+}
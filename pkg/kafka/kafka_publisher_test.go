@@ -0,0 +1,119 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+    "github.com/stretchr/testify/suite"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) WriteMessages(context.Context, ...kafka.Message) error {
+    return errors.New("broker unavailable")
+}
+
+func (failingWriter) Close() error { return nil }
+
+type KafkaPublisherSuite struct {
+    suite.Suite
+    writer *gatedWriter
+}
+
+func TestKafkaPublisherSuite(t *testing.T) {
+    suite.Run(t, new(KafkaPublisherSuite))
+}
+
+func (suite *KafkaPublisherSuite) SetupTest() {
+    suite.writer = &gatedWriter{gate: make(chan struct{}), writing: make(chan struct{}, 10)}
+}
+
+func (suite *KafkaPublisherSuite) publish(publisher *KafkaPublisher, name string) <-chan error {
+    event, err := NewEvent("/test", "agg1", testCreated{Name: name})
+    suite.Require().NoError(err)
+    result := make(chan error, 1)
+    go func() { result <- publisher.Publish(context.Background(), event) }()
+    return result
+}
+
+func (suite *KafkaPublisherSuite) Test_Publish_ReturnsOnceWritten() {
+    publisher := newKafkaPublisher(suite.writer, BinaryMode, time.Second)
+    defer publisher.Close()
+
+    result := suite.publish(publisher, "first")
+    <-suite.writer.writing
+    select {
+    case <-result:
+        suite.Fail("Publish returned before the write")
+    case <-time.After(10 * time.Millisecond):
+    }
+
+    close(suite.writer.gate)
+    suite.NoError(<-result)
+    suite.Equal([][]string{{`{"name":"first"}`}}, suite.writer.written())
+}
+
+func (suite *KafkaPublisherSuite) Test_Publish_BatchesConcurrentPublishes() {
+    publisher := newKafkaPublisher(suite.writer, BinaryMode, time.Second)
+    defer publisher.Close()
+
+    first := suite.publish(publisher, "first")
+    <-suite.writer.writing
+    var results []<-chan error
+    for _, name := range []string{"second", "third", "fourth"} {
+        results = append(results, suite.publish(publisher, name))
+    }
+    suite.Eventually(func() bool { return publisher.producer.Pending() == 3 }, time.Second, time.Millisecond)
+
+    var writes sync.WaitGroup
+    writes.Add(1)
+    go func() {
+        defer writes.Done()
+        for range suite.writer.writing {
+        }
+    }()
+    close(suite.writer.gate)
+    suite.NoError(<-first)
+    for _, result := range results {
+        suite.NoError(<-result)
+    }
+    suite.Require().NoError(publisher.Close())
+    close(suite.writer.writing)
+    writes.Wait()
+
+    written := suite.writer.written()
+    suite.Require().Len(written, 2)
+    suite.Len(written[1], 3)
+}
+
+func (suite *KafkaPublisherSuite) Test_Publish_ReportsWriteError() {
+    publisher := newKafkaPublisher(failingWriter{}, BinaryMode, time.Second)
+    defer publisher.Close()
+
+    suite.EqualError(<-suite.publish(publisher, "first"), "broker unavailable")
+}
+
+func (suite *KafkaPublisherSuite) Test_Relay_WritesPageInOneBatch() {
+    writer := &gatedWriter{}
+    publisher := newKafkaPublisher(writer, BinaryMode, time.Second)
+    defer publisher.Close()
+    outbox := memoryOutbox{}
+    for _, name := range []string{"first", "second", "third"} {
+        event, err := NewEvent("/test", "agg1", testCreated{Name: name})
+        suite.Require().NoError(err)
+        suite.Require().NoError(NewOutboxPublisher(outbox).Publish(context.Background(), event))
+    }
+
+    published, err := NewRelay(outbox, publisher, RelayConfig{}).Flush(context.Background())
+    suite.Require().NoError(err)
+    suite.Equal(3, published)
+    suite.Equal([][]string{{`{"name":"first"}`, `{"name":"second"}`, `{"name":"third"}`}}, writer.written())
+    for _, message := range outbox {
+        suite.Equal(OutboxSent, message.Status)
+    }
+}
@@ -0,0 +1,374 @@
+package kafka
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "log"
+    "net"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Outbox message states.
+const (
+    OutboxPending = "pending"
+    OutboxSent    = "sent"
+    // OutboxRejected marks messages that are not CloudEvents or that the broker kept refusing,
+    // they are not published.
+    OutboxRejected = "rejected"
+)
+
+// OutboxMessage is a Kafka message stored in the outbox collection in the same
+// transaction as the document change it describes. The Relay publishes it later.
+type OutboxMessage struct {
+    // Id is a time ordered UUID, so sorting by id yields the order of creation.
+    // For CloudEvents it is the event id.
+    Id string `json:"id"`
+
+    Key string `json:"key"`
+
+    // Value is the JSON encoded message payload, a CloudEvent in the structured content mode.
+    Value string `json:"value"`
+
+    Status string `json:"status"`
+
+    // Attempts counts the publishes that reached the broker.
+    Attempts int `json:"attempts"`
+
+    LastError string `json:"last_error,omitempty"`
+
+    CreatedAt time.Time `json:"created_at"`
+
+    SentAt *time.Time `json:"sent_at,omitempty"`
+}
+
+// NewOutboxMessage returns a pending outbox message for the given key and payload.
+func NewOutboxMessage(key string, value []byte) *OutboxMessage {
+    id, err := uuid.NewV7()
+    if err != nil {
+        // NewV7 only fails when the random source does
+        id = uuid.New()
+    }
+    return &OutboxMessage{
+        Id:        id.String(),
+        Key:       key,
+        Value:     string(value),
+        Status:    OutboxPending,
+        CreatedAt: time.Now().UTC(),
+    }
+}
+
+// outboxIdAt returns the smallest time ordered id of a message created at or after t.
+func outboxIdAt(t time.Time) string {
+    var id uuid.UUID
+    ms := uint64(t.UnixMilli())
+    for i := 0; i < 6; i++ {
+        id[i] = byte(ms >> (40 - 8*i))
+    }
+    id[6] = 0x70 // version 7
+    id[8] = 0x80 // RFC 4122 variant
+    return id.String()
+}
+
+// NewOutboxEvent returns a pending outbox message holding event in the structured
+// content mode, keyed by the event subject.
+func NewOutboxEvent(event *Event) (*OutboxMessage, error) {
+    value, err := json.Marshal(event)
+    if err != nil {
+        return nil, err
+    }
+    message := NewOutboxMessage(event.Subject, value)
+    message.Id = event.Id
+    return message, nil
+}
+
+// OutboxQuery selects outbox messages. The zero value selects all of them.
+type OutboxQuery struct {
+    // Statuses, if not empty, selects the messages in one of these states.
+    Statuses []string
+
+    // FromId, if set, selects the messages with this or a greater id.
+    FromId string
+
+    // AfterId, if set, selects the messages with a greater id.
+    AfterId string
+
+    // BeforeId, if set, selects the messages with a smaller id.
+    BeforeId string
+}
+
+// OutboxStore is the collection holding the outbox messages, kept in the same database
+// as the documents whose changes they describe.
+type OutboxStore interface {
+    // Insert stores a new message. It must join the transaction carried by ctx.
+    Insert(ctx context.Context, message *OutboxMessage) error
+
+    // Update replaces the stored message with the same id.
+    Update(ctx context.Context, message *OutboxMessage) error
+
+    // List returns up to limit messages selected by query, ordered by id.
+    List(ctx context.Context, query OutboxQuery, limit int) ([]OutboxMessage, error)
+
+    // Count returns the number of messages selected by query.
+    Count(ctx context.Context, query OutboxQuery) (int64, error)
+
+    // Delete removes the messages selected by query and returns their number.
+    Delete(ctx context.Context, query OutboxQuery) (int64, error)
+}
+
+// OutboxPublisher is the EventPublisher used by request handlers: it stores events in
+// the outbox collection instead of sending them. Publish with the context of the
+// transaction that changes the aggregate, so that the event is recorded if and only if
+// the change is committed; a Relay then forwards it to the broker.
+type OutboxPublisher struct {
+    outbox OutboxStore
+}
+
+// NewOutboxPublisher returns a publisher that stores events in the given outbox.
+func NewOutboxPublisher(outbox OutboxStore) *OutboxPublisher {
+    return &OutboxPublisher{outbox: outbox}
+}
+
+func (p *OutboxPublisher) Publish(ctx context.Context, event *Event) error {
+    message, err := NewOutboxEvent(event)
+    if err != nil {
+        return err
+    }
+    return p.outbox.Insert(ctx, message)
+}
+
+// Close does nothing, the outbox collection is owned by the caller.
+func (p *OutboxPublisher) Close() error { return nil }
+
+// RelayConfig tunes the outbox relay. Zero values select the defaults.
+type RelayConfig struct {
+    // Interval between polls of the outbox, 1s by default.
+    Interval time.Duration
+
+    // BatchSize is the maximum number of messages published per poll, 100 by default.
+    BatchSize int
+
+    // MaxBackoff caps the delay between retries after a failed publish, 1m by default.
+    MaxBackoff time.Duration
+
+    // MaxAttempts is how often the broker may refuse a message before it is marked rejected,
+    // so that it does not hold back the messages after it, 10 by default. Publishes failing
+    // because the broker cannot be reached are retried without limit.
+    MaxAttempts int
+
+    // Retention is how long sent messages are kept after they were created, e.g. to replay
+    // them, 7 days by default.
+    Retention time.Duration
+}
+
+// purgeInterval is how often Run deletes the sent messages past their retention.
+const purgeInterval = time.Hour
+
+// Relay publishes pending outbox messages in creation order and marks them sent.
+// A message is marked sent only after the broker acknowledged it, so delivery is
+// at-least-once: a crash between the two steps publishes the message again.
+// An AsyncPublisher is handed a whole page of messages at once, so that they are
+// written in batches.
+type Relay struct {
+    outbox    OutboxStore
+    publisher EventPublisher
+    config    RelayConfig
+}
+
+// NewRelay creates a relay that forwards the events of the given outbox to publisher.
+func NewRelay(outbox OutboxStore, publisher EventPublisher, config RelayConfig) *Relay {
+    if config.Interval <= 0 {
+        config.Interval = time.Second
+    }
+    if config.BatchSize <= 0 {
+        config.BatchSize = 100
+    }
+    if config.MaxBackoff <= 0 {
+        config.MaxBackoff = time.Minute
+    }
+    if config.MaxAttempts <= 0 {
+        config.MaxAttempts = 10
+    }
+    if config.Retention <= 0 {
+        config.Retention = 7 * 24 * time.Hour
+    }
+    return &Relay{outbox: outbox, publisher: publisher, config: config}
+}
+
+// Run polls the outbox until ctx is done. After a failed publish it waits with
+// exponential backoff before trying the same message again. Every purgeInterval it
+// purges the sent messages past their retention.
+func (r *Relay) Run(ctx context.Context) {
+    delay := r.config.Interval
+    var purged time.Time
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(delay):
+        }
+
+        if time.Since(purged) >= purgeInterval {
+            if _, err := r.Purge(ctx); err != nil && ctx.Err() == nil {
+                log.Printf("⚠️ outbox purge error: %v", err)
+            } else {
+                purged = time.Now()
+            }
+        }
+
+        _, err := r.Flush(ctx)
+        switch {
+        case err == nil:
+            delay = r.config.Interval
+        case ctx.Err() != nil:
+            return
+        default:
+            log.Printf("⚠️ outbox relay error: %v", err)
+            delay = min(delay*2, r.config.MaxBackoff)
+        }
+    }
+}
+
+// Flush publishes pending messages in creation order until none are left and returns
+// how many were published. It stops at the first failure so that later messages
+// for the same key are not published before an earlier one. Messages of the page that
+// were in flight when an earlier one failed are still marked sent, only then their order
+// can differ from the outbox.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+    pending := OutboxQuery{Statuses: []string{OutboxPending}}
+
+    published := 0
+    for {
+        messages, err := r.outbox.List(ctx, pending, r.config.BatchSize)
+        if err != nil {
+            return published, err
+        }
+        sent, complete, err := r.relayPage(ctx, messages)
+        published += sent
+        if err != nil {
+            return published, err
+        }
+        // an incomplete page without an error ended at a message rejected after its last attempt
+        if complete && len(messages) < r.config.BatchSize {
+            return published, nil
+        }
+    }
+}
+
+// relayPage publishes the messages of one page in order until a failure is known, waits
+// for their delivery and records the outcomes. It returns the number of sent messages,
+// whether all messages were published and the first error.
+func (r *Relay) relayPage(ctx context.Context, messages []OutboxMessage) (int, bool, error) {
+    // outcomes[i] is written by the delivery of message i, read after all were delivered
+    outcomes := make([]error, len(messages))
+    invalid := make([]error, len(messages))
+    var delivered sync.WaitGroup
+    var failed atomic.Bool
+
+    submitted := 0
+    for ; submitted < len(messages) && !failed.Load(); submitted++ {
+        i := submitted
+        event, err := ParseStructured([]byte(messages[i].Value))
+        if err != nil {
+            invalid[i] = err
+            continue
+        }
+        delivered.Add(1)
+        done := func(err error) {
+            if err != nil {
+                failed.Store(true)
+            }
+            outcomes[i] = err
+            delivered.Done()
+        }
+        if err := r.publish(ctx, event, done); err != nil {
+            done(err)
+        }
+    }
+    delivered.Wait()
+
+    published := 0
+    var firstErr error
+    for i := 0; i < submitted; i++ {
+        var sent bool
+        var err error
+        if invalid[i] != nil {
+            err = r.reject(ctx, &messages[i], invalid[i])
+        } else {
+            sent, err = r.record(ctx, &messages[i], outcomes[i])
+        }
+        if sent {
+            published++
+        }
+        if err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return published, submitted == len(messages) && !failed.Load(), firstErr
+}
+
+// publish hands event to the publisher, done is called with the outcome.
+func (r *Relay) publish(ctx context.Context, event *Event, done func(err error)) error {
+    if async, ok := r.publisher.(AsyncPublisher); ok {
+        return async.PublishAsync(ctx, event, done)
+    }
+    done(r.publisher.Publish(ctx, event))
+    return nil
+}
+
+// Purge deletes the sent messages created before the retention period and returns their number.
+func (r *Relay) Purge(ctx context.Context) (int64, error) {
+    return r.outbox.Delete(ctx, OutboxQuery{
+        Statuses: []string{OutboxSent},
+        BeforeId: outboxIdAt(time.Now().Add(-r.config.Retention)),
+    })
+}
+
+// reject marks a message that is not a CloudEvent rejected, so that it does not block the ones after it.
+func (r *Relay) reject(ctx context.Context, message *OutboxMessage, err error) error {
+    log.Printf("⚠️ rejecting outbox message %v: %v", message.Id, err)
+    message.Status = OutboxRejected
+    message.LastError = err.Error()
+    return r.outbox.Update(ctx, message)
+}
+
+// record stores the outcome of publishing a message in the outbox and reports whether it was sent.
+// Messages that the broker refused MaxAttempts times are marked rejected so that they do not
+// block the ones after them.
+func (r *Relay) record(ctx context.Context, message *OutboxMessage, err error) (bool, error) {
+    if err != nil {
+        message.LastError = err.Error()
+        if !unreachable(err) {
+            message.Attempts++
+        }
+        if message.Attempts >= r.config.MaxAttempts {
+            log.Printf("⚠️ rejecting outbox message %v refused %d times: %v", message.Id, message.Attempts, err)
+            message.Status = OutboxRejected
+            return false, r.outbox.Update(ctx, message)
+        }
+        if updateErr := r.outbox.Update(ctx, message); updateErr != nil {
+            return false, errors.Join(err, updateErr)
+        }
+        return false, err
+    }
+
+    message.Attempts++
+    sentAt := time.Now().UTC()
+    message.Status = OutboxSent
+    message.LastError = ""
+    message.SentAt = &sentAt
+    return true, r.outbox.Update(ctx, message)
+}
+
+// unreachable reports whether a publish failed because the broker could not be reached in
+// time, rather than the broker refusing the message.
+func unreachable(err error) bool {
+    var netErr net.Error
+    return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+        errors.Is(err, io.EOF) || errors.As(err, &netErr)
+}
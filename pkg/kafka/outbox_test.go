@@ -0,0 +1,212 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "slices"
+    "sort"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/suite"
+)
+
+// memoryOutbox is an OutboxStore keeping the messages in a map.
+type memoryOutbox map[string]OutboxMessage
+
+func (o memoryOutbox) Insert(_ context.Context, message *OutboxMessage) error {
+    if _, exists := o[message.Id]; exists {
+        return errors.New("duplicate outbox message " + message.Id)
+    }
+    o[message.Id] = *message
+    return nil
+}
+
+func (o memoryOutbox) Update(_ context.Context, message *OutboxMessage) error {
+    if _, exists := o[message.Id]; !exists {
+        return errors.New("unknown outbox message " + message.Id)
+    }
+    o[message.Id] = *message
+    return nil
+}
+
+func (o memoryOutbox) List(_ context.Context, query OutboxQuery, limit int) ([]OutboxMessage, error) {
+    messages := []OutboxMessage{}
+    for _, message := range o {
+        if (len(query.Statuses) == 0 || slices.Contains(query.Statuses, message.Status)) &&
+            message.Id >= query.FromId && (query.AfterId == "" || message.Id > query.AfterId) &&
+            (query.BeforeId == "" || message.Id < query.BeforeId) {
+            messages = append(messages, message)
+        }
+    }
+    sort.Slice(messages, func(i, j int) bool { return messages[i].Id < messages[j].Id })
+    return messages[:min(limit, len(messages))], nil
+}
+
+func (o memoryOutbox) Count(ctx context.Context, query OutboxQuery) (int64, error) {
+    messages, err := o.List(ctx, query, len(o))
+    return int64(len(messages)), err
+}
+
+func (o memoryOutbox) Delete(ctx context.Context, query OutboxQuery) (int64, error) {
+    messages, err := o.List(ctx, query, len(o))
+    for _, message := range messages {
+        delete(o, message.Id)
+    }
+    return int64(len(messages)), err
+}
+
+type RelaySuite struct {
+    suite.Suite
+    ctx       context.Context
+    outbox    memoryOutbox
+    published []string
+    failures  int
+    // failure is the error of a failing publish, a refusal by the broker by default
+    failure error
+}
+
+// Publish records the name of test_created events, failing while suite.failures is positive.
+func (suite *RelaySuite) Publish(_ context.Context, event *Event) error {
+    if suite.failures > 0 {
+        suite.failures--
+        return suite.failure
+    }
+    var data testCreated
+    if err := event.DecodeData(&data); err != nil {
+        return err
+    }
+    suite.published = append(suite.published, data.Name)
+    return nil
+}
+
+func (suite *RelaySuite) Close() error { return nil }
+
+func TestRelaySuite(t *testing.T) {
+    suite.Run(t, new(RelaySuite))
+}
+
+func (suite *RelaySuite) SetupTest() {
+    suite.ctx = context.Background()
+    suite.outbox = memoryOutbox{}
+    suite.published = nil
+    suite.failures = 0
+    suite.failure = errors.New("message refused")
+    for _, name := range []string{"first", "second", "third"} {
+        event, err := NewEvent("/test", "amb1", testCreated{Name: name})
+        suite.Require().NoError(err)
+        suite.Require().NoError(NewOutboxPublisher(suite.outbox).Publish(suite.ctx, event))
+    }
+}
+
+func (suite *RelaySuite) Test_Flush_PublishesInOrderAndMarksSent() {
+    relay := NewRelay(suite.outbox, suite, RelayConfig{BatchSize: 2})
+
+    published, err := relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(3, published)
+    suite.Equal([]string{"first", "second", "third"}, suite.published)
+
+    for _, message := range suite.outbox {
+        suite.Equal(OutboxSent, message.Status)
+        suite.NotNil(message.SentAt)
+    }
+
+    published, err = relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Zero(published)
+}
+
+func (suite *RelaySuite) Test_Flush_StopsAtFailureAndRetries() {
+    relay := NewRelay(suite.outbox, suite, RelayConfig{})
+    suite.failures = 1
+
+    published, err := relay.Flush(suite.ctx)
+    suite.Error(err)
+    suite.Zero(published)
+    suite.Empty(suite.published)
+
+    messages, err := suite.outbox.List(suite.ctx, OutboxQuery{Statuses: []string{OutboxPending}}, 10)
+    suite.Require().NoError(err)
+    suite.Len(messages, 3)
+
+    published, err = relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(3, published)
+    suite.Equal([]string{"first", "second", "third"}, suite.published)
+
+    suite.Equal(2, suite.outbox[messages[0].Id].Attempts)
+}
+
+func (suite *RelaySuite) Test_Flush_RejectsPlainMessages() {
+    plain := NewOutboxMessage("amb1", []byte("not an event"))
+    suite.Require().NoError(suite.outbox.Insert(suite.ctx, plain))
+    event, err := NewEvent("/test", "amb1", testCreated{Name: "fourth"})
+    suite.Require().NoError(err)
+    suite.Require().NoError(NewOutboxPublisher(suite.outbox).Publish(suite.ctx, event))
+
+    published, err := NewRelay(suite.outbox, suite, RelayConfig{}).Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(4, published)
+    suite.Equal([]string{"first", "second", "third", "fourth"}, suite.published)
+
+    rejected := suite.outbox[plain.Id]
+    suite.Equal(OutboxRejected, rejected.Status)
+    suite.NotEmpty(rejected.LastError)
+}
+
+func (suite *RelaySuite) Test_Flush_RejectsMessagesRefusedMaxAttempts() {
+    relay := NewRelay(suite.outbox, suite, RelayConfig{MaxAttempts: 2})
+    suite.failures = 2
+
+    _, err := relay.Flush(suite.ctx)
+    suite.Error(err)
+    suite.Empty(suite.published)
+
+    published, err := relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(2, published)
+    suite.Equal([]string{"second", "third"}, suite.published)
+
+    messages, err := suite.outbox.List(suite.ctx, OutboxQuery{Statuses: []string{OutboxRejected}}, 10)
+    suite.Require().NoError(err)
+    suite.Require().Len(messages, 1)
+    suite.Equal(2, messages[0].Attempts)
+    suite.Equal("message refused", messages[0].LastError)
+}
+
+func (suite *RelaySuite) Test_Flush_RetriesUnreachableBrokerWithoutLimit() {
+    relay := NewRelay(suite.outbox, suite, RelayConfig{MaxAttempts: 1})
+    suite.failure = context.DeadlineExceeded
+    suite.failures = 3
+
+    for i := 0; i < 3; i++ {
+        _, err := relay.Flush(suite.ctx)
+        suite.ErrorIs(err, context.DeadlineExceeded)
+    }
+    published, err := relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(3, published)
+    suite.Equal([]string{"first", "second", "third"}, suite.published)
+}
+
+func (suite *RelaySuite) Test_Purge_DeletesSentMessagesPastRetention() {
+    old := NewOutboxMessage("amb1", []byte("{}"))
+    old.Id = outboxIdAt(time.Now().Add(-2 * time.Hour))
+    old.Status = OutboxSent
+    suite.Require().NoError(suite.outbox.Insert(suite.ctx, old))
+    rejected := NewOutboxMessage("amb1", []byte("{}"))
+    rejected.Id = outboxIdAt(time.Now().Add(-3 * time.Hour))
+    rejected.Status = OutboxRejected
+    suite.Require().NoError(suite.outbox.Insert(suite.ctx, rejected))
+    relay := NewRelay(suite.outbox, suite, RelayConfig{Retention: time.Hour})
+    _, err := relay.Flush(suite.ctx)
+    suite.Require().NoError(err)
+
+    purged, err := relay.Purge(suite.ctx)
+    suite.Require().NoError(err)
+    suite.Equal(int64(1), purged)
+    suite.NotContains(suite.outbox, old.Id)
+    suite.Contains(suite.outbox, rejected.Id)
+    suite.Len(suite.outbox, 4, "recently sent messages are kept")
+}
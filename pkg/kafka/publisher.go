@@ -0,0 +1,135 @@
+package kafka
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "sync"
+)
+
+// EventPublisher publishes CloudEvents. Implementations use event.Subject,
+// the id of the aggregate, as the message key so that events of one entity stay in order.
+type EventPublisher interface {
+    // Publish blocks until the event is accepted by the underlying transport.
+    Publish(ctx context.Context, event *Event) error
+    // Close releases the resources of the publisher.
+    Close() error
+}
+
+// AsyncPublisher is an EventPublisher that can have many events in flight, e.g. to write
+// them in batches.
+type AsyncPublisher interface {
+    EventPublisher
+    // PublishAsync queues the event and returns. Unless it fails, done is called once with
+    // the outcome of the delivery; it must not block.
+    PublishAsync(ctx context.Context, event *Event, done func(err error)) error
+}
+
+// NewEventPublisher creates the publisher selected by kind: kafka, memory, log or noop.
+// The empty string selects the AMBULANCE_API_EVENT_PUBLISHER environment variable, and kafka if that is unset too.
+func NewEventPublisher(kind string) (EventPublisher, error) {
+    if kind == "" {
+        kind = os.Getenv("AMBULANCE_API_EVENT_PUBLISHER")
+    }
+    switch strings.ToLower(kind) {
+    case "", "kafka":
+        publisher, err := NewKafkaPublisher(KafkaConfig{})
+        if err != nil {
+            return nil, err
+        }
+        return publisher, nil
+    case "memory":
+        return NewMemoryPublisher(), nil
+    case "log", "stdout":
+        return NewLogPublisher(log.New(os.Stdout, "", log.LstdFlags)), nil
+    case "noop", "none":
+        return NoopPublisher{}, nil
+    }
+    return nil, fmt.Errorf("unknown event publisher %q", kind)
+}
+
+// NoopPublisher discards all events.
+type NoopPublisher struct{}
+
+func (NoopPublisher) Publish(context.Context, *Event) error { return nil }
+
+func (NoopPublisher) Close() error { return nil }
+
+// LogPublisher writes every event as one line of structured JSON to a logger.
+type LogPublisher struct {
+    logger *log.Logger
+}
+
+// NewLogPublisher returns a publisher that writes events to logger.
+func NewLogPublisher(logger *log.Logger) *LogPublisher {
+    return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(_ context.Context, event *Event) error {
+    encoded, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+    p.logger.Printf("event %s", encoded)
+    return nil
+}
+
+func (p *LogPublisher) Close() error { return nil }
+
+// MemoryPublisher records published events, it is meant for tests and local development.
+type MemoryPublisher struct {
+    mu     sync.Mutex
+    events []*Event
+}
+
+// NewMemoryPublisher returns an empty recording publisher.
+func NewMemoryPublisher() *MemoryPublisher {
+    return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(_ context.Context, event *Event) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.events = append(p.events, event)
+    return nil
+}
+
+func (p *MemoryPublisher) Close() error { return nil }
+
+// Events returns the recorded events in the order they were published.
+func (p *MemoryPublisher) Events() []*Event {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return append([]*Event(nil), p.events...)
+}
+
+// Reset forgets the recorded events.
+func (p *MemoryPublisher) Reset() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.events = nil
+}
+
+// ValidatingPublisher checks every event against a SchemaRegistry before passing it on.
+type ValidatingPublisher struct {
+    registry *SchemaRegistry
+    next     EventPublisher
+}
+
+// NewValidatingPublisher returns a publisher that rejects events not matching their schema
+// with ErrInvalidEvent or ErrUnknownSchema and publishes the others with next.
+func NewValidatingPublisher(registry *SchemaRegistry, next EventPublisher) *ValidatingPublisher {
+    return &ValidatingPublisher{registry: registry, next: next}
+}
+
+func (p *ValidatingPublisher) Publish(ctx context.Context, event *Event) error {
+    if err := p.registry.Validate(event); err != nil {
+        return err
+    }
+    return p.next.Publish(ctx, event)
+}
+
+func (p *ValidatingPublisher) Close() error { return p.next.Close() }
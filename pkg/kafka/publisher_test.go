@@ -0,0 +1,61 @@
+package kafka
+
+import (
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/suite"
+)
+
+type PublisherSuite struct {
+    suite.Suite
+}
+
+func TestPublisherSuite(t *testing.T) {
+    suite.Run(t, new(PublisherSuite))
+}
+
+func (suite *PublisherSuite) Test_NewEventPublisher_SelectsImplementation() {
+    suite.T().Setenv("AMBULANCE_API_EVENT_PUBLISHER", "memory")
+    publisher, err := NewEventPublisher("")
+    suite.Require().NoError(err)
+    suite.IsType(&MemoryPublisher{}, publisher)
+
+    publisher, err = NewEventPublisher("noop")
+    suite.Require().NoError(err)
+    suite.IsType(NoopPublisher{}, publisher)
+
+    _, err = NewEventPublisher("carrier-pigeon")
+    suite.Error(err)
+}
+
+func (suite *PublisherSuite) Test_KafkaConfig_ReadsEnvironment() {
+    suite.T().Setenv("AMBULANCE_API_KAFKA_BROKERS", "kafka-0:9093, kafka-1:9093")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_TOPIC", "events")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_CONTENT_MODE", "structured")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_TLS", "true")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_SASL_MECHANISM", "scram-sha-512")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_SASL_USERNAME", "api")
+    suite.T().Setenv("AMBULANCE_API_KAFKA_SASL_PASSWORD", "secret")
+
+    config, err := KafkaConfig{Topic: "explicit"}.withDefaults()
+    suite.Require().NoError(err)
+    suite.Equal([]string{"kafka-0:9093", "kafka-1:9093"}, config.Brokers)
+    suite.Equal("explicit", config.Topic)
+    suite.Equal(StructuredMode, config.ContentMode)
+    suite.True(config.TLS)
+    suite.Equal(10*time.Second, config.WriteTimeout)
+
+    transport, err := config.transport()
+    suite.Require().NoError(err)
+    suite.NotNil(transport)
+}
+
+func (suite *PublisherSuite) Test_KafkaConfig_RejectsInvalidSettings() {
+    suite.T().Setenv("AMBULANCE_API_KAFKA_TLS", "sometimes")
+    _, err := NewKafkaPublisher(KafkaConfig{})
+    suite.Error(err)
+
+    _, err = NewKafkaPublisher(KafkaConfig{TLS: true, SASLMechanism: "GSSAPI"})
+    suite.Error(err)
+}
@@ -0,0 +1,307 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// ReplayStart selects where a replay begins. The zero value starts at the oldest retained event.
+type ReplayStart struct {
+    // Offset is the first offset replayed in every partition; offsets before the oldest
+    // retained message start at that message.
+    Offset int64
+
+    // Since, if set, starts at the first event at or after the given time.
+    // It takes precedence over Offset.
+    Since time.Time
+}
+
+// ReplayProgress counts the events of a replay.
+type ReplayProgress struct {
+    // Total is the number of events to replay, known once the replay started.
+    Total int64
+
+    // Replayed events were dispatched to the handlers.
+    Replayed int64
+
+    // Skipped events were not CloudEvents or failed permanently, see Permanent.
+    Skipped int64
+}
+
+// Done returns the number of events processed so far.
+func (p ReplayProgress) Done() int64 {
+    return p.Replayed + p.Skipped
+}
+
+// Replayer dispatches past events to the handlers of a Router, e.g. to rebuild a projection.
+// Events are replayed up to the newest one present when the replay started.
+type Replayer struct {
+    router *Router
+
+    // OnProgress, if set, is called every ProgressInterval and once when the replay ends.
+    OnProgress func(progress ReplayProgress)
+
+    // ProgressInterval is 1s by default.
+    ProgressInterval time.Duration
+
+    progress     ReplayProgress
+    lastReported time.Time
+}
+
+// NewReplayer returns a replayer dispatching to router.
+func NewReplayer(router *Router) *Replayer {
+    return &Replayer{router: router}
+}
+
+// ReplayTopic replays the configured topic partition by partition. The consumer group
+// offsets are neither used nor changed.
+func (r *Replayer) ReplayTopic(ctx context.Context, config KafkaConfig, start ReplayStart) (ReplayProgress, error) {
+    config, err := config.withDefaults()
+    if err != nil {
+        return r.progress, err
+    }
+    partitions, err := replayPartitions(ctx, config, start)
+    if err != nil {
+        return r.progress, err
+    }
+    defer closeReaders(partitions)
+
+    r.begin()
+    for _, partition := range partitions {
+        r.progress.Total += partition.end - partition.start
+    }
+    for _, partition := range partitions {
+        for offset := partition.start; offset < partition.end; {
+            message, err := partition.reader.ReadMessage(ctx)
+            if err != nil {
+                return r.end(), fmt.Errorf("reading partition %d: %w", partition.id, err)
+            }
+            offset = message.Offset + 1
+            event, err := ParseMessage(message)
+            if err != nil {
+                r.skip(fmt.Sprintf("message %d/%d", message.Partition, message.Offset), err)
+                continue
+            }
+            if err := r.dispatch(ctx, event); err != nil {
+                return r.end(), err
+            }
+        }
+    }
+    return r.end(), nil
+}
+
+// ReplayOutbox replays the events stored in the outbox in the order they were recorded,
+// starting with the first event at or after since. Rejected messages are skipped.
+func (r *Replayer) ReplayOutbox(ctx context.Context, outbox OutboxStore, since time.Time) (ReplayProgress, error) {
+    const pageSize = 500
+    query := OutboxQuery{Statuses: []string{OutboxPending, OutboxSent}}
+    if !since.IsZero() {
+        query.FromId = outboxIdAt(since)
+    }
+
+    r.begin()
+    total, err := outbox.Count(ctx, query)
+    if err != nil {
+        return r.end(), err
+    }
+    r.progress.Total = total
+    for {
+        messages, err := outbox.List(ctx, query, pageSize)
+        if err != nil {
+            return r.end(), err
+        }
+        for _, message := range messages {
+            event, err := ParseStructured([]byte(message.Value))
+            if err != nil {
+                r.skip("outbox message "+message.Id, err)
+                continue
+            }
+            if err := r.dispatch(ctx, event); err != nil {
+                return r.end(), err
+            }
+        }
+        if len(messages) < pageSize {
+            return r.end(), nil
+        }
+        query.AfterId = messages[len(messages)-1].Id
+    }
+}
+
+// dispatch handles one event. Permanent failures are skipped, other errors abort the replay.
+func (r *Replayer) dispatch(ctx context.Context, event *Event) error {
+    if err := r.router.Dispatch(ctx, event); err != nil {
+        if !IsPermanent(err) {
+            return fmt.Errorf("replaying %s event %s: %w", event.Type, event.Id, err)
+        }
+        r.skip(event.Type+" event "+event.Id, err)
+        return nil
+    }
+    r.progress.Replayed++
+    r.report(false)
+    return nil
+}
+
+func (r *Replayer) skip(what string, err error) {
+    log.Printf("⚠️ skipping %s: %v", what, err)
+    r.progress.Skipped++
+    r.report(false)
+}
+
+func (r *Replayer) begin() {
+    r.progress = ReplayProgress{}
+    r.lastReported = time.Now()
+    if r.ProgressInterval <= 0 {
+        r.ProgressInterval = time.Second
+    }
+}
+
+func (r *Replayer) end() ReplayProgress {
+    r.report(true)
+    return r.progress
+}
+
+func (r *Replayer) report(final bool) {
+    if r.OnProgress == nil || (!final && time.Since(r.lastReported) < r.ProgressInterval) {
+        return
+    }
+    r.lastReported = time.Now()
+    r.OnProgress(r.progress)
+}
+
+// replayPartition is the range of offsets [start, end) replayed from one partition.
+type replayPartition struct {
+    id     int
+    start  int64
+    end    int64
+    reader *kafka.Reader
+}
+
+// replayPartitions positions a reader at the start of every partition of the topic.
+func replayPartitions(ctx context.Context, config KafkaConfig, start ReplayStart) ([]*replayPartition, error) {
+    transport, err := config.transport()
+    if err != nil {
+        return nil, err
+    }
+    tlsConfig, err := config.tlsConfig()
+    if err != nil {
+        return nil, err
+    }
+    mechanism, err := config.saslMechanism()
+    if err != nil {
+        return nil, err
+    }
+    client := &kafka.Client{Addr: kafka.TCP(config.Brokers...), Transport: transport, Timeout: 10 * time.Second}
+
+    metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{config.Topic}})
+    if err != nil {
+        return nil, err
+    }
+    if len(metadata.Topics) != 1 {
+        return nil, fmt.Errorf("topic %s not found", config.Topic)
+    }
+    if err := metadata.Topics[0].Error; err != nil {
+        return nil, fmt.Errorf("topic %s: %w", config.Topic, err)
+    }
+    ids := []int{}
+    for _, partition := range metadata.Topics[0].Partitions {
+        ids = append(ids, partition.ID)
+    }
+
+    // brokers reject requests for several offsets of one partition, so ask separately
+    first, err := listOffsets(ctx, client, config.Topic, ids, kafka.FirstOffsetOf)
+    if err != nil {
+        return nil, err
+    }
+    last, err := listOffsets(ctx, client, config.Topic, ids, kafka.LastOffsetOf)
+    if err != nil {
+        return nil, err
+    }
+    var since map[int]kafka.PartitionOffsets
+    if !start.Since.IsZero() {
+        since, err = listOffsets(ctx, client, config.Topic, ids, func(partition int) kafka.OffsetRequest {
+            return kafka.TimeOffsetOf(partition, start.Since)
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    partitions := []*replayPartition{}
+    for _, id := range ids {
+        partition := &replayPartition{
+            id:    id,
+            start: max(start.Offset, first[id].FirstOffset),
+            end:   last[id].LastOffset,
+        }
+        if since != nil {
+            // no offset means that every event of the partition is older
+            partition.start = partition.end
+            for offset := range since[id].Offsets {
+                if offset >= 0 {
+                    partition.start = max(offset, first[id].FirstOffset)
+                }
+            }
+        }
+        partition.start = min(partition.start, partition.end)
+
+        partition.reader = kafka.NewReader(kafka.ReaderConfig{
+            Brokers:   config.Brokers,
+            Topic:     config.Topic,
+            Partition: id,
+            MaxWait:   500 * time.Millisecond,
+            Dialer: &kafka.Dialer{
+                Timeout:       10 * time.Second,
+                DualStack:     true,
+                TLS:           tlsConfig,
+                SASLMechanism: mechanism,
+            },
+        })
+        partitions = append(partitions, partition)
+        if err := partition.reader.SetOffset(partition.start); err != nil {
+            closeReaders(partitions)
+            return nil, fmt.Errorf("seeking partition %d: %w", id, err)
+        }
+    }
+    return partitions, nil
+}
+
+// listOffsets sends one offset request for every partition and returns the responses by partition.
+func listOffsets(
+    ctx context.Context,
+    client *kafka.Client,
+    topic string,
+    partitions []int,
+    request func(partition int) kafka.OffsetRequest,
+) (map[int]kafka.PartitionOffsets, error) {
+    requests := make([]kafka.OffsetRequest, len(partitions))
+    for i, partition := range partitions {
+        requests[i] = request(partition)
+    }
+    response, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+    if err != nil {
+        return nil, err
+    }
+    offsets := map[int]kafka.PartitionOffsets{}
+    for _, partition := range response.Topics[topic] {
+        if partition.Error != nil {
+            return nil, fmt.Errorf("offsets of partition %d: %w", partition.Partition, partition.Error)
+        }
+        offsets[partition.Partition] = partition
+    }
+    return offsets, nil
+}
+
+func closeReaders(partitions []*replayPartition) {
+    var err error
+    for _, partition := range partitions {
+        err = errors.Join(err, partition.reader.Close())
+    }
+    if err != nil {
+        log.Printf("⚠️ closing readers: %v", err)
+    }
+}
@@ -0,0 +1,86 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/suite"
+)
+
+type ReplaySuite struct {
+    suite.Suite
+    ctx      context.Context
+    outbox   memoryOutbox
+    replayer *Replayer
+    replayed []string
+    failure  error
+}
+
+func TestReplaySuite(t *testing.T) {
+    suite.Run(t, new(ReplaySuite))
+}
+
+func (suite *ReplaySuite) SetupTest() {
+    suite.ctx = context.Background()
+    suite.outbox = memoryOutbox{}
+    suite.replayed = nil
+    suite.failure = nil
+
+    router := NewRouter()
+    Handle(router, func(_ context.Context, _ *Event, data testCreated) error {
+        if data.Name == "broken" {
+            return Permanent(errors.New("cannot handle broken"))
+        }
+        if suite.failure != nil {
+            return suite.failure
+        }
+        suite.replayed = append(suite.replayed, data.Name)
+        return nil
+    })
+    suite.replayer = NewReplayer(router)
+
+    publisher := NewOutboxPublisher(suite.outbox)
+    for _, name := range []string{"first", "broken", "second"} {
+        event, err := NewEvent("/test", "agg1", testCreated{Name: name})
+        suite.Require().NoError(err)
+        suite.Require().NoError(publisher.Publish(suite.ctx, event))
+    }
+    rejected := NewOutboxMessage("agg1", []byte("not an event"))
+    rejected.Status = OutboxRejected
+    suite.Require().NoError(suite.outbox.Insert(suite.ctx, rejected))
+}
+
+func (suite *ReplaySuite) Test_ReplayOutbox_DispatchesInOrderAndSkipsFailures() {
+    var reported []ReplayProgress
+    suite.replayer.OnProgress = func(progress ReplayProgress) {
+        reported = append(reported, progress)
+    }
+
+    progress, err := suite.replayer.ReplayOutbox(suite.ctx, suite.outbox, time.Time{})
+    suite.Require().NoError(err)
+    suite.Equal([]string{"first", "second"}, suite.replayed)
+    suite.Equal(ReplayProgress{Total: 3, Replayed: 2, Skipped: 1}, progress)
+    suite.Require().NotEmpty(reported)
+    suite.Equal(progress, reported[len(reported)-1])
+}
+
+func (suite *ReplaySuite) Test_ReplayOutbox_StartsAtTime() {
+    progress, err := suite.replayer.ReplayOutbox(suite.ctx, suite.outbox, time.Now().Add(time.Hour))
+    suite.Require().NoError(err)
+    suite.Zero(progress.Total)
+    suite.Empty(suite.replayed)
+
+    progress, err = suite.replayer.ReplayOutbox(suite.ctx, suite.outbox, time.Now().Add(-time.Hour))
+    suite.Require().NoError(err)
+    suite.Equal(int64(3), progress.Total)
+}
+
+func (suite *ReplaySuite) Test_ReplayOutbox_StopsAtTransientFailure() {
+    suite.failure = errors.New("database unavailable")
+
+    progress, err := suite.replayer.ReplayOutbox(suite.ctx, suite.outbox, time.Time{})
+    suite.ErrorIs(err, suite.failure)
+    suite.Zero(progress.Replayed)
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io/fs"
+    "path"
+    "regexp"
+    "strconv"
+
+    "github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+var (
+    // ErrUnknownSchema is returned by SchemaRegistry.Validate for events without a registered schema.
+    ErrUnknownSchema = errors.New("unknown event schema")
+
+    // ErrInvalidEvent is returned by SchemaRegistry.Validate for events whose data violates their schema.
+    ErrInvalidEvent = errors.New("event does not match its schema")
+)
+
+// schemaFileName matches the schema files of event types, e.g. ambulance_created.v1.json.
+var schemaFileName = regexp.MustCompile(`^([a-z0-9_.]+)\.v([0-9]+)\.json$`)
+
+// SchemaURI identifies one version of the data schema of an event type,
+// e.g. urn:wac-api:events:ambulance_created:v1. It is used as the dataschema attribute.
+func SchemaURI(eventType string, version int) string {
+    return fmt.Sprintf("urn:wac-api:events:%s:v%d", eventType, version)
+}
+
+// SchemaRegistry validates event data against JSON Schemas.
+type SchemaRegistry struct {
+    schemas map[string]*jsonschema.Schema
+}
+
+// NewSchemaRegistry compiles the JSON Schemas in the root of fsys. Files named
+// <event type>.v<version>.json describe the data of one event type, other .json files
+// may hold shared definitions referenced by relative $ref.
+func NewSchemaRegistry(fsys fs.FS) (*SchemaRegistry, error) {
+    files, err := fs.Glob(fsys, "*.json")
+    if err != nil {
+        return nil, err
+    }
+
+    const base = "file:///events/"
+    compiler := jsonschema.NewCompiler()
+    for _, file := range files {
+        content, err := fs.ReadFile(fsys, file)
+        if err != nil {
+            return nil, err
+        }
+        document, err := jsonschema.UnmarshalJSON(bytes.NewReader(content))
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", file, err)
+        }
+        if err := compiler.AddResource(base+file, document); err != nil {
+            return nil, fmt.Errorf("%s: %w", file, err)
+        }
+    }
+
+    registry := &SchemaRegistry{schemas: map[string]*jsonschema.Schema{}}
+    for _, file := range files {
+        match := schemaFileName.FindStringSubmatch(path.Base(file))
+        if match == nil {
+            continue
+        }
+        version, _ := strconv.Atoi(match[2])
+        schema, err := compiler.Compile(base + file)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", file, err)
+        }
+        registry.schemas[SchemaURI(match[1], version)] = schema
+    }
+    return registry, nil
+}
+
+// Validate checks the data of event against the schema named by its dataschema attribute.
+func (r *SchemaRegistry) Validate(event *Event) error {
+    schema, ok := r.schemas[event.DataSchema]
+    if !ok {
+        return fmt.Errorf("%w: %s (type %s)", ErrUnknownSchema, event.DataSchema, event.Type)
+    }
+    data, err := jsonschema.UnmarshalJSON(bytes.NewReader(event.Data))
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+    }
+    if err := schema.Validate(data); err != nil {
+        return fmt.Errorf("%w: %s: %v", ErrInvalidEvent, event.Type, err)
+    }
+    return nil
+}
@@ -0,0 +1,226 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "mime"
+    "net"
+    "net/http"
+    "net/smtp"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// ChannelConfig configures one channel. Type selects the channel, the other fields apply to some types only.
+type ChannelConfig struct {
+    // Type is one of smtp, webhook, kafka, log or file.
+    Type string `json:"type"`
+
+    // Host, Port, Username, Password and From configure smtp. Port is 25 by default,
+    // without Username no authentication is used.
+    Host     string `json:"host,omitempty"`
+    Port     int    `json:"port,omitempty"`
+    Username string `json:"username,omitempty"`
+    Password string `json:"password,omitempty"`
+    From     string `json:"from,omitempty"`
+
+    // URL and Headers configure webhook.
+    URL     string            `json:"url,omitempty"`
+    Headers map[string]string `json:"headers,omitempty"`
+
+    // Topic configures kafka, the brokers come from the AMBULANCE_API_KAFKA_* environment.
+    Topic string `json:"topic,omitempty"`
+
+    // Path configures file.
+    Path string `json:"path,omitempty"`
+}
+
+// NewChannel creates the channel described by config.
+func NewChannel(config ChannelConfig) (Channel, error) {
+    switch strings.ToLower(config.Type) {
+    case "smtp":
+        if config.Host == "" || config.From == "" {
+            return nil, fmt.Errorf("smtp channel needs host and from")
+        }
+        return NewSMTPChannel(config), nil
+    case "webhook":
+        if config.URL == "" {
+            return nil, fmt.Errorf("webhook channel needs url")
+        }
+        return NewWebhookChannel(config.URL, config.Headers), nil
+    case "kafka":
+        if config.Topic == "" {
+            return nil, fmt.Errorf("kafka channel needs topic")
+        }
+        publisher, err := kafka.NewKafkaPublisher(kafka.KafkaConfig{Topic: config.Topic})
+        if err != nil {
+            return nil, err
+        }
+        return NewKafkaChannel(publisher), nil
+    case "log":
+        return NewLogChannel(log.New(os.Stdout, "", log.LstdFlags)), nil
+    case "file":
+        if config.Path == "" {
+            return nil, fmt.Errorf("file channel needs path")
+        }
+        return NewFileChannel(config.Path), nil
+    }
+    return nil, fmt.Errorf("unknown channel type %q", config.Type)
+}
+
+// SMTPChannel sends notifications as plain text emails to their recipients.
+type SMTPChannel struct {
+    addr string
+    auth smtp.Auth
+    from string
+
+    // sendMail is smtp.SendMail, replaced in tests
+    sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPChannel returns a channel sending through the SMTP server of config.
+func NewSMTPChannel(config ChannelConfig) *SMTPChannel {
+    port := config.Port
+    if port == 0 {
+        port = 25
+    }
+    channel := &SMTPChannel{
+        addr:     net.JoinHostPort(config.Host, strconv.Itoa(port)),
+        from:     config.From,
+        sendMail: smtp.SendMail,
+    }
+    if config.Username != "" {
+        channel.auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+    }
+    return channel
+}
+
+func (c *SMTPChannel) Send(_ context.Context, notification *Notification) error {
+    if len(notification.Recipients) == 0 {
+        return fmt.Errorf("no recipients for department %s", notification.Department)
+    }
+    var message bytes.Buffer
+    fmt.Fprintf(&message, "From: %s\r\n", c.from)
+    fmt.Fprintf(&message, "To: %s\r\n", strings.Join(notification.Recipients, ", "))
+    fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+    fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    message.WriteString("MIME-Version: 1.0\r\n")
+    message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+    message.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+    return c.sendMail(c.addr, c.auth, c.from, notification.Recipients, message.Bytes())
+}
+
+// WebhookChannel posts notifications as JSON to a URL.
+type WebhookChannel struct {
+    url     string
+    headers map[string]string
+    http    *http.Client
+}
+
+// NewWebhookChannel returns a channel posting to url with the given extra headers.
+func NewWebhookChannel(url string, headers map[string]string) *WebhookChannel {
+    return &WebhookChannel{url: url, headers: headers, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, notification *Notification) error {
+    body, err := json.Marshal(notification)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    for name, value := range c.headers {
+        req.Header.Set(name, value)
+    }
+    resp, err := c.http.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+    }
+    return nil
+}
+
+// eventSource is the CloudEvents source attribute of the notifications published by KafkaChannel.
+const eventSource = "/wac-api/notifications"
+
+// KafkaChannel publishes notifications as department_notified events keyed by department.
+type KafkaChannel struct {
+    publisher kafka.EventPublisher
+}
+
+// NewKafkaChannel returns a channel publishing with publisher.
+func NewKafkaChannel(publisher kafka.EventPublisher) *KafkaChannel {
+    return &KafkaChannel{publisher: publisher}
+}
+
+func (c *KafkaChannel) Send(ctx context.Context, notification *Notification) error {
+    event, err := kafka.NewEvent(eventSource, notification.Department, *notification)
+    if err != nil {
+        return err
+    }
+    return c.publisher.Publish(ctx, event)
+}
+
+func (c *KafkaChannel) Close() error {
+    return c.publisher.Close()
+}
+
+// LogChannel writes notifications to a logger, it is meant for local development.
+type LogChannel struct {
+    logger *log.Logger
+}
+
+// NewLogChannel returns a channel writing to logger.
+func NewLogChannel(logger *log.Logger) *LogChannel {
+    return &LogChannel{logger: logger}
+}
+
+func (c *LogChannel) Send(_ context.Context, notification *Notification) error {
+    c.logger.Printf("notification to %s %v: %s\n%s", notification.Department, notification.Recipients, notification.Subject, notification.Body)
+    return nil
+}
+
+// FileChannel appends notifications as JSON lines to a file, it is meant for tests.
+type FileChannel struct {
+    path string
+    lock sync.Mutex
+}
+
+// NewFileChannel returns a channel appending to the file at path, which is created when needed.
+func NewFileChannel(path string) *FileChannel {
+    return &FileChannel{path: path}
+}
+
+func (c *FileChannel) Send(_ context.Context, notification *Notification) error {
+    line, err := json.Marshal(notification)
+    if err != nil {
+        return err
+    }
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    file, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+    if err != nil {
+        return err
+    }
+    if _, err := file.Write(append(line, '\n')); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "text/template"
+)
+
+// Notification is a message to the staff of one department.
+type Notification struct {
+    Department string   `json:"department"`
+    Recipients []string `json:"recipients,omitempty"`
+    Subject    string   `json:"subject"`
+    Body       string   `json:"body"`
+
+    // Reference is the id of the entity the notification is about, e.g. a procedure.
+    Reference string `json:"reference,omitempty"`
+}
+
+// EventType implements kafka.Payload for notifications published by KafkaChannel.
+func (Notification) EventType() string { return "department_notified" }
+
+// Channel delivers notifications.
+type Channel interface {
+    Send(ctx context.Context, notification *Notification) error
+}
+
+// Delivery statuses of a channel.
+const (
+    StatusSent   = "sent"
+    StatusFailed = "failed"
+)
+
+// Delivery is the outcome of sending a notification over one channel.
+type Delivery struct {
+    Channel string `json:"channel"`
+    Status  string `json:"status"`
+    Error   string `json:"error,omitempty"`
+}
+
+// Template renders the subject and body of a notification with text/template.
+type Template struct {
+    Subject string `json:"subject"`
+    Body    string `json:"body"`
+}
+
+// Route selects the channels, recipients and template of the notifications of a department.
+type Route struct {
+    // Department is compared case-insensitively, "*" matches departments without a route of their own.
+    Department string   `json:"department"`
+    Channels   []string `json:"channels"`
+    Recipients []string `json:"recipients,omitempty"`
+
+    // Template names an entry of Config.Templates, "default" if empty.
+    Template string `json:"template,omitempty"`
+}
+
+// Config configures a Notifier, see LoadConfig.
+type Config struct {
+    Channels  map[string]ChannelConfig `json:"channels"`
+    Templates map[string]Template      `json:"templates"`
+    Routes    []Route                  `json:"routes"`
+}
+
+// LoadConfig reads the JSON file named by the environment variable name, e.g.
+//
+//	{
+//	  "channels": {"mail": {"type": "smtp", "host": "smtp.example.com", "port": 587, "from": "wac@example.com"}},
+//	  "templates": {"default": {"subject": "...", "body": "..."}},
+//	  "routes": [{"department": "Interná klinika", "channels": ["mail"], "recipients": ["interna@example.com"]}]
+//	}
+//
+// It returns an empty configuration if the variable is unset.
+func LoadConfig(name string) (Config, error) {
+    config := Config{}
+    path := os.Getenv(name)
+    if path == "" {
+        return config, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return config, err
+    }
+    if err := json.Unmarshal(data, &config); err != nil {
+        return config, fmt.Errorf("reading %s: %w", path, err)
+    }
+    return config, nil
+}
+
+type route struct {
+    Route
+    channels map[string]Channel
+    subject  *template.Template
+    body     *template.Template
+}
+
+// Notifier renders notifications for departments and sends them over the channels of their route.
+type Notifier struct {
+    routes   map[string]*route
+    fallback *route
+    channels map[string]Channel
+}
+
+// NewNotifier creates the channels of config and parses its templates. Channels that are
+// not listed in config can be supplied in channels, e.g. for tests.
+func NewNotifier(config Config, channels map[string]Channel) (*Notifier, error) {
+    created := map[string]Channel{}
+    for name, channel := range channels {
+        created[name] = channel
+    }
+    for name, channelConfig := range config.Channels {
+        if _, ok := created[name]; ok {
+            continue
+        }
+        channel, err := NewChannel(channelConfig)
+        if err != nil {
+            return nil, fmt.Errorf("channel %s: %w", name, err)
+        }
+        created[name] = channel
+    }
+
+    n := &Notifier{routes: map[string]*route{}, channels: created}
+    for _, configured := range config.Routes {
+        r := &route{Route: configured, channels: map[string]Channel{}}
+        for _, name := range configured.Channels {
+            channel, ok := created[name]
+            if !ok {
+                return nil, fmt.Errorf("route %q uses unknown channel %s", configured.Department, name)
+            }
+            r.channels[name] = channel
+        }
+
+        templateName := configured.Template
+        if templateName == "" {
+            templateName = "default"
+        }
+        source, ok := config.Templates[templateName]
+        if !ok {
+            return nil, fmt.Errorf("route %q uses unknown template %s", configured.Department, templateName)
+        }
+        var err error
+        if r.subject, err = template.New(templateName + ".subject").Option("missingkey=error").Parse(source.Subject); err != nil {
+            return nil, err
+        }
+        if r.body, err = template.New(templateName + ".body").Option("missingkey=error").Parse(source.Body); err != nil {
+            return nil, err
+        }
+
+        if configured.Department == "*" {
+            n.fallback = r
+        } else {
+            n.routes[strings.ToLower(configured.Department)] = r
+        }
+    }
+    return n, nil
+}
+
+// Notify renders the template of the route of department with data and sends the notification
+// over every channel of the route. It returns no deliveries if no route matches department,
+// and an error only if the notification could not be rendered.
+func (n *Notifier) Notify(ctx context.Context, department string, reference string, data any) ([]Delivery, error) {
+    r, ok := n.routes[strings.ToLower(department)]
+    if !ok {
+        r = n.fallback
+    }
+    if r == nil {
+        return nil, nil
+    }
+
+    notification := &Notification{Department: department, Recipients: r.Recipients, Reference: reference}
+    var text bytes.Buffer
+    if err := r.subject.Execute(&text, data); err != nil {
+        return nil, err
+    }
+    notification.Subject = strings.TrimSpace(text.String())
+    text.Reset()
+    if err := r.body.Execute(&text, data); err != nil {
+        return nil, err
+    }
+    notification.Body = text.String()
+
+    deliveries := []Delivery{}
+    for _, name := range r.Channels {
+        delivery := Delivery{Channel: name, Status: StatusSent}
+        if err := r.channels[name].Send(ctx, notification); err != nil {
+            delivery.Status = StatusFailed
+            delivery.Error = err.Error()
+        }
+        deliveries = append(deliveries, delivery)
+    }
+    return deliveries, nil
+}
+
+// Close closes the channels that hold connections.
+func (n *Notifier) Close() error {
+    var err error
+    for _, channel := range n.channels {
+        if closer, ok := channel.(io.Closer); ok {
+            err = errors.Join(err, closer.Close())
+        }
+    }
+    return err
+}
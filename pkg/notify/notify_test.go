@@ -0,0 +1,178 @@
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/smtp"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/suite"
+    "github.com/wac-project/wac-api/api"
+    "github.com/wac-project/wac-api/pkg/kafka"
+)
+
+// recordingChannel records sent notifications and fails with err if set.
+type recordingChannel struct {
+    sent []Notification
+    err  error
+}
+
+func (c *recordingChannel) Send(_ context.Context, notification *Notification) error {
+    if c.err != nil {
+        return c.err
+    }
+    c.sent = append(c.sent, *notification)
+    return nil
+}
+
+type NotifySuite struct {
+    suite.Suite
+    primary  *recordingChannel
+    broken   *recordingChannel
+    notifier *Notifier
+}
+
+func TestNotifySuite(t *testing.T) {
+    suite.Run(t, new(NotifySuite))
+}
+
+func (suite *NotifySuite) SetupTest() {
+    suite.primary = &recordingChannel{}
+    suite.broken = &recordingChannel{err: errors.New("connection refused")}
+    var err error
+    suite.notifier, err = NewNotifier(Config{
+        Templates: map[string]Template{
+            "default": {Subject: "Procedure {{.Name}}", Body: "Price {{printf \"%.2f\" .Price}}"},
+            "short":   {Subject: "{{.Name}}", Body: ""},
+        },
+        Routes: []Route{
+            {Department: "Interná klinika", Channels: []string{"primary", "broken"}, Recipients: []string{"interna@example.com"}},
+            {Department: "*", Channels: []string{"primary"}, Template: "short"},
+        },
+    }, map[string]Channel{"primary": suite.primary, "broken": suite.broken})
+    suite.Require().NoError(err)
+}
+
+type templateData struct {
+    Name  string
+    Price float64
+}
+
+func (suite *NotifySuite) Test_Notify_RendersTemplateAndReportsEveryChannel() {
+    deliveries, err := suite.notifier.Notify(context.Background(), "interná KLINIKA", "p1", templateData{Name: "EKG", Price: 200.5})
+    suite.Require().NoError(err)
+    suite.Equal([]Delivery{
+        {Channel: "primary", Status: StatusSent},
+        {Channel: "broken", Status: StatusFailed, Error: "connection refused"},
+    }, deliveries)
+    suite.Equal([]Notification{{
+        Department: "interná KLINIKA",
+        Recipients: []string{"interna@example.com"},
+        Subject:    "Procedure EKG",
+        Body:       "Price 200.50",
+        Reference:  "p1",
+    }}, suite.primary.sent)
+}
+
+func (suite *NotifySuite) Test_Notify_UsesFallbackRoute() {
+    deliveries, err := suite.notifier.Notify(context.Background(), "Chirurgia", "p1", templateData{Name: "EKG"})
+    suite.Require().NoError(err)
+    suite.Equal([]Delivery{{Channel: "primary", Status: StatusSent}}, deliveries)
+    suite.Equal("EKG", suite.primary.sent[0].Subject)
+
+    notifier, err := NewNotifier(Config{}, nil)
+    suite.Require().NoError(err)
+    deliveries, err = notifier.Notify(context.Background(), "Chirurgia", "p1", nil)
+    suite.Require().NoError(err)
+    suite.Empty(deliveries)
+}
+
+func (suite *NotifySuite) Test_NewNotifier_RejectsUnknownChannelsAndTemplates() {
+    _, err := NewNotifier(Config{Routes: []Route{{Department: "*", Channels: []string{"missing"}}}}, nil)
+    suite.ErrorContains(err, "unknown channel missing")
+
+    _, err = NewNotifier(Config{
+        Channels: map[string]ChannelConfig{"log": {Type: "log"}},
+        Routes:   []Route{{Department: "*", Channels: []string{"log"}, Template: "missing"}},
+    }, nil)
+    suite.ErrorContains(err, "unknown template missing")
+
+    _, err = NewNotifier(Config{Channels: map[string]ChannelConfig{"mail": {Type: "smtp"}}}, nil)
+    suite.ErrorContains(err, "smtp channel needs host and from")
+}
+
+func (suite *NotifySuite) Test_FileChannel_AppendsJsonLines() {
+    path := filepath.Join(suite.T().TempDir(), "notifications.jsonl")
+    channel, err := NewChannel(ChannelConfig{Type: "file", Path: path})
+    suite.Require().NoError(err)
+    for _, subject := range []string{"first", "second"} {
+        suite.Require().NoError(channel.Send(context.Background(), &Notification{Department: "d1", Subject: subject}))
+    }
+
+    data, err := os.ReadFile(path)
+    suite.Require().NoError(err)
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    suite.Require().Len(lines, 2)
+    var second Notification
+    suite.Require().NoError(json.Unmarshal([]byte(lines[1]), &second))
+    suite.Equal("second", second.Subject)
+}
+
+func (suite *NotifySuite) Test_SMTPChannel_SendsEncodedMail() {
+    channel := NewSMTPChannel(ChannelConfig{Host: "smtp.example.com", From: "wac@example.com"})
+    var addr, message string
+    var to []string
+    channel.sendMail = func(a string, _ smtp.Auth, _ string, recipients []string, msg []byte) error {
+        addr, to, message = a, recipients, string(msg)
+        return nil
+    }
+
+    notification := &Notification{Department: "d1", Recipients: []string{"a@example.com", "b@example.com"}, Subject: "Výkon", Body: "line 1\nline 2"}
+    suite.Require().NoError(channel.Send(context.Background(), notification))
+    suite.Equal("smtp.example.com:25", addr)
+    suite.Equal(notification.Recipients, to)
+    suite.Contains(message, "To: a@example.com, b@example.com\r\n")
+    suite.Contains(message, "Subject: =?utf-8?q?V=C3=BDkon?=\r\n")
+    suite.True(strings.HasSuffix(message, "\r\n\r\nline 1\r\nline 2"))
+
+    suite.Error(channel.Send(context.Background(), &Notification{Department: "d1"}))
+}
+
+func (suite *NotifySuite) Test_WebhookChannel_PostsNotification() {
+    var received Notification
+    var token string
+    status := http.StatusNoContent
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        token = r.Header.Get("Authorization")
+        json.NewDecoder(r.Body).Decode(&received)
+        w.WriteHeader(status)
+    }))
+    defer server.Close()
+    channel := NewWebhookChannel(server.URL, map[string]string{"Authorization": "Bearer secret"})
+
+    suite.Require().NoError(channel.Send(context.Background(), &Notification{Department: "d1", Subject: "hello"}))
+    suite.Equal("hello", received.Subject)
+    suite.Equal("Bearer secret", token)
+
+    status = http.StatusBadGateway
+    suite.ErrorContains(channel.Send(context.Background(), &Notification{Department: "d1"}), "502")
+}
+
+func (suite *NotifySuite) Test_KafkaChannel_PublishesValidEvent() {
+    registry, err := kafka.NewSchemaRegistry(api.EventSchemas())
+    suite.Require().NoError(err)
+    events := kafka.NewMemoryPublisher()
+    channel := NewKafkaChannel(kafka.NewValidatingPublisher(registry, events))
+
+    suite.Require().NoError(channel.Send(context.Background(), &Notification{Department: "d1", Subject: "hello", Body: "text", Reference: "p1"}))
+    suite.Require().Len(events.Events(), 1)
+    event := events.Events()[0]
+    suite.Equal("department_notified", event.Type)
+    suite.Equal("d1", event.Subject)
+}
@@ -11,6 +11,8 @@ export AMBULANCE_API_ENVIRONMENT="Development"
 export AMBULANCE_API_PORT="8080"
 export AMBULANCE_API_MONGODB_USERNAME="root"
 export AMBULANCE_API_MONGODB_PASSWORD="neUhaDnes"
+# the worker sends it as AMBULANCE_WORKER_API_TOKEN
+export AMBULANCE_API_WORKER_TOKEN="local-worker-token"
 
 # Define a helper function to call docker compose with the proper compose file
 mongo() {
@@ -30,6 +32,10 @@ case "$command" in
         # Remove the trap (optional, if additional commands are run after)
         trap - EXIT
         ;;
+    memory)
+        # Run the API without MongoDB, all data is kept in memory
+        AMBULANCE_API_STORAGE=memory AMBULANCE_API_EVENT_PUBLISHER=log go run "${ProjectRoot}/cmd/ambulance-api-service"
+        ;;
     mongo)
         mongo up
         ;;
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+)
+
+// apiClient calls the REST API of the ambulance service
+type apiClient struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// newAPIClient returns a client for the API at url, e.g. http://localhost:8080/api, that
+// authorizes the workflow status updates with token
+func newAPIClient(url string, token string) *apiClient {
+	return &apiClient{url: strings.TrimSuffix(url, "/"), token: token, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// findAmbulance returns the ambulance with the given id, or nil if it does not exist
+func (c *apiClient) findAmbulance(ctx context.Context, id string) (*ambulance.Ambulance, error) {
+	var found ambulance.Ambulance
+	if ok, err := c.get(ctx, "/ambulances/"+url.PathEscape(id), &found); !ok || err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// findProcedure returns the procedure with the given id, or nil if it does not exist
+func (c *apiClient) findProcedure(ctx context.Context, id string) (*ambulance.Procedure, error) {
+	var found ambulance.Procedure
+	if ok, err := c.get(ctx, "/procedures/"+url.PathEscape(id), &found); !ok || err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// findPayment returns the payment with the given id, or nil if it does not exist
+func (c *apiClient) findPayment(ctx context.Context, id string) (*ambulance.Payment, error) {
+	var found ambulance.Payment
+	if ok, err := c.get(ctx, "/payments/"+url.PathEscape(id), &found); !ok || err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// createPayment creates payment, it returns false if a payment with its id already exists
+func (c *apiClient) createPayment(ctx context.Context, payment *ambulance.Payment) (bool, error) {
+	body, err := json.Marshal(payment)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/payments", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return false, fmt.Errorf("POST /payments returned %d: %s", resp.StatusCode, data)
+}
+
+// setWorkflowStatus records the step of the workflow procedure id is in, reported by the process
+// instance with the given id; procedures that no longer exist are ignored, and so are updates the
+// procedure has moved past, e.g. the repeated status of a retried task
+func (c *apiClient) setWorkflowStatus(ctx context.Context, id string, processInstanceId string, status string) error {
+	body, err := json.Marshal(ambulance.WorkflowStatusUpdate{Status: status, ProcessInstanceId: processInstanceId})
+	if err != nil {
+		return err
+	}
+	path := "/procedures/" + url.PathEscape(id) + "/workflow/status"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	case http.StatusConflict:
+		data, _ := io.ReadAll(resp.Body)
+		log.Printf("Ignored workflow status %s of procedure %s: %s", status, id, data)
+		return nil
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("PUT %s returned %d: %s", path, resp.StatusCode, data)
+}
+
+// get decodes the response to a GET request into response, it returns false for 404 Not Found
+func (c *apiClient) get(ctx context.Context, path string, response any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("GET %s returned %d: %s", path, resp.StatusCode, body)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(response)
+}
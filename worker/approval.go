@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// approvalTaskKey is the id of the Approve Submission user task
+const approvalTaskKey = "ApproveSubmission"
+
+// approvalRule approves the procedures that satisfy all of its conditions. Conditions that are
+// not set are not checked, so a rule without any approves every procedure.
+type approvalRule struct {
+	Name string `json:"name"`
+	// PriceBelow approves procedures cheaper than it
+	PriceBelow float64 `json:"price_below,omitempty"`
+	// Payers and VisitTypes are compared case-insensitively
+	Payers     []string `json:"payers,omitempty"`
+	VisitTypes []string `json:"visit_types,omitempty"`
+}
+
+// matches reports whether the rule approves p
+func (r approvalRule) matches(p *ambulance.Procedure) bool {
+	oneOf := func(values []string, value string) bool {
+		if len(values) == 0 {
+			return true
+		}
+		for _, v := range values {
+			if strings.EqualFold(v, strings.TrimSpace(value)) {
+				return true
+			}
+		}
+		return false
+	}
+	if r.PriceBelow > 0 && p.Price >= r.PriceBelow {
+		return false
+	}
+	return oneOf(r.Payers, p.Payer) && oneOf(r.VisitTypes, p.VisitType)
+}
+
+// loadApprovalRules reads the auto-approval rules from the environment:
+//
+//   - AMBULANCE_WORKER_APPROVAL_RULES names a JSON file with a list of rules, e.g.
+//     [{"name": "cheap checkups", "price_below": 50, "visit_types": ["checkup"]}]
+//   - AMBULANCE_WORKER_AUTO_APPROVE_BELOW adds a rule approving procedures cheaper than its value
+//
+// Without either every submission waits for a person to approve it.
+func loadApprovalRules() ([]approvalRule, error) {
+	rules := []approvalRule{}
+	if path := os.Getenv("AMBULANCE_WORKER_APPROVAL_RULES"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	if value := os.Getenv("AMBULANCE_WORKER_AUTO_APPROVE_BELOW"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("invalid AMBULANCE_WORKER_AUTO_APPROVE_BELOW value %q", value)
+		}
+		rules = append(rules, approvalRule{Name: "price below " + value, PriceBelow: threshold})
+	}
+	for i := range rules {
+		if rules[i].PriceBelow < 0 {
+			return nil, fmt.Errorf("approval rule %q has a negative price_below", rules[i].Name)
+		}
+		if rules[i].Name == "" {
+			rules[i].Name = fmt.Sprintf("rule %d", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// autoApprover completes the Approve Submission tasks of procedures matching one of its rules,
+// the other tasks and those claimed by a person are left alone
+type autoApprover struct {
+	client *camunda.Client
+	rules  []approvalRule
+	// skipped are the pending tasks that matched no rule, they are not checked again
+	skipped map[string]bool
+}
+
+func newAutoApprover(client *camunda.Client, rules []approvalRule) *autoApprover {
+	return &autoApprover{client: client, rules: rules, skipped: map[string]bool{}}
+}
+
+// approvePending checks the pending approvals once
+func (a *autoApprover) approvePending(ctx context.Context) error {
+	tasks, err := a.client.UserTasks(ctx, approvalTaskKey)
+	if err != nil {
+		return err
+	}
+	pending := map[string]bool{}
+	for _, task := range tasks {
+		pending[task.Id] = true
+		if task.Assignee != "" || a.skipped[task.Id] {
+			continue
+		}
+		variables, err := a.client.UserTaskVariables(ctx, task.Id, "procedureData")
+		if err != nil {
+			log.Printf("[Approve] Failed to read procedure of task %s: %v", task.Id, err)
+			continue
+		}
+		p, err := camunda.Get[*ambulance.Procedure](variables, "procedureData")
+		if err != nil {
+			log.Printf("[Approve] Task %s is left for approval: %v", task.Id, err)
+			a.skipped[task.Id] = true
+			continue
+		}
+		rule := a.match(p)
+		if rule == nil {
+			log.Printf("[Approve] Procedure %s matches no rule, it is left for approval (task %s)", p.Id, task.Id)
+			a.skipped[task.Id] = true
+			continue
+		}
+		err = a.client.CompleteUserTask(ctx, task.Id, map[string]any{
+			"approved":       true,
+			"approvedBy":     "auto-approver",
+			"approvalReason": "matches rule " + rule.Name,
+		})
+		if err != nil {
+			log.Printf("[Approve] Failed to complete task %s: %v", task.Id, err)
+			continue
+		}
+		log.Printf("[Approve] Procedure %s approved by rule %q (task %s)", p.Id, rule.Name, task.Id)
+	}
+	for id := range a.skipped {
+		if !pending[id] {
+			delete(a.skipped, id)
+		}
+	}
+	return nil
+}
+
+// match returns the first rule approving p or nil
+func (a *autoApprover) match(p *ambulance.Procedure) *approvalRule {
+	for i := range a.rules {
+		if a.rules[i].matches(p) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+// run checks the pending approvals every pollInterval until ctx is done
+func (a *autoApprover) run(ctx context.Context, pollInterval time.Duration) {
+	for ctx.Err() == nil {
+		if err := a.approvePending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[Approve] Error fetching user tasks: %v", err)
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// fakeTaskEngine serves the user tasks of the engine and records completions
+type fakeTaskEngine struct {
+	tasks         []camunda.UserTask
+	procedures    map[string]ambulance.Procedure
+	variableReads int
+	completed     map[string]camunda.Variables
+}
+
+func (e *fakeTaskEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1:
+		json.NewEncoder(w).Encode(e.tasks)
+	case parts[2] == "variables":
+		e.variableReads++
+		procedure, _ := camunda.NewVariable(e.procedures[parts[1]])
+		json.NewEncoder(w).Encode(camunda.Variables{"procedureData": procedure})
+	case parts[2] == "complete":
+		var body struct {
+			Variables camunda.Variables `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		e.completed[parts[1]] = body.Variables
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type ApprovalSuite struct {
+	suite.Suite
+}
+
+func TestApprovalSuite(t *testing.T) {
+	suite.Run(t, new(ApprovalSuite))
+}
+
+func (suite *ApprovalSuite) Test_LoadApprovalRules_CombinesFileAndThreshold() {
+	rules, err := loadApprovalRules()
+	suite.Require().NoError(err)
+	suite.Empty(rules)
+
+	path := filepath.Join(suite.T().TempDir(), "rules.json")
+	suite.Require().NoError(os.WriteFile(path, []byte(`[{"payers":["VSZP"],"visit_types":["checkup"]}]`), 0o644))
+	suite.T().Setenv("AMBULANCE_WORKER_APPROVAL_RULES", path)
+	suite.T().Setenv("AMBULANCE_WORKER_AUTO_APPROVE_BELOW", "50")
+	rules, err = loadApprovalRules()
+	suite.Require().NoError(err)
+	suite.Equal([]approvalRule{
+		{Name: "rule 1", Payers: []string{"VSZP"}, VisitTypes: []string{"checkup"}},
+		{Name: "price below 50", PriceBelow: 50},
+	}, rules)
+
+	suite.T().Setenv("AMBULANCE_WORKER_AUTO_APPROVE_BELOW", "cheap")
+	_, err = loadApprovalRules()
+	suite.ErrorContains(err, "AMBULANCE_WORKER_AUTO_APPROVE_BELOW")
+}
+
+func (suite *ApprovalSuite) Test_Matches_ChecksEveryCondition() {
+	rule := approvalRule{PriceBelow: 100, Payers: []string{"VSZP", "Dovera"}, VisitTypes: []string{"checkup"}}
+	suite.True(rule.matches(&ambulance.Procedure{Price: 99, Payer: "vszp", VisitType: "Checkup"}))
+	suite.False(rule.matches(&ambulance.Procedure{Price: 100, Payer: "VSZP", VisitType: "checkup"}))
+	suite.False(rule.matches(&ambulance.Procedure{Price: 20, Payer: "Union", VisitType: "checkup"}))
+	suite.False(rule.matches(&ambulance.Procedure{Price: 20, Payer: "VSZP", VisitType: "emergency"}))
+	suite.True(approvalRule{}.matches(&ambulance.Procedure{Price: 5000}))
+}
+
+func (suite *ApprovalSuite) Test_ApprovePending_CompletesMatchingUnclaimedTasks() {
+	engine := &fakeTaskEngine{
+		tasks: []camunda.UserTask{
+			{Id: "t1", TaskDefinitionKey: approvalTaskKey},
+			{Id: "t2", TaskDefinitionKey: approvalTaskKey},
+			{Id: "t3", TaskDefinitionKey: approvalTaskKey, Assignee: "mudr.novak"},
+		},
+		procedures: map[string]ambulance.Procedure{
+			"t1": {Id: "p1", Price: 20},
+			"t2": {Id: "p2", Price: 2000},
+			"t3": {Id: "p3", Price: 20},
+		},
+		completed: map[string]camunda.Variables{},
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	approver := newAutoApprover(camunda.NewClient(server.URL), []approvalRule{{Name: "cheap", PriceBelow: 50}})
+
+	suite.Require().NoError(approver.approvePending(context.Background()))
+	suite.Require().Len(engine.completed, 1)
+	approvedBy, _ := camunda.Get[string](engine.completed["t1"], "approvedBy")
+	reason, _ := camunda.Get[string](engine.completed["t1"], "approvalReason")
+	suite.Equal("auto-approver", approvedBy)
+	suite.Equal("matches rule cheap", reason)
+
+	// t2 matched no rule and is not read again while it is pending
+	engine.tasks = engine.tasks[1:]
+	suite.Require().NoError(approver.approvePending(context.Background()))
+	suite.Equal(2, engine.variableReads)
+	suite.Len(engine.completed, 1)
+}
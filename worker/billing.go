@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// billingPaymentId returns the id of the payment billing a procedure, deriving it from the
+// procedure id makes a repeated Update Billing task find the payment instead of creating another
+func billingPaymentId(procedureId string) string {
+	return "payment-" + procedureId
+}
+
+// handleBilling creates the payment of the approved procedure and sets paymentId
+func handleBilling(api *apiClient) camunda.HandlerFunc {
+	return camunda.Typed(func(ctx context.Context, t *camunda.ExternalTask, vars procedureVariables) (map[string]any, error) {
+		if vars.ProcedureId == "" {
+			return nil, fmt.Errorf("%w procedureId", camunda.ErrMissingVariable)
+		}
+		p, err := api.findProcedure(ctx, vars.ProcedureId)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, fmt.Errorf("procedure %s does not exist", vars.ProcedureId)
+		}
+
+		payment := &ambulance.Payment{
+			Id:          billingPaymentId(p.Id),
+			Name:        p.Name,
+			Description: "Billed by process instance " + t.ProcessInstanceId,
+			ProcedureId: p.Id,
+			Insurance:   p.Payer,
+			Amount:      p.Price,
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		}
+		created, err := api.createPayment(ctx, payment)
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			existing, err := api.findPayment(ctx, payment.Id)
+			if err != nil {
+				return nil, err
+			}
+			if existing == nil || existing.ProcedureId != p.Id {
+				return nil, fmt.Errorf("payment %s conflicts with another payment", payment.Id)
+			}
+			log.Printf("[Billing] Procedure %s is already billed by payment %s (task %s)", p.Id, payment.Id, t.Id)
+		} else {
+			log.Printf("[Billing] Created payment %s of %.2f for procedure %s (task %s)", payment.Id, payment.Amount, p.Id, t.Id)
+		}
+		return map[string]any{"paymentId": payment.Id}, nil
+	})
+}
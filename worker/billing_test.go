@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// workerToken authorizes the workflow status updates of the worker at fakeAPI
+const workerToken = "worker-token"
+
+// fakeAPI serves the procedures and payments endpoints used by the worker
+type fakeAPI struct {
+	lock       sync.Mutex
+	ambulances map[string]ambulance.Ambulance
+	procedures map[string]ambulance.Procedure
+	payments   map[string]ambulance.Payment
+	// statuses are the workflow statuses set for procedures, in order
+	statuses map[string][]string
+	// processes are the process instances that reported the statuses
+	processes map[string]string
+	// stale are the workflow statuses refused as conflicting with the procedure
+	stale map[string]bool
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	reply := func(status int, body any) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+	id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/payments":
+		var payment ambulance.Payment
+		json.NewDecoder(r.Body).Decode(&payment)
+		if _, ok := a.payments[payment.Id]; ok {
+			reply(http.StatusConflict, map[string]string{"message": "Payment already exists"})
+			return
+		}
+		a.payments[payment.Id] = payment
+		reply(http.StatusCreated, payment)
+	case strings.HasPrefix(r.URL.Path, "/api/payments/"):
+		if payment, ok := a.payments[id]; ok {
+			reply(http.StatusOK, payment)
+			return
+		}
+		reply(http.StatusNotFound, map[string]string{"message": "Payment not found"})
+	case strings.HasPrefix(r.URL.Path, "/api/ambulances/"):
+		if found, ok := a.ambulances[id]; ok {
+			reply(http.StatusOK, found)
+			return
+		}
+		reply(http.StatusNotFound, map[string]string{"message": "Ambulance not found"})
+	case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/workflow/status"):
+		id = strings.Split(r.URL.Path, "/")[3]
+		if r.Header.Get("Authorization") != "Bearer "+workerToken {
+			reply(http.StatusUnauthorized, map[string]string{"message": "Invalid worker token"})
+			return
+		}
+		if _, ok := a.procedures[id]; !ok {
+			reply(http.StatusNotFound, map[string]string{"message": "Procedure not found"})
+			return
+		}
+		var update ambulance.WorkflowStatusUpdate
+		json.NewDecoder(r.Body).Decode(&update)
+		if a.stale[update.Status] {
+			reply(http.StatusConflict, map[string]string{"message": "Workflow status conflict"})
+			return
+		}
+		a.statuses[id] = append(a.statuses[id], update.Status)
+		a.processes[id] = update.ProcessInstanceId
+		reply(http.StatusOK, a.procedures[id])
+	case strings.HasPrefix(r.URL.Path, "/api/procedures/"):
+		if procedure, ok := a.procedures[id]; ok {
+			reply(http.StatusOK, procedure)
+			return
+		}
+		reply(http.StatusNotFound, map[string]string{"message": "Procedure not found"})
+	default:
+		reply(http.StatusNotFound, map[string]string{"message": "Not found"})
+	}
+}
+
+// newFakeAPI serves ambulance amb1 with procedure p1
+func newFakeAPI() (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{
+		ambulances: map[string]ambulance.Ambulance{
+			"amb1": {Id: "amb1", Name: "Ambulancia Hlavná", Department: "Interná klinika"},
+		},
+		procedures: map[string]ambulance.Procedure{
+			"p1": {Id: "p1", Name: "EKG", Patient: "Peter Horváth", Price: 200.5, Payer: "VSZP", AmbulanceId: "amb1"},
+		},
+		payments:  map[string]ambulance.Payment{},
+		statuses:  map[string][]string{},
+		processes: map[string]string{},
+		stale:     map[string]bool{},
+	}
+	return api, httptest.NewServer(api)
+}
+
+// procedureTask returns an external task with the procedureId variable
+func procedureTask(procedureId string) *camunda.ExternalTask {
+	variables, _ := camunda.NewVariables(map[string]any{"procedureId": procedureId})
+	return &camunda.ExternalTask{Id: "t1", ProcessInstanceId: "pi1", Variables: variables}
+}
+
+type BillingSuite struct {
+	suite.Suite
+	api     *fakeAPI
+	server  *httptest.Server
+	handler camunda.HandlerFunc
+}
+
+func TestBillingSuite(t *testing.T) {
+	suite.Run(t, new(BillingSuite))
+}
+
+func (suite *BillingSuite) SetupTest() {
+	suite.api, suite.server = newFakeAPI()
+	suite.handler = handleBilling(newAPIClient(suite.server.URL+"/api", workerToken))
+}
+
+func (suite *BillingSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *BillingSuite) Test_HandleBilling_CreatesPaymentOnce() {
+	for range 2 {
+		result, err := suite.handler(context.Background(), procedureTask("p1"))
+		suite.Require().NoError(err)
+		suite.Equal(map[string]any{"paymentId": "payment-p1"}, result)
+	}
+
+	suite.Require().Len(suite.api.payments, 1)
+	payment := suite.api.payments["payment-p1"]
+	suite.Equal("p1", payment.ProcedureId)
+	suite.Equal(200.5, payment.Amount)
+	suite.Equal("VSZP", payment.Insurance)
+	suite.Equal("EKG", payment.Name)
+	suite.NotEmpty(payment.Timestamp)
+}
+
+func (suite *BillingSuite) Test_HandleBilling_FailsForConflictingPayment() {
+	suite.api.payments["payment-p1"] = ambulance.Payment{Id: "payment-p1", ProcedureId: "p2"}
+
+	_, err := suite.handler(context.Background(), procedureTask("p1"))
+	suite.ErrorContains(err, "conflicts")
+}
+
+func (suite *BillingSuite) Test_HandleBilling_FailsForMissingProcedure() {
+	_, err := suite.handler(context.Background(), procedureTask("p404"))
+	suite.ErrorContains(err, "procedure p404 does not exist")
+
+	_, err = suite.handler(context.Background(), &camunda.ExternalTask{})
+	suite.ErrorIs(err, camunda.ErrMissingVariable)
+}
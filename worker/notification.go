@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+	"github.com/wac-project/wac-api/pkg/notify"
+)
+
+// defaultNotifications logs a notification for every department when no configuration is given
+var defaultNotifications = notify.Config{
+	Channels: map[string]notify.ChannelConfig{"log": {Type: "log"}},
+	Routes:   []notify.Route{{Department: "*", Channels: []string{"log"}}},
+}
+
+// defaultTemplate is used by routes without a template of their own
+var defaultTemplate = notify.Template{
+	Subject: `Procedure {{.Procedure.Name}} of {{.Procedure.Patient}} was approved`,
+	Body: `Procedure {{.Procedure.Id}} performed in ambulance {{.Ambulance.Name}} ({{.Ambulance.Id}}) was approved.
+
+Patient:    {{.Procedure.Patient}}
+Visit type: {{.Procedure.VisitType}}
+Price:      {{printf "%.2f" .Procedure.Price}}
+Payer:      {{.Procedure.Payer}}
+Performed:  {{.Procedure.Timestamp}}
+`,
+}
+
+// loadNotifier creates the notifier from the file named by AMBULANCE_WORKER_NOTIFY_CONFIG
+func loadNotifier() (*notify.Notifier, error) {
+	config, err := notify.LoadConfig("AMBULANCE_WORKER_NOTIFY_CONFIG")
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Routes) == 0 {
+		config.Channels, config.Routes = defaultNotifications.Channels, defaultNotifications.Routes
+	}
+	if _, ok := config.Templates["default"]; !ok {
+		if config.Templates == nil {
+			config.Templates = map[string]notify.Template{}
+		}
+		config.Templates["default"] = defaultTemplate
+	}
+	return notify.NewNotifier(config, nil)
+}
+
+// notificationData is rendered by the notification templates
+type notificationData struct {
+	Procedure         *ambulance.Procedure
+	Ambulance         *ambulance.Ambulance
+	ProcessInstanceId string
+}
+
+// handleNotify notifies the department of the ambulance of the procedure and records the
+// outcome in notificationStatus (sent, partial or skipped) and notificationDeliveries.
+// The task fails, and is retried, if no channel delivered the notification.
+func handleNotify(api *apiClient, notifier *notify.Notifier) camunda.HandlerFunc {
+	return camunda.Typed(func(ctx context.Context, t *camunda.ExternalTask, vars procedureVariables) (map[string]any, error) {
+		if vars.ProcedureId == "" {
+			return nil, fmt.Errorf("%w procedureId", camunda.ErrMissingVariable)
+		}
+		p, err := api.findProcedure(ctx, vars.ProcedureId)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, fmt.Errorf("procedure %s does not exist", vars.ProcedureId)
+		}
+		a, err := api.findAmbulance(ctx, p.AmbulanceId)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			return nil, fmt.Errorf("ambulance %s of procedure %s does not exist", p.AmbulanceId, p.Id)
+		}
+
+		deliveries, err := notifier.Notify(ctx, a.Department, p.Id, notificationData{Procedure: p, Ambulance: a, ProcessInstanceId: t.ProcessInstanceId})
+		if err != nil {
+			return nil, fmt.Errorf("rendering notification: %w", err)
+		}
+		sent := 0
+		for _, delivery := range deliveries {
+			if delivery.Status == notify.StatusSent {
+				sent++
+			}
+		}
+		status := "sent"
+		switch {
+		case len(deliveries) == 0:
+			status = "skipped"
+		case sent == 0:
+			return nil, fmt.Errorf("no channel delivered the notification: %v", deliveries)
+		case sent < len(deliveries):
+			status = "partial"
+		}
+		log.Printf("[Notify] Notification of department %q for procedure %s %s (task %s)", a.Department, p.Id, status, t.Id)
+		return map[string]any{"notificationStatus": status, "notificationDeliveries": deliveries}, nil
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/pkg/notify"
+)
+
+// recordingChannel records sent notifications and fails with err if set
+type recordingChannel struct {
+	sent []notify.Notification
+	err  error
+}
+
+func (c *recordingChannel) Send(_ context.Context, notification *notify.Notification) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, *notification)
+	return nil
+}
+
+type NotificationSuite struct {
+	suite.Suite
+	api     *fakeAPI
+	server  *httptest.Server
+	mail    *recordingChannel
+	webhook *recordingChannel
+	config  notify.Config
+}
+
+func TestNotificationSuite(t *testing.T) {
+	suite.Run(t, new(NotificationSuite))
+}
+
+func (suite *NotificationSuite) SetupTest() {
+	suite.api, suite.server = newFakeAPI()
+	suite.mail = &recordingChannel{}
+	suite.webhook = &recordingChannel{}
+	suite.config = notify.Config{
+		Templates: map[string]notify.Template{"default": defaultTemplate},
+		Routes: []notify.Route{
+			{Department: "Interná klinika", Channels: []string{"mail", "webhook"}, Recipients: []string{"interna@example.com"}},
+		},
+	}
+}
+
+func (suite *NotificationSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *NotificationSuite) notify() (map[string]any, error) {
+	notifier, err := notify.NewNotifier(suite.config, map[string]notify.Channel{"mail": suite.mail, "webhook": suite.webhook})
+	suite.Require().NoError(err)
+	return handleNotify(newAPIClient(suite.server.URL+"/api", workerToken), notifier)(context.Background(), procedureTask("p1"))
+}
+
+func (suite *NotificationSuite) Test_HandleNotify_RoutesByDepartmentAndRecordsDeliveries() {
+	suite.webhook.err = errors.New("webhook returned 502")
+
+	result, err := suite.notify()
+	suite.Require().NoError(err)
+	suite.Equal(map[string]any{
+		"notificationStatus": "partial",
+		"notificationDeliveries": []notify.Delivery{
+			{Channel: "mail", Status: notify.StatusSent},
+			{Channel: "webhook", Status: notify.StatusFailed, Error: "webhook returned 502"},
+		},
+	}, result)
+	suite.Require().Len(suite.mail.sent, 1)
+	sent := suite.mail.sent[0]
+	suite.Equal("Procedure EKG of Peter Horváth was approved", sent.Subject)
+	suite.Contains(sent.Body, "ambulance Ambulancia Hlavná (amb1)")
+	suite.Equal([]string{"interna@example.com"}, sent.Recipients)
+	suite.Equal("p1", sent.Reference)
+}
+
+func (suite *NotificationSuite) Test_HandleNotify_FailsWhenNothingWasDelivered() {
+	suite.mail.err = errors.New("connection refused")
+	suite.webhook.err = errors.New("connection refused")
+
+	_, err := suite.notify()
+	suite.ErrorContains(err, "no channel delivered the notification")
+}
+
+func (suite *NotificationSuite) Test_HandleNotify_SkipsDepartmentsWithoutRoute() {
+	amb := suite.api.ambulances["amb1"]
+	amb.Department = "Chirurgia"
+	suite.api.ambulances["amb1"] = amb
+
+	result, err := suite.notify()
+	suite.Require().NoError(err)
+	suite.Equal("skipped", result["notificationStatus"])
+	suite.Empty(suite.mail.sent)
+}
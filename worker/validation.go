@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+)
+
+// validationError describes a problem with one field of the submitted procedure
+type validationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationRules are the checks of the Validate Data task
+type validationRules struct {
+	// MaxPrice is the highest accepted price, prices must be positive
+	MaxPrice float64
+	// Payers are the known payers, compared case-insensitively
+	Payers []string
+	// MaxAge is how long ago a procedure may have been performed
+	MaxAge time.Duration
+	// ClockSkew is how far in the future a timestamp may be
+	ClockSkew time.Duration
+	// FindAmbulance returns the ambulance with the given id or nil if it does not exist
+	FindAmbulance func(ctx context.Context, id string) (*ambulance.Ambulance, error)
+}
+
+// loadValidationRules reads the rules from the environment:
+//
+//   - AMBULANCE_WORKER_MAX_PRICE, 10000 by default
+//   - AMBULANCE_WORKER_PAYERS, comma separated, VSZP,Dovera,Union by default
+//   - AMBULANCE_WORKER_MAX_PROCEDURE_AGE, 8760h (a year) by default
+func loadValidationRules(findAmbulance func(ctx context.Context, id string) (*ambulance.Ambulance, error)) (validationRules, error) {
+	rules := validationRules{ClockSkew: 5 * time.Minute, FindAmbulance: findAmbulance}
+	value := enviro("AMBULANCE_WORKER_MAX_PRICE", "10000")
+	maxPrice, err := strconv.ParseFloat(value, 64)
+	if err != nil || maxPrice <= 0 {
+		return rules, fmt.Errorf("invalid AMBULANCE_WORKER_MAX_PRICE value %q", value)
+	}
+	rules.MaxPrice = maxPrice
+
+	for _, payer := range strings.Split(enviro("AMBULANCE_WORKER_PAYERS", "VSZP,Dovera,Union"), ",") {
+		if payer = strings.TrimSpace(payer); payer != "" {
+			rules.Payers = append(rules.Payers, payer)
+		}
+	}
+
+	value = enviro("AMBULANCE_WORKER_MAX_PROCEDURE_AGE", "8760h")
+	if rules.MaxAge, err = time.ParseDuration(value); err != nil || rules.MaxAge <= 0 {
+		return rules, fmt.Errorf("invalid AMBULANCE_WORKER_MAX_PROCEDURE_AGE value %q", value)
+	}
+	return rules, nil
+}
+
+// validate returns the problems of p. Errors are returned only if the checks could not run.
+func (rules validationRules) validate(ctx context.Context, p *ambulance.Procedure, now time.Time) ([]validationError, error) {
+	problems := []validationError{}
+	invalid := func(field string, format string, args ...any) {
+		problems = append(problems, validationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	required := []struct{ field, value string }{
+		{"id", p.Id},
+		{"patient", p.Patient},
+		{"visit_type", p.VisitType},
+		{"ambulance_id", p.AmbulanceId},
+		{"payer", p.Payer},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			invalid(r.field, "%s is required", r.field)
+		}
+	}
+
+	if p.Price <= 0 || p.Price > rules.MaxPrice {
+		invalid("price", "price %.2f is not between 0 and %.2f", p.Price, rules.MaxPrice)
+	}
+
+	if p.Payer != "" && len(rules.Payers) > 0 {
+		known := false
+		for _, payer := range rules.Payers {
+			known = known || strings.EqualFold(payer, strings.TrimSpace(p.Payer))
+		}
+		if !known {
+			invalid("payer", "payer %q is not one of %s", p.Payer, strings.Join(rules.Payers, ", "))
+		}
+	}
+
+	if p.Timestamp != "" {
+		performed, err := time.Parse(time.RFC3339, p.Timestamp)
+		switch {
+		case err != nil:
+			invalid("timestamp", "timestamp %q is not an RFC 3339 date-time", p.Timestamp)
+		case performed.After(now.Add(rules.ClockSkew)):
+			invalid("timestamp", "timestamp %s is in the future", p.Timestamp)
+		case performed.Before(now.Add(-rules.MaxAge)):
+			invalid("timestamp", "timestamp %s is older than %s", p.Timestamp, rules.MaxAge)
+		}
+	}
+
+	if p.AmbulanceId != "" && rules.FindAmbulance != nil {
+		found, err := rules.FindAmbulance(ctx, p.AmbulanceId)
+		if err != nil {
+			return nil, fmt.Errorf("looking up ambulance %s: %w", p.AmbulanceId, err)
+		}
+		if found == nil {
+			invalid("ambulance_id", "ambulance %q does not exist", p.AmbulanceId)
+		}
+	}
+
+	return problems, nil
+}
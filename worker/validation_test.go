@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/internal/ambulance"
+)
+
+type ValidationSuite struct {
+	suite.Suite
+	now   time.Time
+	rules validationRules
+}
+
+func TestValidationSuite(t *testing.T) {
+	suite.Run(t, new(ValidationSuite))
+}
+
+func (suite *ValidationSuite) SetupTest() {
+	suite.now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	suite.T().Setenv("AMBULANCE_WORKER_PAYERS", "VSZP, Dovera")
+	rules, err := loadValidationRules(func(_ context.Context, id string) (*ambulance.Ambulance, error) {
+		if id == "amb1" {
+			return &ambulance.Ambulance{Id: id}, nil
+		}
+		return nil, nil
+	})
+	suite.Require().NoError(err)
+	suite.rules = rules
+}
+
+func (suite *ValidationSuite) procedure() *ambulance.Procedure {
+	return &ambulance.Procedure{
+		Id:          "p1",
+		Patient:     "Peter Horváth",
+		VisitType:   "checkup",
+		Price:       120,
+		Payer:       "vszp",
+		AmbulanceId: "amb1",
+		Timestamp:   "2026-02-28T10:00:00Z",
+	}
+}
+
+func (suite *ValidationSuite) Test_Validate_AcceptsValidProcedure() {
+	problems, err := suite.rules.validate(context.Background(), suite.procedure(), suite.now)
+	suite.Require().NoError(err)
+	suite.Empty(problems)
+}
+
+func (suite *ValidationSuite) Test_Validate_ReportsEveryProblem() {
+	p := suite.procedure()
+	p.Patient = " "
+	p.Price = 20000
+	p.Payer = "Unknown"
+	p.AmbulanceId = "amb404"
+	p.Timestamp = "2026-03-02T10:00:00Z"
+
+	problems, err := suite.rules.validate(context.Background(), p, suite.now)
+	suite.Require().NoError(err)
+	suite.Equal([]validationError{
+		{Field: "patient", Message: "patient is required"},
+		{Field: "price", Message: "price 20000.00 is not between 0 and 10000.00"},
+		{Field: "payer", Message: `payer "Unknown" is not one of VSZP, Dovera`},
+		{Field: "timestamp", Message: "timestamp 2026-03-02T10:00:00Z is in the future"},
+		{Field: "ambulance_id", Message: `ambulance "amb404" does not exist`},
+	}, problems)
+
+	p.Timestamp = "2024-01-01T00:00:00Z"
+	problems, err = suite.rules.validate(context.Background(), p, suite.now)
+	suite.Require().NoError(err)
+	suite.Contains(problems, validationError{Field: "timestamp", Message: "timestamp 2024-01-01T00:00:00Z is older than 8760h0m0s"})
+}
+
+func (suite *ValidationSuite) Test_Validate_FailsWhenAmbulanceLookupFails() {
+	suite.rules.FindAmbulance = func(context.Context, string) (*ambulance.Ambulance, error) {
+		return nil, errors.New("connection refused")
+	}
+	_, err := suite.rules.validate(context.Background(), suite.procedure(), suite.now)
+	suite.ErrorContains(err, "connection refused")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+// tracked records the workflow status of the procedure of a task around handler: running while
+// it runs, unless empty, and the status returned by done for its result once it succeeded. The
+// procedure is the business key of the process instance, tasks of processes started without one
+// are not tracked. The updates name the process instance, so that the API links a procedure whose
+// process it failed to record. A failed update fails the task, so it is retried.
+func tracked(api *apiClient, topic camunda.Topic, running string, handler camunda.HandlerFunc, done func(result map[string]any) string) camunda.HandlerFunc {
+	return func(ctx context.Context, task *camunda.ExternalTask) (map[string]any, error) {
+		id := task.BusinessKey
+		if id == "" {
+			return handler(ctx, task)
+		}
+		if running != "" {
+			if err := api.setWorkflowStatus(ctx, id, task.ProcessInstanceId, running); err != nil {
+				return nil, fmt.Errorf("setting workflow status of procedure %s: %w", id, err)
+			}
+		}
+
+		result, err := handler(ctx, task)
+		if err != nil {
+			// the worker throws invalidProcedureError for these, which routes the procedure to CorrectData
+			invalid := errors.Is(err, camunda.ErrMissingVariable) || errors.Is(err, camunda.ErrInvalidVariables)
+			if invalid && topic.InvalidVariablesError != "" {
+				if err := api.setWorkflowStatus(ctx, id, task.ProcessInstanceId, ambulance.WorkflowNeedsCorrection); err != nil {
+					log.Printf("[%s] Failed to set workflow status of procedure %s: %v", topic.Name, id, err)
+				}
+			}
+			return nil, err
+		}
+
+		if err := api.setWorkflowStatus(ctx, id, task.ProcessInstanceId, done(result)); err != nil {
+			return nil, fmt.Errorf("setting workflow status of procedure %s: %w", id, err)
+		}
+		return result, nil
+	}
+}
+
+// validated is the workflow status of a procedure after the Validate Data task returned result
+func validated(result map[string]any) string {
+	if valid, _ := result["procedureValid"].(bool); valid {
+		return ambulance.WorkflowAwaitingApproval
+	}
+	return ambulance.WorkflowNeedsCorrection
+}
+
+// status returns a done function of tracked that always sets the status s
+func status(s string) func(map[string]any) string {
+	return func(map[string]any) string { return s }
+}
+
+// markFailed records that the procedure of a task that raised an incident is stuck until
+// somebody resolves the incident
+func markFailed(api *apiClient) func(ctx context.Context, task *camunda.ExternalTask, err error) {
+	return func(ctx context.Context, task *camunda.ExternalTask, _ error) {
+		if task.BusinessKey == "" {
+			return
+		}
+		if err := api.setWorkflowStatus(ctx, task.BusinessKey, task.ProcessInstanceId, ambulance.WorkflowFailed); err != nil {
+			log.Printf("[%s] Failed to set workflow status of procedure %s: %v", task.TopicName, task.BusinessKey, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wac-project/wac-api/internal/ambulance"
+	"github.com/wac-project/wac-api/pkg/camunda"
+)
+
+type WorkflowStatusSuite struct {
+	suite.Suite
+	api    *fakeAPI
+	server *httptest.Server
+	client *apiClient
+}
+
+func TestWorkflowStatusSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowStatusSuite))
+}
+
+func (suite *WorkflowStatusSuite) SetupTest() {
+	suite.api, suite.server = newFakeAPI()
+	suite.client = newAPIClient(suite.server.URL+"/api", workerToken)
+}
+
+func (suite *WorkflowStatusSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+// handlerReturning returns a handler with the given result
+func handlerReturning(result map[string]any, err error) camunda.HandlerFunc {
+	return func(context.Context, *camunda.ExternalTask) (map[string]any, error) {
+		return result, err
+	}
+}
+
+func (suite *WorkflowStatusSuite) Test_Tracked_SetsRunningAndDoneStatus() {
+	topic := camunda.Topic{Name: "taskTopic2", InvalidVariablesError: invalidProcedureError}
+	handler := tracked(suite.client, topic, ambulance.WorkflowValidating, handlerReturning(map[string]any{"procedureValid": true}, nil), validated)
+
+	result, err := handler(context.Background(), &camunda.ExternalTask{Id: "t1", BusinessKey: "p1", ProcessInstanceId: "pi1"})
+	suite.Require().NoError(err)
+	suite.Equal(map[string]any{"procedureValid": true}, result)
+	suite.Equal([]string{ambulance.WorkflowValidating, ambulance.WorkflowAwaitingApproval}, suite.api.statuses["p1"])
+	suite.Equal("pi1", suite.api.processes["p1"])
+
+	// tasks of processes without a business key are handled without tracking
+	_, err = handler(context.Background(), &camunda.ExternalTask{Id: "t2"})
+	suite.Require().NoError(err)
+	suite.Len(suite.api.statuses, 1)
+}
+
+func (suite *WorkflowStatusSuite) Test_Tracked_InvalidVariables_NeedCorrection() {
+	invalid := handlerReturning(nil, camunda.ErrMissingVariable)
+	topic := camunda.Topic{Name: "taskTopic1", InvalidVariablesError: invalidProcedureError}
+
+	_, err := tracked(suite.client, topic, "", invalid, status(ambulance.WorkflowValidating))(context.Background(), &camunda.ExternalTask{BusinessKey: "p1"})
+	suite.ErrorIs(err, camunda.ErrMissingVariable)
+	suite.Equal([]string{ambulance.WorkflowNeedsCorrection}, suite.api.statuses["p1"])
+
+	// other failures are retried, the status stays until they succeed or raise an incident
+	_, err = tracked(suite.client, topic, "", handlerReturning(nil, errors.New("boom")), status(ambulance.WorkflowValidating))(context.Background(), &camunda.ExternalTask{BusinessKey: "p1"})
+	suite.Error(err)
+	suite.Len(suite.api.statuses["p1"], 1)
+}
+
+func (suite *WorkflowStatusSuite) Test_MarkFailed_SetsFailedStatus() {
+	markFailed(suite.client)(context.Background(), &camunda.ExternalTask{TopicName: "taskTopic3", BusinessKey: "p1"}, errors.New("boom"))
+	suite.Equal([]string{ambulance.WorkflowFailed}, suite.api.statuses["p1"])
+
+	// procedures deleted meanwhile are ignored
+	suite.NoError(suite.client.setWorkflowStatus(context.Background(), "p404", "pi404", ambulance.WorkflowFailed))
+}
+
+func (suite *WorkflowStatusSuite) Test_SetWorkflowStatus_IgnoresStaleStatus() {
+	// the procedure moved past the status a retried task reports again
+	suite.api.stale[ambulance.WorkflowValidating] = true
+	suite.NoError(suite.client.setWorkflowStatus(context.Background(), "p1", "pi1", ambulance.WorkflowValidating))
+	suite.Empty(suite.api.statuses["p1"])
+
+	// without the token the update fails, so that the task is retried
+	suite.Error(newAPIClient(suite.server.URL+"/api", "").setWorkflowStatus(context.Background(), "p1", "pi1", ambulance.WorkflowBilling))
+	suite.Empty(suite.api.statuses["p1"])
+}